@@ -4,11 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 
+	"github.com/celestiaorg/bittwister/xdp"
 	"github.com/celestiaorg/bittwister/xdp/bandwidth"
+	"github.com/celestiaorg/bittwister/xdp/corrupt"
+	"github.com/celestiaorg/bittwister/xdp/duplicate"
 	"github.com/celestiaorg/bittwister/xdp/latency"
 	"github.com/celestiaorg/bittwister/xdp/packetloss"
+	"github.com/celestiaorg/bittwister/xdp/partition"
+	"github.com/celestiaorg/bittwister/xdp/reorder"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
@@ -22,31 +28,119 @@ func NewRESTApiV1(productionMode bool, logger *zap.Logger) *RESTApiV1 {
 		productionMode: productionMode,
 
 		// initialize the xdp services
-		bw: &netRestrictService{service: &bandwidth.Bandwidth{}},
-		lt: &netRestrictService{service: &latency.Latency{}},
-		pl: &netRestrictService{service: &packetloss.PacketLoss{}},
+		bw: newNetServiceGroup("bandwidth", func() xdp.XdpLoader { return &bandwidth.Bandwidth{} }),
+		dp: newNetServiceGroup("duplicate", func() xdp.XdpLoader { return &duplicate.Duplicate{} }),
+		lt: newNetServiceGroup("latency", func() xdp.XdpLoader { return &latency.Latency{} }),
+		pl: newNetServiceGroup("packetloss", func() xdp.XdpLoader { return &packetloss.PacketLoss{} }),
+		ro: newNetServiceGroup("reorder", func() xdp.XdpLoader { return &reorder.Reorder{} }),
+		cr: newNetServiceGroup("corrupt", func() xdp.XdpLoader { return &corrupt.Corrupt{} }),
+		pt: newNetServiceGroup("partition", func() xdp.XdpLoader { return &partition.Partition{} }),
 	}
 
+	restAPI.initMetrics()
+	restAPI.router.Use(restAPI.instrument)
+
 	restAPI.router.HandleFunc("/", restAPI.IndexPage).Methods(http.MethodGet, http.MethodPost, http.MethodOptions, http.MethodPut, http.MethodHead)
 
 	restAPI.router.HandleFunc(PacketlossPath.Start(), restAPI.PacketlossStart).Methods(http.MethodPost)
 	restAPI.router.HandleFunc(PacketlossPath.Status(), restAPI.PacketlossStatus).Methods(http.MethodGet)
 	restAPI.router.HandleFunc(PacketlossPath.Stop(), restAPI.PacketlossStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(PacketlossPath.InterfaceStart(ifacePathTemplate), restAPI.PacketlossStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(PacketlossPath.InterfaceStatus(ifacePathTemplate), restAPI.PacketlossStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(PacketlossPath.InterfaceStop(ifacePathTemplate), restAPI.PacketlossStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(PacketlossPath.Stats(), restAPI.PacketlossStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(PacketlossPath.InterfaceStats(ifacePathTemplate), restAPI.PacketlossStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(PacketlossPath.Params(), restAPI.PacketlossUpdate).Methods(http.MethodPut)
+	restAPI.router.HandleFunc(PacketlossPath.InterfaceParams(ifacePathTemplate), restAPI.PacketlossUpdate).Methods(http.MethodPut)
 
 	restAPI.router.HandleFunc(BandwidthPath.Start(), restAPI.BandwidthStart).Methods(http.MethodPost)
 	restAPI.router.HandleFunc(BandwidthPath.Status(), restAPI.BandwidthStatus).Methods(http.MethodGet)
 	restAPI.router.HandleFunc(BandwidthPath.Stop(), restAPI.BandwidthStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(BandwidthPath.InterfaceStart(ifacePathTemplate), restAPI.BandwidthStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(BandwidthPath.InterfaceStatus(ifacePathTemplate), restAPI.BandwidthStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(BandwidthPath.InterfaceStop(ifacePathTemplate), restAPI.BandwidthStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(BandwidthPath.Stats(), restAPI.BandwidthStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(BandwidthPath.InterfaceStats(ifacePathTemplate), restAPI.BandwidthStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(BandwidthPath.Params(), restAPI.BandwidthUpdate).Methods(http.MethodPut)
+	restAPI.router.HandleFunc(BandwidthPath.InterfaceParams(ifacePathTemplate), restAPI.BandwidthUpdate).Methods(http.MethodPut)
 
 	restAPI.router.HandleFunc(LatencyPath.Start(), restAPI.LatencyStart).Methods(http.MethodPost)
 	restAPI.router.HandleFunc(LatencyPath.Status(), restAPI.LatencyStatus).Methods(http.MethodGet)
 	restAPI.router.HandleFunc(LatencyPath.Stop(), restAPI.LatencyStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(LatencyPath.InterfaceStart(ifacePathTemplate), restAPI.LatencyStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(LatencyPath.InterfaceStatus(ifacePathTemplate), restAPI.LatencyStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(LatencyPath.InterfaceStop(ifacePathTemplate), restAPI.LatencyStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(LatencyPath.Stats(), restAPI.LatencyStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(LatencyPath.InterfaceStats(ifacePathTemplate), restAPI.LatencyStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(LatencyPath.Params(), restAPI.LatencyUpdate).Methods(http.MethodPut)
+	restAPI.router.HandleFunc(LatencyPath.InterfaceParams(ifacePathTemplate), restAPI.LatencyUpdate).Methods(http.MethodPut)
+
+	restAPI.router.HandleFunc(DuplicatePath.Start(), restAPI.DuplicateStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(DuplicatePath.Status(), restAPI.DuplicateStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(DuplicatePath.Stop(), restAPI.DuplicateStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(DuplicatePath.InterfaceStart(ifacePathTemplate), restAPI.DuplicateStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(DuplicatePath.InterfaceStatus(ifacePathTemplate), restAPI.DuplicateStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(DuplicatePath.InterfaceStop(ifacePathTemplate), restAPI.DuplicateStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(DuplicatePath.Stats(), restAPI.DuplicateStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(DuplicatePath.InterfaceStats(ifacePathTemplate), restAPI.DuplicateStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(DuplicatePath.Params(), restAPI.DuplicateUpdate).Methods(http.MethodPut)
+	restAPI.router.HandleFunc(DuplicatePath.InterfaceParams(ifacePathTemplate), restAPI.DuplicateUpdate).Methods(http.MethodPut)
+
+	restAPI.router.HandleFunc(ReorderPath.Start(), restAPI.ReorderStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(ReorderPath.Status(), restAPI.ReorderStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(ReorderPath.Stop(), restAPI.ReorderStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(ReorderPath.InterfaceStart(ifacePathTemplate), restAPI.ReorderStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(ReorderPath.InterfaceStatus(ifacePathTemplate), restAPI.ReorderStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(ReorderPath.InterfaceStop(ifacePathTemplate), restAPI.ReorderStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(ReorderPath.Stats(), restAPI.ReorderStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(ReorderPath.InterfaceStats(ifacePathTemplate), restAPI.ReorderStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(ReorderPath.Params(), restAPI.ReorderUpdate).Methods(http.MethodPut)
+	restAPI.router.HandleFunc(ReorderPath.InterfaceParams(ifacePathTemplate), restAPI.ReorderUpdate).Methods(http.MethodPut)
+
+	restAPI.router.HandleFunc(CorruptPath.Start(), restAPI.CorruptStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(CorruptPath.Status(), restAPI.CorruptStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(CorruptPath.Stop(), restAPI.CorruptStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(CorruptPath.InterfaceStart(ifacePathTemplate), restAPI.CorruptStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(CorruptPath.InterfaceStatus(ifacePathTemplate), restAPI.CorruptStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(CorruptPath.InterfaceStop(ifacePathTemplate), restAPI.CorruptStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(CorruptPath.Stats(), restAPI.CorruptStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(CorruptPath.InterfaceStats(ifacePathTemplate), restAPI.CorruptStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(CorruptPath.Params(), restAPI.CorruptUpdate).Methods(http.MethodPut)
+	restAPI.router.HandleFunc(CorruptPath.InterfaceParams(ifacePathTemplate), restAPI.CorruptUpdate).Methods(http.MethodPut)
+
+	restAPI.router.HandleFunc(PartitionPath.Start(), restAPI.PartitionStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(PartitionPath.Status(), restAPI.PartitionStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(PartitionPath.Stop(), restAPI.PartitionStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(PartitionPath.InterfaceStart(ifacePathTemplate), restAPI.PartitionStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(PartitionPath.InterfaceStatus(ifacePathTemplate), restAPI.PartitionStatus).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(PartitionPath.InterfaceStop(ifacePathTemplate), restAPI.PartitionStop).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(PartitionPath.Stats(), restAPI.PartitionStats).Methods(http.MethodGet)
+	restAPI.router.HandleFunc(PartitionPath.InterfaceStats(ifacePathTemplate), restAPI.PartitionStats).Methods(http.MethodGet)
+
+	restAPI.router.HandleFunc(ScenarioPath, restAPI.ScenarioStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(SchedulePath, restAPI.ScheduleStart).Methods(http.MethodPost)
+	restAPI.router.HandleFunc(SchedulePath+"/stop", restAPI.ScheduleStop).Methods(http.MethodPost)
 
 	restAPI.router.HandleFunc(ServicesPath.Status(), restAPI.NetServicesStatus).Methods(http.MethodGet)
 
+	restAPI.router.HandleFunc(MetricsPath, restAPI.Metrics).Methods(http.MethodGet)
+
 	return restAPI
 }
 
-func (a *RESTApiV1) Serve(addr, originAllowed string) error {
+// Serve serves the API on addr until Shutdown is called. Without options,
+// it accepts all the requests over plain HTTP.
+func (a *RESTApiV1) Serve(addr, originAllowed string, opts ...ServeOption) error {
+	cfg := &serveConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return err
+	}
+	a.authToken = cfg.authToken
+
 	http.Handle("/", a.router)
 
 	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "X-CSRF-Token"})
@@ -56,25 +150,84 @@ func (a *RESTApiV1) Serve(addr, originAllowed string) error {
 	a.logger.Info(fmt.Sprintf("serving on %s", addr))
 
 	a.server = &http.Server{
-		Addr:    addr,
-		Handler: handlers.CORS(originsOk, headersOk, methodsOk)(a.router),
+		Addr:      addr,
+		Handler:   handlers.CORS(originsOk, headersOk, methodsOk)(a.authenticate(a.router)),
+		TLSConfig: tlsConfig,
 	}
 
+	if tlsConfig != nil {
+		return a.server.ListenAndServeTLS(cfg.certFile, cfg.keyFile)
+	}
 	return a.server.ListenAndServe()
 }
 
+// AdoptPinned takes over the services a previous process left running in
+// the objects pinned in xdp.PinPath, so that they can be stopped and updated
+// through the API. It is called before Serve.
+func (a *RESTApiV1) AdoptPinned() error {
+	indexes, err := xdp.PinnedInterfaces()
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		iface, err := net.InterfaceByIndex(index)
+		if err != nil {
+			a.loggerNoStack.Warn("network interface of pinned objects not found, remove them with `bittwister cleanup`",
+				zap.Int("index", index), zap.Error(err))
+			continue
+		}
+
+		// The object is held while the services are adopted one by one, so it
+		// is not closed, and its pins removed, between two of them.
+		x, err := xdp.GetPreparedXdpObject(index)
+		if errors.Is(err, xdp.ErrPinsIncompatible) {
+			a.loggerNoStack.Warn("pinned objects left by an incompatible bittwister version, their services are stopped and the pins removed",
+				zap.String("network_interface", iface.Name), zap.Error(err))
+			if err := xdp.RemoveInterfacePins(index); err != nil {
+				return fmt.Errorf("remove pinned objects of %s: %w", iface.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("adopt pinned objects of %s: %w", iface.Name, err)
+		}
+
+		for _, g := range []*netServiceGroup{a.pl, a.bw, a.lt, a.dp, a.ro, a.cr, a.pt} {
+			adopted, err := g.adopt(iface.Name)
+			if err != nil {
+				return errors.Join(fmt.Errorf("adopt %s service on %s: %w", g.name, iface.Name, err), x.Close())
+			}
+			if adopted {
+				a.logger.Info("adopted pinned service", zap.String("service", g.name), zap.String("network_interface", iface.Name))
+			}
+		}
+
+		if err := x.Close(); err != nil {
+			return fmt.Errorf("close XDP object: %w", err)
+		}
+	}
+	return nil
+}
+
 // Shutdown stops the API server and all running XDP services.
 func (a *RESTApiV1) Shutdown() error {
 	if a.server == nil {
 		return errors.New("server is not running")
 	}
-	for _, s := range []*netRestrictService{a.pl, a.bw, a.lt} {
-		if s != nil && s.ready {
+	a.stopSchedule()
+	for _, g := range []*netServiceGroup{a.pl, a.bw, a.lt, a.dp, a.ro, a.cr, a.pt} {
+		for _, s := range g.ReadyInstances("") {
 			if err := s.Stop(); err != nil {
 				return fmt.Errorf("error while stopping service: %w", err)
 			}
 		}
 	}
+	if a.metricsServer != nil {
+		if err := a.metricsServer.Shutdown(context.Background()); err != nil {
+			return fmt.Errorf("error while stopping the metrics server: %w", err)
+		}
+	}
 	return a.server.Shutdown(context.Background())
 }
 
@@ -2,6 +2,10 @@ package api
 
 const endpointPrefix = "/api/v1"
 
+// pathVarNetworkInterface is the name of the path variable that addresses
+// a service instance by its network interface name.
+const pathVarNetworkInterface = "iface"
+
 type serviceEndpointPath struct {
 	basePath string
 }
@@ -18,9 +22,62 @@ func (e *serviceEndpointPath) Stop() string {
 	return endpointPrefix + e.basePath + "/stop"
 }
 
+// Params returns the path to update the parameters of the running service.
+func (e *serviceEndpointPath) Params() string {
+	return endpointPrefix + e.basePath + "/params"
+}
+
+// Stats returns the path of the packet counters of the running service.
+func (e *serviceEndpointPath) Stats() string {
+	return endpointPrefix + e.basePath + "/stats"
+}
+
+// InterfaceStatus returns the status path of the service instance running
+// on the given network interface.
+func (e *serviceEndpointPath) InterfaceStatus(ifaceName string) string {
+	return endpointPrefix + e.basePath + "/" + ifaceName + "/status"
+}
+
+// InterfaceStart returns the start path of the service instance running
+// on the given network interface.
+func (e *serviceEndpointPath) InterfaceStart(ifaceName string) string {
+	return endpointPrefix + e.basePath + "/" + ifaceName + "/start"
+}
+
+// InterfaceStop returns the stop path of the service instance running
+// on the given network interface.
+func (e *serviceEndpointPath) InterfaceStop(ifaceName string) string {
+	return endpointPrefix + e.basePath + "/" + ifaceName + "/stop"
+}
+
+// InterfaceParams returns the path to update the parameters of the service
+// instance running on the given network interface.
+func (e *serviceEndpointPath) InterfaceParams(ifaceName string) string {
+	return endpointPrefix + e.basePath + "/" + ifaceName + "/params"
+}
+
+// InterfaceStats returns the path of the packet counters of the service
+// instance running on the given network interface.
+func (e *serviceEndpointPath) InterfaceStats(ifaceName string) string {
+	return endpointPrefix + e.basePath + "/" + ifaceName + "/stats"
+}
+
 var (
 	PacketlossPath = &serviceEndpointPath{basePath: "/packetloss"}
 	BandwidthPath  = &serviceEndpointPath{basePath: "/bandwidth"}
 	LatencyPath    = &serviceEndpointPath{basePath: "/latency"}
+	DuplicatePath  = &serviceEndpointPath{basePath: "/duplicate"}
+	ReorderPath    = &serviceEndpointPath{basePath: "/reorder"}
+	CorruptPath    = &serviceEndpointPath{basePath: "/corrupt"}
+	PartitionPath  = &serviceEndpointPath{basePath: "/partition"}
 	ServicesPath   = &serviceEndpointPath{basePath: "/services"}
 )
+
+// ScenarioPath is the path to start the services of a scenario at once.
+var ScenarioPath = endpointPrefix + "/scenario"
+
+// SchedulePath is the path to start a schedule, SchedulePath + "/stop" stops it.
+var SchedulePath = endpointPrefix + "/schedule"
+
+// ifacePathTemplate is the route template of the network interface path variable.
+var ifacePathTemplate = "{" + pathVarNetworkInterface + "}"
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ServeOption configures how Serve accepts the API requests.
+type ServeOption func(*serveConfig)
+
+type serveConfig struct {
+	authToken    string
+	certFile     string
+	keyFile      string
+	clientCAFile string
+}
+
+// WithAuthToken requires every API request to carry the token in an
+// `Authorization: Bearer <token>` header.
+func WithAuthToken(token string) ServeOption {
+	return func(c *serveConfig) {
+		c.authToken = token
+	}
+}
+
+// WithTLS serves the API over HTTPS with the given certificate and key files.
+func WithTLS(certFile, keyFile string) ServeOption {
+	return func(c *serveConfig) {
+		c.certFile = certFile
+		c.keyFile = keyFile
+	}
+}
+
+// WithClientCA requires the clients to present a certificate signed by one of
+// the CAs of the PEM file (mutual TLS). It needs WithTLS.
+func WithClientCA(caFile string) ServeOption {
+	return func(c *serveConfig) {
+		c.clientCAFile = caFile
+	}
+}
+
+func (c *serveConfig) tlsConfig() (*tls.Config, error) {
+	if c.certFile == "" && c.keyFile == "" {
+		if c.clientCAFile != "" {
+			return nil, errors.New("a client CA needs a TLS certificate and key")
+		}
+		return nil, nil
+	}
+	if c.certFile == "" || c.keyFile == "" {
+		return nil, errors.New("TLS needs both a certificate and a key")
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.clientCAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(c.clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in client CA %q", c.clientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return cfg, nil
+}
+
+// authenticate is a middleware rejecting the requests without the bearer
+// token, if one is set.
+func (a *RESTApiV1) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if a.authToken == "" || validBearerToken(req.Header.Get("Authorization"), a.authToken) {
+			next.ServeHTTP(resp, req)
+			return
+		}
+
+		a.loggerNoStack.Warn("unauthorized API request",
+			zap.String("method", req.Method),
+			zap.String("path", req.URL.Path),
+			zap.String("remote_addr", req.RemoteAddr))
+
+		resp.Header().Set("WWW-Authenticate", `Bearer realm="bittwister"`)
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugUnauthorized,
+				Title:   "Unauthorized",
+				Message: "A valid bearer token is required in the Authorization header.",
+			},
+			http.StatusUnauthorized)
+	})
+}
+
+func validBearerToken(header, token string) bool {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return false
+	}
+	got := strings.TrimSpace(header[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
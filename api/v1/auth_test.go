@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuthenticate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		token    string
+		header   string
+		wantCode int
+	}{
+		{name: "no token set", wantCode: http.StatusOK},
+		{name: "valid token", token: "s3cret", header: "Bearer s3cret", wantCode: http.StatusOK},
+		{name: "lowercase scheme", token: "s3cret", header: "bearer s3cret", wantCode: http.StatusOK},
+		{name: "missing header", token: "s3cret", wantCode: http.StatusUnauthorized},
+		{name: "wrong token", token: "s3cret", header: "Bearer guess", wantCode: http.StatusUnauthorized},
+		{name: "token prefix", token: "s3cret", header: "Bearer s3c", wantCode: http.StatusUnauthorized},
+		{name: "basic auth", token: "s3cret", header: "Basic czNjcmV0", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &RESTApiV1{loggerNoStack: zap.NewNop(), authToken: tc.token}
+			handler := a.authenticate(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, PacketlossPath.Start(), nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantCode, rr.Code)
+			if tc.wantCode == http.StatusUnauthorized {
+				assert.Contains(t, rr.Body.String(), SlugUnauthorized)
+				assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestServeConfigTLS(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, selfSignedCertPEM(t), 0o600))
+
+	testCases := []struct {
+		name       string
+		cfg        serveConfig
+		wantTLS    bool
+		wantMTLS   bool
+		shouldFail bool
+	}{
+		{name: "plain HTTP"},
+		{name: "TLS", cfg: serveConfig{certFile: "cert.pem", keyFile: "key.pem"}, wantTLS: true},
+		{name: "mutual TLS", cfg: serveConfig{certFile: "cert.pem", keyFile: "key.pem", clientCAFile: caFile}, wantTLS: true, wantMTLS: true},
+		{name: "missing key", cfg: serveConfig{certFile: "cert.pem"}, shouldFail: true},
+		{name: "client CA without TLS", cfg: serveConfig{clientCAFile: caFile}, shouldFail: true},
+		{name: "missing client CA", cfg: serveConfig{certFile: "cert.pem", keyFile: "key.pem", clientCAFile: filepath.Join(t.TempDir(), "missing.pem")}, shouldFail: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := tc.cfg.tlsConfig()
+			if tc.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantTLS, cfg != nil)
+			if tc.wantMTLS {
+				assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+				assert.NotNil(t, cfg.ClientCAs)
+			}
+		})
+	}
+}
+
+func selfSignedCertPEM(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "bittwister test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/celestiaorg/bittwister/xdp"
 	"go.uber.org/zap"
 )
 
-// BandwidthStart implements POST /bandwidth/start
+// BandwidthStart implements POST /bandwidth/start and POST /bandwidth/{iface}/start
 func (a *RESTApiV1) BandwidthStart(resp http.ResponseWriter, req *http.Request) {
 	var body BandwidthStartRequest
 	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
@@ -22,38 +23,96 @@ func (a *RESTApiV1) BandwidthStart(resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	if !ensureServiceInitialized(resp, a.bw) {
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	err := netServiceStart(resp, a.bw, ifaceName, body)
+	if err != nil {
+		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	}
+}
+
+// BandwidthStop implements POST /bandwidth/stop and POST /bandwidth/{iface}/stop
+//
+// Without a network interface in the path, it stops the service on all interfaces.
+func (a *RESTApiV1) BandwidthStop(resp http.ResponseWriter, req *http.Request) {
+	if !ensureServiceGroupInitialized(resp, a.bw) {
 		return
 	}
 
-	if err := a.bw.SetBandwidthLimit(body.Limit); err != nil {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStop(resp, a.bw.ReadyInstances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+	}
+}
+
+// BandwidthUpdate implements PUT /bandwidth/params and PUT /bandwidth/{iface}/params
+//
+// It changes the parameters of the running service without stopping it.
+// Without a network interface, it updates the service on all interfaces.
+func (a *RESTApiV1) BandwidthUpdate(resp http.ResponseWriter, req *http.Request) {
+	var body BandwidthUpdateRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
 		sendJSONError(resp,
 			MetaMessage{
 				Type:    APIMetaMessageTypeError,
-				Slug:    SlugServiceSetParamFailed,
-				Title:   "Service set param failed",
+				Slug:    SlugJSONDecodeFailed,
+				Title:   "JSON decode failed",
 				Message: err.Error(),
 			},
-			http.StatusInternalServerError)
+			http.StatusBadRequest)
 		return
 	}
 
-	err := netServiceStart(resp, a.bw, body.NetworkInterfaceName)
-	if err != nil {
-		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	if err := netServiceUpdate(resp, a.bw, ifaceName, body); err != nil {
+		a.loggerNoStack.Error("netServiceUpdate failed", zap.Error(err))
 	}
 }
 
-// BandwidthStop implements POST /bandwidth/stop
-func (a *RESTApiV1) BandwidthStop(resp http.ResponseWriter, req *http.Request) {
-	if err := netServiceStop(resp, a.bw); err != nil {
-		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+// BandwidthStatus implements GET /bandwidth/status and GET /bandwidth/{iface}/status
+//
+// Without a network interface in the path, the service is reported ready
+// if it is running on any interface.
+func (a *RESTApiV1) BandwidthStatus(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStatus(resp, a.bw, a.bw.Instances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
 	}
 }
 
-// BandwidthStatus implements GET /bandwidth/status
-func (a *RESTApiV1) BandwidthStatus(resp http.ResponseWriter, _ *http.Request) {
-	if err := netServiceStatus(resp, a.bw); err != nil {
-		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
+// BandwidthStats implements GET /bandwidth/stats and GET /bandwidth/{iface}/stats
+//
+// Without a network interface in the path, the counters of the service on
+// all the interfaces it runs on are returned.
+func (a *RESTApiV1) BandwidthStats(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStats(resp, a.bw, ifaceName); err != nil {
+		a.loggerNoStack.Error("netServiceStats failed", zap.Error(err))
+	}
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r BandwidthStartRequest) apply(ns *netRestrictService) error {
+	err := ns.SetBandwidthLimit(r.Limit, r.Burst)
+	if err == nil {
+		err = ns.SetBandwidthDirectionLimits(r.LimitIngress, r.LimitEgress)
+	}
+	if err == nil {
+		err = ns.SetDirection(xdp.Direction(r.Direction))
+	}
+	if err == nil {
+		err = ns.SetPeers(r.Peers)
+	}
+	if err == nil {
+		err = ns.SetProtocolFilter(r.Protocol, r.Ports)
+	}
+	return err
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r BandwidthUpdateRequest) apply(ns *netRestrictService) error {
+	err := ns.SetBandwidthLimit(r.Limit, r.Burst)
+	if err == nil {
+		err = ns.SetBandwidthDirectionLimits(r.LimitIngress, r.LimitEgress)
 	}
+	return err
 }
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os/exec"
 
 	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/celestiaorg/bittwister/xdp/bandwidth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -66,6 +68,161 @@ func (s *APITestSuite) TestBandwidthStatus() {
 	assert.Equal(t, api.SlugServiceNotReady, slug)
 }
 
+func (s *APITestSuite) TestBandwidthBurst() {
+	t := s.T()
+
+	tests := []struct {
+		burst     int64
+		wantCode  int
+		wantBurst float64
+	}{
+		{burst: 0, wantCode: http.StatusOK, wantBurst: bandwidth.MinBurst},
+		{burst: 1 << 20, wantCode: http.StatusOK, wantBurst: 1 << 20},
+		{burst: 1500, wantCode: http.StatusInternalServerError},
+		{burst: bandwidth.MaxBurst + 1, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultBandwidthStartRequest()
+		body.Burst = tt.burst
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.BandwidthPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.BandwidthStart(rr, req)
+		require.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.NetServicesStatus(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var statuses []api.ServiceStatus
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&statuses))
+		for _, st := range statuses {
+			if st.Name == "bandwidth" && st.NetworkInterfaceName == s.ifaceName {
+				assert.Equal(t, tt.wantBurst, st.Params["burst"])
+			}
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.BandwidthStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) TestBandwidthAsymmetric() {
+	t := s.T()
+
+	tests := []struct {
+		direction     string
+		limitIngress  int64
+		limitEgress   int64
+		wantCode      int
+		wantDirection string
+	}{
+		{limitIngress: 8 << 20, limitEgress: 1 << 20, wantCode: http.StatusOK, wantDirection: "both"},
+		{limitEgress: 1 << 20, wantCode: http.StatusOK, wantDirection: "egress"},
+		{direction: "egress", limitIngress: 8 << 20, limitEgress: 1 << 20, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultBandwidthStartRequest()
+		body.Limit = 0
+		body.LimitIngress = tt.limitIngress
+		body.LimitEgress = tt.limitEgress
+		body.Direction = tt.direction
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.BandwidthPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.BandwidthStart(rr, req)
+		require.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.NetServicesStatus(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var statuses []api.ServiceStatus
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&statuses))
+		for _, st := range statuses {
+			if st.Name == "bandwidth" && st.NetworkInterfaceName == s.ifaceName {
+				assert.Equal(t, tt.wantDirection, st.Params["direction"])
+				assert.Equal(t, float64(tt.limitIngress), st.Params["limit_ingress"])
+				assert.Equal(t, float64(tt.limitEgress), st.Params["limit_egress"])
+			}
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.BandwidthStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) TestBandwidthUpdateRollback() {
+	t := s.T()
+
+	// a second interface, updated before the loopback one
+	const ifb = "bttest0"
+	if err := exec.Command("ip", "link", "add", ifb, "up", "type", "ifb").Run(); err != nil {
+		t.Skipf("add ifb device: %v", err)
+	}
+	defer func() { _ = exec.Command("ip", "link", "del", ifb).Run() }()
+
+	for ifaceName, direction := range map[string]string{ifb: "both", s.ifaceName: "ingress"} {
+		body := s.getDefaultBandwidthStartRequest()
+		body.NetworkInterfaceName = ifaceName
+		body.Direction = direction
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.BandwidthPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		s.restAPI.BandwidthStart(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+	defer s.restAPI.BandwidthStop(httptest.NewRecorder(), nil)
+
+	// an egress limit fits the ifb instance, not the ingress only one
+	jsonBody, err := json.Marshal(api.BandwidthUpdateRequest{LimitIngress: 1 << 20, LimitEgress: 1 << 20})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, api.BandwidthPath.Params(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	s.restAPI.BandwidthUpdate(rr, req)
+	require.Equal(t, http.StatusInternalServerError, rr.Code, rr.Body.String())
+
+	// the instance updated first got its previous limits back
+	rr = httptest.NewRecorder()
+	s.restAPI.NetServicesStatus(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var statuses []api.ServiceStatus
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&statuses))
+	found := 0
+	for _, st := range statuses {
+		if st.Name != "bandwidth" {
+			continue
+		}
+		found++
+		assert.EqualValues(t, 100, st.Params["limit"], st.NetworkInterfaceName)
+		assert.EqualValues(t, 0, st.Params["limit_egress"], st.NetworkInterfaceName)
+	}
+	assert.Equal(t, 2, found)
+}
+
 func (s *APITestSuite) getDefaultBandwidthStartRequest() api.BandwidthStartRequest {
 	return api.BandwidthStartRequest{
 		NetworkInterfaceName: s.ifaceName,
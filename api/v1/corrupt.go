@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// CorruptStart implements POST /corrupt/start and POST /corrupt/{iface}/start
+func (a *RESTApiV1) CorruptStart(resp http.ResponseWriter, req *http.Request) {
+	var body CorruptStartRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugJSONDecodeFailed,
+				Title:   "JSON decode failed",
+				Message: err.Error(),
+			},
+			http.StatusBadRequest)
+		return
+	}
+
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	err := netServiceStart(resp, a.cr, ifaceName, body)
+	if err != nil {
+		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	}
+}
+
+// CorruptStop implements POST /corrupt/stop and POST /corrupt/{iface}/stop
+//
+// Without a network interface in the path, it stops the service on all interfaces.
+func (a *RESTApiV1) CorruptStop(resp http.ResponseWriter, req *http.Request) {
+	if !ensureServiceGroupInitialized(resp, a.cr) {
+		return
+	}
+
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStop(resp, a.cr.ReadyInstances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+	}
+}
+
+// CorruptUpdate implements PUT /corrupt/params and PUT /corrupt/{iface}/params
+//
+// It changes the parameters of the running service without stopping it.
+// Without a network interface, it updates the service on all interfaces.
+func (a *RESTApiV1) CorruptUpdate(resp http.ResponseWriter, req *http.Request) {
+	var body CorruptUpdateRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugJSONDecodeFailed,
+				Title:   "JSON decode failed",
+				Message: err.Error(),
+			},
+			http.StatusBadRequest)
+		return
+	}
+
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	if err := netServiceUpdate(resp, a.cr, ifaceName, body); err != nil {
+		a.loggerNoStack.Error("netServiceUpdate failed", zap.Error(err))
+	}
+}
+
+// CorruptStatus implements GET /corrupt/status and GET /corrupt/{iface}/status
+//
+// Without a network interface in the path, the service is reported ready
+// if it is running on any interface.
+func (a *RESTApiV1) CorruptStatus(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStatus(resp, a.cr, a.cr.Instances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
+	}
+}
+
+// CorruptStats implements GET /corrupt/stats and GET /corrupt/{iface}/stats
+//
+// Without a network interface in the path, the counters of the service on
+// all the interfaces it runs on are returned.
+func (a *RESTApiV1) CorruptStats(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStats(resp, a.cr, ifaceName); err != nil {
+		a.loggerNoStack.Error("netServiceStats failed", zap.Error(err))
+	}
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r CorruptStartRequest) apply(ns *netRestrictService) error {
+	err := ns.SetCorruptParams(r.CorruptRate, r.PayloadOnly, r.FixChecksum)
+	if err == nil {
+		err = ns.SetPeers(r.Peers)
+	}
+	if err == nil {
+		err = ns.SetProtocolFilter(r.Protocol, r.Ports)
+	}
+	return err
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r CorruptUpdateRequest) apply(ns *netRestrictService) error {
+	return ns.SetCorruptParams(r.CorruptRate, r.PayloadOnly, r.FixChecksum)
+}
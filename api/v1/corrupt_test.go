@@ -0,0 +1,65 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *APITestSuite) TestCorruptStartStop() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(s.getDefaultCorruptStartRequest())
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.CorruptPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.CorruptStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	slug, err := getServiceStatusSlug(s.restAPI.CorruptStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceReady, slug)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.CorruptStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	slug, err = getServiceStatusSlug(s.restAPI.CorruptStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+}
+
+func (s *APITestSuite) TestCorruptInvalidRate() {
+	t := s.T()
+
+	// below one part per million, the rate would read as a stopped service
+	for _, rate := range []float64{101, 0.00001} {
+		body := s.getDefaultCorruptStartRequest()
+		body.CorruptRate = rate
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.CorruptPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.CorruptStart(rr, req)
+		require.Equal(t, http.StatusInternalServerError, rr.Code, "rate %v: %s", rate, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) getDefaultCorruptStartRequest() api.CorruptStartRequest {
+	return api.CorruptStartRequest{
+		NetworkInterfaceName: s.ifaceName,
+		CorruptRate:          10,
+		PayloadOnly:          true,
+	}
+}
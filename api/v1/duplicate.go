@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// DuplicateStart implements POST /duplicate/start and POST /duplicate/{iface}/start
+func (a *RESTApiV1) DuplicateStart(resp http.ResponseWriter, req *http.Request) {
+	var body DuplicateStartRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugJSONDecodeFailed,
+				Title:   "JSON decode failed",
+				Message: err.Error(),
+			},
+			http.StatusBadRequest)
+		return
+	}
+
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	err := netServiceStart(resp, a.dp, ifaceName, body)
+	if err != nil {
+		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	}
+}
+
+// DuplicateStop implements POST /duplicate/stop and POST /duplicate/{iface}/stop
+//
+// Without a network interface in the path, it stops the service on all interfaces.
+func (a *RESTApiV1) DuplicateStop(resp http.ResponseWriter, req *http.Request) {
+	if !ensureServiceGroupInitialized(resp, a.dp) {
+		return
+	}
+
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStop(resp, a.dp.ReadyInstances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+	}
+}
+
+// DuplicateUpdate implements PUT /duplicate/params and PUT /duplicate/{iface}/params
+//
+// It changes the parameters of the running service without stopping it.
+// Without a network interface, it updates the service on all interfaces.
+func (a *RESTApiV1) DuplicateUpdate(resp http.ResponseWriter, req *http.Request) {
+	var body DuplicateUpdateRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugJSONDecodeFailed,
+				Title:   "JSON decode failed",
+				Message: err.Error(),
+			},
+			http.StatusBadRequest)
+		return
+	}
+
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	if err := netServiceUpdate(resp, a.dp, ifaceName, body); err != nil {
+		a.loggerNoStack.Error("netServiceUpdate failed", zap.Error(err))
+	}
+}
+
+// DuplicateStatus implements GET /duplicate/status and GET /duplicate/{iface}/status
+//
+// Without a network interface in the path, the service is reported ready
+// if it is running on any interface.
+func (a *RESTApiV1) DuplicateStatus(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStatus(resp, a.dp, a.dp.Instances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
+	}
+}
+
+// DuplicateStats implements GET /duplicate/stats and GET /duplicate/{iface}/stats
+//
+// Without a network interface in the path, the counters of the service on
+// all the interfaces it runs on are returned.
+func (a *RESTApiV1) DuplicateStats(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStats(resp, a.dp, ifaceName); err != nil {
+		a.loggerNoStack.Error("netServiceStats failed", zap.Error(err))
+	}
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r DuplicateStartRequest) apply(ns *netRestrictService) error {
+	err := ns.SetDuplicateRate(r.DuplicateRate)
+	if err == nil {
+		err = ns.SetPeers(r.Peers)
+	}
+	if err == nil {
+		err = ns.SetProtocolFilter(r.Protocol, r.Ports)
+	}
+	return err
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r DuplicateUpdateRequest) apply(ns *netRestrictService) error {
+	return ns.SetDuplicateRate(r.DuplicateRate)
+}
@@ -0,0 +1,188 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func (s *APITestSuite) TestDuplicateStartStop() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(s.getDefaultDuplicateStartRequest())
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.DuplicatePath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.DuplicateStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	slug, err := getServiceStatusSlug(s.restAPI.DuplicateStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceReady, slug)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.DuplicateStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	slug, err = getServiceStatusSlug(s.restAPI.DuplicateStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+}
+
+func (s *APITestSuite) TestDuplicateStatsWhileStopping() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(s.getDefaultDuplicateStartRequest())
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.DuplicatePath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.DuplicateStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// the stats are read while the service stops, its XDP object must not
+	// be read once closed
+	var (
+		wg      sync.WaitGroup
+		codes   []int
+		read    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			rr := httptest.NewRecorder()
+			s.restAPI.DuplicateStats(rr, httptest.NewRequest(http.MethodGet, api.DuplicatePath.Stats(), nil))
+			codes = append(codes, rr.Code)
+			if len(codes) == 1 {
+				close(read)
+			}
+
+			select {
+			case <-stopped:
+				return
+			default:
+			}
+		}
+	}()
+
+	<-read
+	rr = httptest.NewRecorder()
+	s.restAPI.DuplicateStop(rr, nil)
+	close(stopped)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	wg.Wait()
+	for _, code := range codes {
+		assert.Contains(t, []int{http.StatusOK, http.StatusBadRequest}, code)
+	}
+}
+
+func (s *APITestSuite) TestDuplicateInvalidRate() {
+	t := s.T()
+
+	// below one part per million, the rate would read as a stopped service
+	for _, rate := range []float64{101, 0.00001} {
+		body := s.getDefaultDuplicateStartRequest()
+		body.DuplicateRate = rate
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.DuplicatePath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.DuplicateStart(rr, req)
+		require.Equal(t, http.StatusInternalServerError, rr.Code, "rate %v: %s", rate, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) TestDuplicateWithPacketloss() {
+	t := s.T()
+
+	// Duplicate all the UDP packets of a single loopback address, and count
+	// them with an egress packet loss service which hardly drops any.
+	const peer = "127.0.0.43"
+	plBody := s.getDefaultPacketLossStartRequest()
+	plBody.PacketLossRate = 0.0001
+	plBody.Direction = "egress"
+	plBody.Peers = []string{peer}
+	plBody.Protocol = "udp"
+	jsonBody, err := json.Marshal(plBody)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	defer s.restAPI.PacketlossStop(httptest.NewRecorder(), nil)
+
+	body := s.getDefaultDuplicateStartRequest()
+	body.DuplicateRate = 100
+	body.Peers = []string{peer}
+	body.Protocol = "udp"
+	jsonBody, err = json.Marshal(body)
+	require.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, api.DuplicatePath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	s.restAPI.DuplicateStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	defer s.restAPI.DuplicateStop(httptest.NewRecorder(), nil)
+
+	// the packets carry the highest bit of the mark, which other programs
+	// may use
+	addr := &net.UDPAddr{IP: net.ParseIP(peer), Port: 9}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: addr.IP})
+	require.NoError(t, err)
+	rc, err := conn.SyscallConn()
+	require.NoError(t, err)
+	var sockErr error
+	require.NoError(t, rc.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, 0x80000000)
+	}))
+	require.NoError(t, sockErr)
+	const sent = 5
+	for i := 0; i < sent; i++ {
+		_, err := conn.WriteToUDP([]byte("bittwister"), addr)
+		require.NoError(t, err)
+	}
+	require.NoError(t, conn.Close())
+
+	stats := func(handler http.HandlerFunc, path string) xdp.Stats {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var stats []api.ServiceStats
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&stats))
+		require.Len(t, stats, 1)
+		return stats[0].Egress
+	}
+
+	assert.EqualValues(t, sent, stats(s.restAPI.DuplicateStats, api.DuplicatePath.Stats()).PacketsModified)
+	// the copies do not go through the packet loss service again
+	assert.EqualValues(t, sent, stats(s.restAPI.PacketlossStats, api.PacketlossPath.Stats()).PacketsSeen)
+}
+
+func (s *APITestSuite) getDefaultDuplicateStartRequest() api.DuplicateStartRequest {
+	return api.DuplicateStartRequest{
+		NetworkInterfaceName: s.ifaceName,
+		DuplicateRate:        10,
+	}
+}
@@ -19,8 +19,11 @@ const (
 	SlugServiceReady          = "service-ready"
 	SlugServiceNotReady       = "service-not-ready"
 	SlugServiceSetParamFailed = "service-set-param-failed"
+	SlugServiceStatsFailed    = "service-stats-failed"
 	SlugJSONDecodeFailed      = "json-decode-failed"
+	SlugScenarioInvalid       = "scenario-invalid"
 	SlugTypeError             = "type-error"
+	SlugUnauthorized          = "unauthorized"
 )
 
 type MetaMessage struct {
@@ -2,13 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// LatencyStart implements POST /latency/start
+// LatencyStart implements POST /latency/start and POST /latency/{iface}/start
 func (a *RESTApiV1) LatencyStart(resp http.ResponseWriter, req *http.Request) {
 	var body LatencyStartRequest
 	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
@@ -23,41 +24,128 @@ func (a *RESTApiV1) LatencyStart(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if !ensureServiceInitialized(resp, a.lt) {
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	err := netServiceStart(resp, a.lt, ifaceName, body)
+	if err != nil {
+		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	}
+}
+
+// LatencyStop implements POST /latency/stop and POST /latency/{iface}/stop
+//
+// Without a network interface in the path, it stops the service on all interfaces.
+func (a *RESTApiV1) LatencyStop(resp http.ResponseWriter, req *http.Request) {
+	if !ensureServiceGroupInitialized(resp, a.lt) {
 		return
 	}
 
-	err := a.lt.SetLatencyParams(
-		time.Duration(body.Latency)*time.Millisecond,
-		time.Duration(body.Jitter)*time.Millisecond)
-	if err != nil {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStop(resp, a.lt.ReadyInstances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+	}
+}
+
+// LatencyUpdate implements PUT /latency/params and PUT /latency/{iface}/params
+//
+// It changes the parameters of the running service without stopping it.
+// Without a network interface, it updates the service on all interfaces.
+func (a *RESTApiV1) LatencyUpdate(resp http.ResponseWriter, req *http.Request) {
+	var body LatencyUpdateRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
 		sendJSONError(resp,
 			MetaMessage{
 				Type:    APIMetaMessageTypeError,
-				Slug:    SlugServiceSetParamFailed,
-				Title:   "Service set param failed",
+				Slug:    SlugJSONDecodeFailed,
+				Title:   "JSON decode failed",
 				Message: err.Error(),
 			},
-			http.StatusInternalServerError)
+			http.StatusBadRequest)
 		return
 	}
 
-	err = netServiceStart(resp, a.lt, body.NetworkInterfaceName)
-	if err != nil {
-		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	if err := netServiceUpdate(resp, a.lt, ifaceName, body); err != nil {
+		a.loggerNoStack.Error("netServiceUpdate failed", zap.Error(err))
 	}
 }
 
-// LatencyStop implements POST /latency/stop
-func (a *RESTApiV1) LatencyStop(resp http.ResponseWriter, req *http.Request) {
-	if err := netServiceStop(resp, a.lt); err != nil {
-		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+// LatencyStatus implements GET /latency/status and GET /latency/{iface}/status
+//
+// Without a network interface in the path, the service is reported ready
+// if it is running on any interface.
+func (a *RESTApiV1) LatencyStatus(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStatus(resp, a.lt, a.lt.Instances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
 	}
 }
 
-// LatencyStatus implements GET /latency/status
-func (a *RESTApiV1) LatencyStatus(resp http.ResponseWriter, _ *http.Request) {
-	if err := netServiceStatus(resp, a.lt); err != nil {
-		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
+// LatencyStats implements GET /latency/stats and GET /latency/{iface}/stats
+//
+// Without a network interface in the path, the counters of the service on
+// all the interfaces it runs on are returned.
+func (a *RESTApiV1) LatencyStats(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStats(resp, a.lt, ifaceName); err != nil {
+		a.loggerNoStack.Error("netServiceStats failed", zap.Error(err))
+	}
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r LatencyStartRequest) apply(ns *netRestrictService) error {
+	delay, jitter, err := latencyDurations(r.Latency, r.LatencyDuration, r.Jitter, r.JitterDuration)
+	if err == nil {
+		err = ns.SetLatencyParams(delay, jitter, r.Engine)
+	}
+	if err == nil {
+		err = ns.SetLatencyDistribution(r.Distribution, r.Correlation)
+	}
+	if err == nil {
+		err = ns.SetPeers(r.Peers)
+	}
+	if err == nil {
+		err = ns.SetProtocolFilter(r.Protocol, r.Ports)
+	}
+	return err
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r LatencyUpdateRequest) apply(ns *netRestrictService) error {
+	delay, jitter, err := latencyDurations(r.Latency, r.LatencyDuration, r.Jitter, r.JitterDuration)
+	if err == nil {
+		err = ns.SetLatencyParams(delay, jitter, "")
+	}
+	if err == nil {
+		err = ns.SetLatencyDistribution(r.Distribution, r.Correlation)
+	}
+	return err
+}
+
+// latencyDurations returns the latency and jitter of a request, each given
+// either in milliseconds or as a duration string for a finer resolution.
+func latencyDurations(latencyMs int64, latency string, jitterMs int64, jitter string) (time.Duration, time.Duration, error) {
+	l, err := requestMsDuration("latency", latencyMs, latency)
+	if err != nil {
+		return 0, 0, err
+	}
+	j, err := requestMsDuration("jitter", jitterMs, jitter)
+	if err != nil {
+		return 0, 0, err
+	}
+	return l, j, nil
+}
+
+func requestMsDuration(name string, ms int64, s string) (time.Duration, error) {
+	if s == "" {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	if ms != 0 {
+		return 0, fmt.Errorf("%s and %s_ms are exclusive", name, name)
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
 	}
+	return d, nil
 }
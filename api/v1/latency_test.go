@@ -3,8 +3,10 @@ package api_test
 import (
 	"bytes"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	api "github.com/celestiaorg/bittwister/api/v1"
 	"github.com/stretchr/testify/assert"
@@ -70,6 +72,159 @@ func (s *APITestSuite) TestLatencyStatus() {
 	assert.Equal(t, api.SlugServiceNotReady, slug)
 }
 
+func (s *APITestSuite) TestLatencyStartUnknownEngine() {
+	t := s.T()
+
+	body := s.getDefaultLatencyStartRequest()
+	body.Engine = "carrier-pigeon"
+	jsonBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.LatencyPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.LatencyStart(rr, req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	var msg api.MetaMessage
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+	assert.Equal(t, api.SlugServiceStartFailed, msg.Slug)
+
+	slug, err := getServiceStatusSlug(s.restAPI.LatencyStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+}
+
+func (s *APITestSuite) TestLatencyStartInvalidDistribution() {
+	t := s.T()
+
+	tests := []struct {
+		name         string
+		distribution string
+		correlation  float64
+		jitter       int64
+	}{
+		{name: "unknown distribution", distribution: "gaussian", jitter: 50},
+		{name: "correlation above 100", correlation: 150, jitter: 50},
+		{name: "distribution without jitter", distribution: "normal"},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultLatencyStartRequest()
+		body.Distribution = tt.distribution
+		body.Correlation = tt.correlation
+		body.Jitter = tt.jitter
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.LatencyPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.LatencyStart(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, tt.name)
+
+		var msg api.MetaMessage
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+		assert.Equal(t, api.SlugServiceStartFailed, msg.Slug, tt.name)
+	}
+}
+
+func (s *APITestSuite) TestLatencyInvalidDuration() {
+	t := s.T()
+
+	tests := []struct {
+		name string
+		body api.LatencyStartRequest
+	}{
+		{name: "latency_ms and latency", body: api.LatencyStartRequest{Latency: 100, LatencyDuration: "150us"}},
+		{name: "not a duration", body: api.LatencyStartRequest{LatencyDuration: "fast"}},
+		{name: "jitter without unit", body: api.LatencyStartRequest{LatencyDuration: "150us", JitterDuration: "20"}},
+	}
+
+	for _, tt := range tests {
+		body := tt.body
+		body.NetworkInterfaceName = s.ifaceName
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.LatencyPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.LatencyStart(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, tt.name)
+
+		var msg api.MetaMessage
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+		assert.Equal(t, api.SlugServiceSetParamFailed, msg.Slug, tt.name)
+	}
+}
+
+func (s *APITestSuite) TestLatencyTcWithPacketloss() {
+	t := s.T()
+
+	// Count the UDP packets of a single loopback address with an egress
+	// packet loss service which hardly drops any, while netem delays them.
+	const peer = "127.0.0.44"
+	plBody := s.getDefaultPacketLossStartRequest()
+	plBody.PacketLossRate = 0.0001
+	plBody.Direction = "egress"
+	plBody.Peers = []string{peer}
+	plBody.Protocol = "udp"
+	jsonBody, err := json.Marshal(plBody)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	defer s.restAPI.PacketlossStop(httptest.NewRecorder(), nil)
+
+	const latency = 50 * time.Millisecond
+	body := s.getDefaultLatencyStartRequest()
+	body.Latency, body.Jitter = latency.Milliseconds(), 0
+	jsonBody, err = json.Marshal(body)
+	require.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, api.LatencyPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	s.restAPI.LatencyStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	defer s.restAPI.LatencyStop(httptest.NewRecorder(), nil)
+
+	recv, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(peer)})
+	require.NoError(t, err)
+	defer recv.Close()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(peer)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	const sent = 5
+	buf := make([]byte, 16)
+	require.NoError(t, recv.SetReadDeadline(time.Now().Add(10*latency)))
+	for i := 0; i < sent; i++ {
+		start := time.Now()
+		_, err := conn.WriteToUDP([]byte("bittwister"), recv.LocalAddr().(*net.UDPAddr))
+		require.NoError(t, err)
+		_, _, err = recv.ReadFromUDP(buf)
+		require.NoError(t, err, "the packets go through the netem qdisc")
+		assert.GreaterOrEqual(t, time.Since(start), latency)
+	}
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStats(rr, httptest.NewRequest(http.MethodGet, api.PacketlossPath.Stats(), nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var stats []api.ServiceStats
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&stats))
+	require.Len(t, stats, 1)
+	// the packets sent back by the ifb device do not go through the packet
+	// loss service again
+	assert.EqualValues(t, sent, stats[0].Egress.PacketsSeen)
+	assert.EqualValues(t, sent, stats[0].Egress.PacketsPassed)
+}
+
 func (s *APITestSuite) getDefaultLatencyStartRequest() api.LatencyStartRequest {
 	return api.LatencyStartRequest{
 		NetworkInterfaceName: s.ifaceName,
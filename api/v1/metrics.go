@@ -0,0 +1,226 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsPath is where the Prometheus metrics are served, outside of the
+// versioned API.
+const MetricsPath = "/metrics"
+
+const metricsNamespace = "bittwister"
+
+var (
+	serviceUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "service", "up"),
+		"Whether the service runs on the network interface.",
+		[]string{"service", "network_interface"}, nil)
+	serviceParamDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "service", "param"),
+		"Current numeric parameter of a running service, as listed by /services/status.",
+		[]string{"service", "network_interface", "param"}, nil)
+	serviceStartsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "service", "starts_total"),
+		"Number of times the service was started, on any network interface.",
+		[]string{"service"}, nil)
+	serviceStopsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "service", "stops_total"),
+		"Number of times the service was stopped, on any network interface.",
+		[]string{"service"}, nil)
+	servicePacketsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "service", "packets_total"),
+		"Packets the running service applied to since it was started, by outcome.",
+		[]string{"service", "network_interface", "direction", "outcome"}, nil)
+	serviceBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "service", "bytes_total"),
+		"Bytes the running service applied to since it was started, by outcome.",
+		[]string{"service", "network_interface", "direction", "outcome"}, nil)
+)
+
+// servicesCollector reads the state of the services when the metrics are
+// scraped, so they are never out of date.
+type servicesCollector struct {
+	groups []*netServiceGroup
+}
+
+var _ prometheus.Collector = (*servicesCollector)(nil)
+
+func (c *servicesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serviceUpDesc
+	ch <- serviceParamDesc
+	ch <- serviceStartsDesc
+	ch <- serviceStopsDesc
+	ch <- servicePacketsDesc
+	ch <- serviceBytesDesc
+}
+
+func (c *servicesCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, g := range c.groups {
+		ch <- prometheus.MustNewConstMetric(serviceStartsDesc, prometheus.CounterValue, float64(g.starts.Load()), g.name)
+		ch <- prometheus.MustNewConstMetric(serviceStopsDesc, prometheus.CounterValue, float64(g.stops.Load()), g.name)
+
+		for _, ns := range g.Instances("") {
+			st, err := netServiceParams(ns)
+			if err != nil {
+				ch <- prometheus.NewInvalidMetric(serviceUpDesc, err)
+				continue
+			}
+			if st.NetworkInterfaceName == "" {
+				continue // never started
+			}
+
+			up := 0.0
+			if st.Ready {
+				up = 1
+			}
+			ch <- prometheus.MustNewConstMetric(serviceUpDesc, prometheus.GaugeValue, up, g.name, st.NetworkInterfaceName)
+			if !st.Ready {
+				continue
+			}
+
+			for param, v := range st.Params {
+				if f, ok := paramValue(v); ok {
+					ch <- prometheus.MustNewConstMetric(serviceParamDesc, prometheus.GaugeValue, f, g.name, st.NetworkInterfaceName, param)
+				}
+			}
+
+			stats, err := ns.Stats()
+			if errors.Is(err, xdp.ErrStatsUnavailable) || errors.Is(err, ErrServiceNotStarted) {
+				continue
+			}
+			if err != nil {
+				ch <- prometheus.NewInvalidMetric(servicePacketsDesc, err)
+				continue
+			}
+			collectStats(ch, g.name, st.NetworkInterfaceName, string(xdp.DirectionIngress), stats.Ingress)
+			collectStats(ch, g.name, st.NetworkInterfaceName, string(xdp.DirectionEgress), stats.Egress)
+		}
+	}
+}
+
+func collectStats(ch chan<- prometheus.Metric, service, ifaceName, direction string, s xdp.Stats) {
+	outcomes := []struct {
+		name           string
+		packets, bytes uint64
+	}{
+		{"seen", s.PacketsSeen, s.BytesSeen},
+		{"passed", s.PacketsPassed, s.BytesPassed},
+		{"dropped", s.PacketsDropped, s.BytesDropped},
+		{"delayed", s.PacketsDelayed, s.BytesDelayed},
+		{"modified", s.PacketsModified, s.BytesModified},
+	}
+	for _, o := range outcomes {
+		ch <- prometheus.MustNewConstMetric(servicePacketsDesc, prometheus.CounterValue, float64(o.packets), service, ifaceName, direction, o.name)
+		ch <- prometheus.MustNewConstMetric(serviceBytesDesc, prometheus.CounterValue, float64(o.bytes), service, ifaceName, direction, o.name)
+	}
+}
+
+// paramValue returns the value of a numeric or boolean service parameter.
+func paramValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// initMetrics registers the metrics of the API server, with the Go runtime
+// and process metrics, in a registry of its own.
+func (a *RESTApiV1) initMetrics() {
+	a.requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the API requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path", "code"})
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		a.requestDuration,
+		&servicesCollector{groups: []*netServiceGroup{a.pl, a.bw, a.lt, a.dp, a.ro, a.cr, a.pt}},
+	)
+
+	// a broken collector must not hide the other metrics
+	a.metricsHandler = promhttp.HandlerFor(reg, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// Metrics implements GET /metrics in the Prometheus exposition format.
+func (a *RESTApiV1) Metrics(resp http.ResponseWriter, req *http.Request) {
+	a.metricsHandler.ServeHTTP(resp, req)
+}
+
+// ServeMetrics serves the metrics alone on a separate address, e.g. to keep
+// them on a port only reachable by Prometheus. They stay available on the
+// address of Serve too.
+func (a *RESTApiV1) ServeMetrics(addr string) error {
+	handler := http.NewServeMux()
+	handler.HandleFunc(MetricsPath, a.Metrics)
+
+	a.metricsServer = &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
+	return a.metricsServer.ListenAndServe()
+}
+
+// instrument is a middleware observing the duration of the API requests, by
+// route so that the network interfaces in the paths don't add labels.
+func (a *RESTApiV1) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		path := req.URL.Path
+		if route := mux.CurrentRoute(req); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				path = tpl
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: resp, code: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, req)
+
+		a.requestDuration.WithLabelValues(req.Method, path, strconv.Itoa(rec.code)).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,47 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *APITestSuite) TestMetrics() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(s.getDefaultPacketLossStartRequest())
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	metrics := s.scrapeMetrics()
+	assert.Contains(t, metrics, `bittwister_service_up{network_interface="`+s.ifaceName+`",service="packetloss"} 1`)
+	assert.Contains(t, metrics, `bittwister_service_param{network_interface="`+s.ifaceName+`",param="packet_loss_rate",service="packetloss"} 10`)
+	assert.Contains(t, metrics, `bittwister_service_packets_total{direction="ingress",network_interface="`+s.ifaceName+`",outcome="dropped",service="packetloss"}`)
+	assert.Contains(t, metrics, `bittwister_service_starts_total{service="packetloss"}`)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	metrics = s.scrapeMetrics()
+	assert.Contains(t, metrics, `bittwister_service_up{network_interface="`+s.ifaceName+`",service="packetloss"} 0`)
+	assert.NotContains(t, metrics, `bittwister_service_param{network_interface="`+s.ifaceName+`",param="packet_loss_rate",service="packetloss"}`)
+}
+
+func (s *APITestSuite) scrapeMetrics() string {
+	rr := httptest.NewRecorder()
+	s.restAPI.Metrics(rr, httptest.NewRequest(http.MethodGet, api.MetricsPath, nil))
+	require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+	return rr.Body.String()
+}
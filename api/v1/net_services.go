@@ -3,42 +3,202 @@ package api
 import (
 	"fmt"
 	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/celestiaorg/bittwister/xdp"
 	"github.com/celestiaorg/bittwister/xdp/bandwidth"
+	"github.com/celestiaorg/bittwister/xdp/corrupt"
+	"github.com/celestiaorg/bittwister/xdp/duplicate"
 	"github.com/celestiaorg/bittwister/xdp/latency"
 	"github.com/celestiaorg/bittwister/xdp/packetloss"
+	"github.com/celestiaorg/bittwister/xdp/partition"
+	"github.com/celestiaorg/bittwister/xdp/reorder"
 )
 
 const ServiceStopTimeout = 5 // Seconds
 
 type netRestrictService struct {
-	service xdp.XdpLoader
-	cancel  xdp.CancelFunc
-	ready   bool
+	group *netServiceGroup // nil if the instance is not kept by a group
+
+	// mu guards the fields below, the instance is started, stopped and read
+	// concurrently by the HTTP handlers, the scheduler and the metrics
+	// collector. It also serializes the calls to the service, which is not
+	// safe for concurrent use, see xdp.StatsReader.
+	mu                   sync.Mutex
+	service              xdp.XdpLoader
+	cancel               xdp.CancelFunc
+	ready                bool
+	networkInterfaceName string // set by Start
+}
+
+// netServiceGroup keeps the instances of one kind of service, one instance
+// per network interface.
+type netServiceGroup struct {
+	name       string
+	newService func() xdp.XdpLoader
+	services   map[string]*netRestrictService // key: network interface name
+	mu         sync.Mutex
+
+	// the number of times an instance was started and stopped, see metrics
+	starts, stops atomic.Uint64
+}
+
+func newNetServiceGroup(name string, newService func() xdp.XdpLoader) *netServiceGroup {
+	return &netServiceGroup{
+		name:       name,
+		newService: newService,
+		services:   make(map[string]*netRestrictService),
+	}
+}
+
+// Get returns the service instance of the given network interface and
+// creates it if the interface exists but has no instance yet.
+func (g *netServiceGroup) Get(networkInterfaceName string) (*netRestrictService, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if ns, ok := g.services[networkInterfaceName]; ok {
+		return ns, nil
+	}
+
+	if _, err := net.InterfaceByName(networkInterfaceName); err != nil {
+		return nil, fmt.Errorf("lookup network device %q: %v", networkInterfaceName, err)
+	}
+
+	ns := &netRestrictService{service: g.newService(), group: g}
+	g.services[networkInterfaceName] = ns
+	return ns, nil
+}
+
+// adopt creates the service instance of the network interface from the
+// pinned maps, if a previous process left the service running there. It
+// reports whether the instance was adopted.
+func (g *netServiceGroup) adopt(networkInterfaceName string) (bool, error) {
+	ns := &netRestrictService{service: g.newService(), group: g}
+	a, ok := ns.service.(xdp.Adopter)
+	if !ok {
+		return false, nil
+	}
+	if err := ns.SetNetworkInterface(networkInterfaceName); err != nil {
+		return false, fmt.Errorf("set network interface: %w", err)
+	}
+
+	var (
+		adopted bool
+		err     error
+	)
+	ns.cancel, adopted, err = a.Adopt()
+	if err != nil || !adopted {
+		return false, err
+	}
+	ns.ready = true
+	ns.networkInterfaceName = networkInterfaceName
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.services[networkInterfaceName] = ns
+	return true, nil
 }
 
-func (n *netRestrictService) Start(networkInterfaceName string) error {
-	if n.service == nil {
+// Instances returns the service instances sorted by network interface name.
+// If networkInterfaceName is not empty, only the instance of that interface
+// is returned, if there is one.
+func (g *netServiceGroup) Instances(networkInterfaceName string) []*netRestrictService {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if networkInterfaceName != "" {
+		if ns, ok := g.services[networkInterfaceName]; ok {
+			return []*netRestrictService{ns}
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(g.services))
+	for name := range g.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]*netRestrictService, 0, len(names))
+	for _, name := range names {
+		out = append(out, g.services[name])
+	}
+	return out
+}
+
+// ReadyInstances is like Instances but returns only the running instances.
+func (g *netServiceGroup) ReadyInstances(networkInterfaceName string) []*netRestrictService {
+	out := []*netRestrictService{}
+	for _, ns := range g.Instances(networkInterfaceName) {
+		if ns.Ready() {
+			out = append(out, ns)
+		}
+	}
+	return out
+}
+
+// Start starts the service on the network interface, it then replaces the
+// service of the instance. The instance is left as is if the service fails
+// to start.
+func (n *netRestrictService) Start(networkInterfaceName string, service xdp.XdpLoader) error {
+	if service == nil {
 		return ErrServiceNotInitialized
 	}
 
-	if err := n.SetNetworkInterface(networkInterfaceName); err != nil {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.ready {
+		return ErrServiceAlreadyStarted
+	}
+
+	if err := setNetworkInterface(service, networkInterfaceName); err != nil {
 		return fmt.Errorf("set network interface: %w", err)
 	}
 
-	var err error
-	n.cancel, err = n.service.Start()
+	cancel, err := service.Start()
 	if err != nil {
 		return fmt.Errorf("start service: %w", err)
 	}
+	n.service = service
+	n.cancel = cancel
 	n.ready = true
+	n.networkInterfaceName = networkInterfaceName
+	if n.group != nil {
+		n.group.starts.Add(1)
+	}
 
 	return nil
 }
 
 func (n *netRestrictService) Stop() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.stop()
+}
+
+// StopService stops the instance if it still runs the given service, it is
+// left as is if the service was stopped and another one started since.
+func (n *netRestrictService) StopService(service xdp.XdpLoader) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.service != service || n.cancel == nil {
+		return nil
+	}
+	return n.stop()
+}
+
+func (n *netRestrictService) stop() error {
 	if n.cancel == nil {
 		return ErrServiceNotStarted
 	}
@@ -46,30 +206,151 @@ func (n *netRestrictService) Stop() error {
 	if err := n.cancel(); err != nil {
 		return fmt.Errorf("stop service: %w", err)
 	}
+	n.cancel = nil
 	n.ready = false
+	if n.group != nil {
+		n.group.stops.Add(1)
+	}
+	return nil
+}
+
+// Ready reports whether the service of the instance runs.
+func (n *netRestrictService) Ready() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.ready
+}
+
+func (n *netRestrictService) initialized() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.service != nil
+}
+
+// NetworkInterfaceName returns the network interface the instance was last
+// started on.
+func (n *netRestrictService) NetworkInterfaceName() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.networkInterfaceName
+}
+
+// Update applies the impairment parameters of next, a service of the same
+// type, to the running service. It returns a copy of the service with the
+// previous parameters, which undoes the update when given to Update.
+func (n *netRestrictService) Update(next xdp.XdpLoader) (xdp.XdpLoader, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.ready {
+		return nil, ErrServiceNotStarted
+	}
+
+	u, ok := n.service.(xdp.Updater)
+	if !ok {
+		return nil, fmt.Errorf("%T cannot be updated while it runs", n.service)
+	}
+	prev := copyService(n.service)
+	if err := u.Update(next); err != nil {
+		return nil, fmt.Errorf("update service: %w", err)
+	}
+	return prev, nil
+}
+
+// copyService returns a copy of the service, which keeps its parameters, or
+// nil if the service cannot be updated.
+func copyService(service xdp.XdpLoader) xdp.XdpLoader {
+	switch s := service.(type) {
+	case *packetloss.PacketLoss:
+		c := *s
+		return &c
+	case *bandwidth.Bandwidth:
+		c := *s
+		return &c
+	case *latency.Latency:
+		c := *s
+		return &c
+	case *duplicate.Duplicate:
+		c := *s
+		return &c
+	case *reorder.Reorder:
+		c := *s
+		return &c
+	case *corrupt.Corrupt:
+		c := *s
+		return &c
+	}
 	return nil
 }
 
-func (n *netRestrictService) SetBandwidthLimit(limit int64) error {
+// Stats returns the packet counters of the running service.
+func (n *netRestrictService) Stats() (xdp.ServiceStats, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.ready {
+		return xdp.ServiceStats{}, ErrServiceNotStarted
+	}
+
+	r, ok := n.service.(xdp.StatsReader)
+	if !ok {
+		return xdp.ServiceStats{}, fmt.Errorf("%T does not count packets", n.service)
+	}
+	return r.Stats()
+}
+
+func (n *netRestrictService) SetBandwidthLimit(limit, burst int64) error {
 	if s, ok := n.service.(*bandwidth.Bandwidth); ok {
 		s.Limit = limit
+		s.Burst = burst
+		return nil
+	}
+
+	return fmt.Errorf("could not cast netRestrictService.service to *bandwidth.Bandwidth")
+}
+
+// SetBandwidthDirectionLimits gives each direction its own limit, instead
+// of the one of SetBandwidthLimit.
+func (n *netRestrictService) SetBandwidthDirectionLimits(ingress, egress int64) error {
+	if s, ok := n.service.(*bandwidth.Bandwidth); ok {
+		s.LimitIngress = ingress
+		s.LimitEgress = egress
 		return nil
 	}
 
 	return fmt.Errorf("could not cast netRestrictService.service to *bandwidth.Bandwidth")
 }
 
-func (n *netRestrictService) SetLatencyParams(delay, jitter time.Duration) error {
+func (n *netRestrictService) SetLatencyParams(delay, jitter time.Duration, engine string) error {
 	if s, ok := n.service.(*latency.Latency); ok {
 		s.Latency = delay
 		s.Jitter = jitter
+		s.Engine = engine
 		return nil
 	}
 
 	return fmt.Errorf("could not cast netRestrictService.service to *latency.Latency")
 }
 
-func (n *netRestrictService) SetPacketLossRate(rate int32) error {
+// SetLatencyDistribution shapes the jitter of SetLatencyParams.
+func (n *netRestrictService) SetLatencyDistribution(distribution string, correlation float64) error {
+	if s, ok := n.service.(*latency.Latency); ok {
+		s.Distribution = distribution
+		s.Correlation = correlation
+		return nil
+	}
+
+	return fmt.Errorf("could not cast netRestrictService.service to *latency.Latency")
+}
+
+func (n *netRestrictService) SetPacketLossRate(rate float64) error {
+	if err := packetloss.ValidateRate(rate); err != nil {
+		return err
+	}
+
 	if s, ok := n.service.(*packetloss.PacketLoss); ok {
 		s.PacketLossRate = rate
 		return nil
@@ -78,20 +359,173 @@ func (n *netRestrictService) SetPacketLossRate(rate int32) error {
 	return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss")
 }
 
+// SetPacketLossDirectionRates gives each direction its own loss rate,
+// instead of the one of SetPacketLossRate.
+func (n *netRestrictService) SetPacketLossDirectionRates(ingress, egress float64) error {
+	for _, rate := range []float64{ingress, egress} {
+		if err := packetloss.ValidateRate(rate); err != nil {
+			return err
+		}
+	}
+
+	if s, ok := n.service.(*packetloss.PacketLoss); ok {
+		s.PacketLossRateIngress = ingress
+		s.PacketLossRateEgress = egress
+		return nil
+	}
+
+	return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss")
+}
+
+func (n *netRestrictService) SetDuplicateRate(rate float64) error {
+	if err := duplicate.ValidateRate(rate); err != nil {
+		return err
+	}
+
+	if s, ok := n.service.(*duplicate.Duplicate); ok {
+		s.DuplicateRate = rate
+		return nil
+	}
+
+	return fmt.Errorf("could not cast netRestrictService.service to *duplicate.Duplicate")
+}
+
+func (n *netRestrictService) SetReorderParams(rate float64, gap uint32, delay time.Duration) error {
+	if err := reorder.ValidateRate(rate); err != nil {
+		return err
+	}
+
+	if s, ok := n.service.(*reorder.Reorder); ok {
+		s.ReorderRate = rate
+		s.Gap = gap
+		s.Delay = delay
+		return nil
+	}
+
+	return fmt.Errorf("could not cast netRestrictService.service to *reorder.Reorder")
+}
+
+func (n *netRestrictService) SetCorruptParams(rate float64, payloadOnly, fixChecksum bool) error {
+	if err := corrupt.ValidateRate(rate); err != nil {
+		return err
+	}
+
+	if s, ok := n.service.(*corrupt.Corrupt); ok {
+		s.CorruptRate = rate
+		s.PayloadOnly = payloadOnly
+		s.FixChecksum = fixChecksum
+		return nil
+	}
+
+	return fmt.Errorf("could not cast netRestrictService.service to *corrupt.Corrupt")
+}
+
+func (n *netRestrictService) SetLossModel(model string, ge packetloss.GilbertElliott) error {
+	if s, ok := n.service.(*packetloss.PacketLoss); ok {
+		s.Model = model
+		s.GilbertElliott = ge
+		return nil
+	}
+
+	return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss")
+}
+
+func (n *netRestrictService) SetDirection(direction xdp.Direction) error {
+	if s, ok := n.service.(*packetloss.PacketLoss); ok {
+		s.Direction = direction
+	} else if s, ok := n.service.(*bandwidth.Bandwidth); ok {
+		s.Direction = direction
+	} else {
+		return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss or *bandwidth.Bandwidth")
+	}
+	return nil
+}
+
+// SetPeers scopes the service to the given peers in CIDR notation,
+// an empty list applies the service to all the traffic.
+func (n *netRestrictService) SetPeers(cidrs []string) error {
+	peers, err := xdp.ParsePeers(cidrs)
+	if err != nil {
+		return err
+	}
+
+	if s, ok := n.service.(*packetloss.PacketLoss); ok {
+		s.Peers = peers
+	} else if s, ok := n.service.(*bandwidth.Bandwidth); ok {
+		s.Peers = peers
+	} else if s, ok := n.service.(*latency.Latency); ok {
+		s.Peers = peers
+	} else if s, ok := n.service.(*duplicate.Duplicate); ok {
+		s.Peers = peers
+	} else if s, ok := n.service.(*reorder.Reorder); ok {
+		s.Peers = peers
+	} else if s, ok := n.service.(*corrupt.Corrupt); ok {
+		s.Peers = peers
+	} else if s, ok := n.service.(*partition.Partition); ok {
+		s.Peers = peers
+	} else {
+		return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss, *bandwidth.Bandwidth, *latency.Latency, *duplicate.Duplicate, *reorder.Reorder, *corrupt.Corrupt or *partition.Partition")
+	}
+	return nil
+}
+
+// SetProtocolFilter scopes the service to a transport protocol (tcp, udp or
+// icmp) and a port range (e.g. 26656 or 26656-26660), empty values apply
+// the service to all the protocols and ports.
+func (n *netRestrictService) SetProtocolFilter(protocol, ports string) error {
+	portRange, err := xdp.ParsePortRange(ports)
+	if err != nil {
+		return err
+	}
+	p := xdp.Protocol(protocol)
+	if _, err := xdp.NewProtocolFilter(p, portRange); err != nil {
+		return err
+	}
+
+	if s, ok := n.service.(*packetloss.PacketLoss); ok {
+		s.Protocol, s.Ports = p, portRange
+	} else if s, ok := n.service.(*bandwidth.Bandwidth); ok {
+		s.Protocol, s.Ports = p, portRange
+	} else if s, ok := n.service.(*latency.Latency); ok {
+		s.Protocol, s.Ports = p, portRange
+	} else if s, ok := n.service.(*duplicate.Duplicate); ok {
+		s.Protocol, s.Ports = p, portRange
+	} else if s, ok := n.service.(*reorder.Reorder); ok {
+		s.Protocol, s.Ports = p, portRange
+	} else if s, ok := n.service.(*corrupt.Corrupt); ok {
+		s.Protocol, s.Ports = p, portRange
+	} else {
+		return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss, *bandwidth.Bandwidth, *latency.Latency, *duplicate.Duplicate, *reorder.Reorder or *corrupt.Corrupt")
+	}
+	return nil
+}
+
 func (n *netRestrictService) SetNetworkInterface(networkInterfaceName string) error {
+	return setNetworkInterface(n.service, networkInterfaceName)
+}
+
+func setNetworkInterface(service xdp.XdpLoader, networkInterfaceName string) error {
 	iface, err := net.InterfaceByName(networkInterfaceName)
 	if err != nil {
 		return fmt.Errorf("lookup network device %q: %v", networkInterfaceName, err)
 	}
 
-	if s, ok := n.service.(*packetloss.PacketLoss); ok {
+	if s, ok := service.(*packetloss.PacketLoss); ok {
 		s.NetworkInterface = iface
-	} else if s, ok := n.service.(*bandwidth.Bandwidth); ok {
+	} else if s, ok := service.(*bandwidth.Bandwidth); ok {
 		s.NetworkInterface = iface
-	} else if s, ok := n.service.(*latency.Latency); ok {
+	} else if s, ok := service.(*latency.Latency); ok {
+		s.NetworkInterface = iface
+	} else if s, ok := service.(*duplicate.Duplicate); ok {
+		s.NetworkInterface = iface
+	} else if s, ok := service.(*reorder.Reorder); ok {
+		s.NetworkInterface = iface
+	} else if s, ok := service.(*corrupt.Corrupt); ok {
+		s.NetworkInterface = iface
+	} else if s, ok := service.(*partition.Partition); ok {
 		s.NetworkInterface = iface
 	} else {
-		return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss, *bandwidth.Bandwidth or *latency.Latency")
+		return fmt.Errorf("could not cast netRestrictService.service to *packetloss.PacketLoss, *bandwidth.Bandwidth, *latency.Latency, *duplicate.Duplicate, *reorder.Reorder, *corrupt.Corrupt or *partition.Partition")
 	}
 	return nil
 }
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/celestiaorg/bittwister/xdp"
+)
+
+// ServiceStats are the counters of the packets a running service instance
+// applied to since it was started, in each direction.
+type ServiceStats struct {
+	Name                 string `json:"name"`
+	NetworkInterfaceName string `json:"network_interface_name"`
+	xdp.ServiceStats
+}
+
+// netServiceStats sends the packet counters of the running service instances
+// of the given network interface, or of all of them if it is empty.
+func netServiceStats(resp http.ResponseWriter, g *netServiceGroup, ifaceName string) error {
+	if !ensureServiceGroupInitialized(resp, g) {
+		return ErrServiceNotInitialized
+	}
+
+	nss := g.ReadyInstances(ifaceName)
+	out := make([]ServiceStats, 0, len(nss))
+	for _, ns := range nss {
+		stats, err := ns.Stats()
+		if errors.Is(err, ErrServiceNotStarted) {
+			continue // stopped meanwhile
+		}
+		if err != nil {
+			sendJSONError(resp,
+				MetaMessage{
+					Type:    APIMetaMessageTypeError,
+					Slug:    SlugServiceStatsFailed,
+					Title:   "Service stats failed",
+					Message: err.Error(),
+				},
+				http.StatusInternalServerError)
+			return err
+		}
+
+		out = append(out, ServiceStats{
+			Name:                 g.name,
+			NetworkInterfaceName: ns.NetworkInterfaceName(),
+			ServiceStats:         stats,
+		})
+	}
+
+	if len(out) == 0 {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceNotStarted,
+				Title:   "Service stats failed",
+				Message: "The packets are only counted while the service runs.",
+			},
+			http.StatusBadRequest)
+		return ErrServiceNotStarted
+	}
+
+	if err := sendJSON(resp, out); err != nil {
+		return fmt.Errorf("sendJSON failed: %w", err)
+	}
+	return nil
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"errors"
+	"net"
 	"net/http"
 
 	"github.com/celestiaorg/bittwister/xdp/bandwidth"
+	"github.com/celestiaorg/bittwister/xdp/corrupt"
+	"github.com/celestiaorg/bittwister/xdp/duplicate"
 	"github.com/celestiaorg/bittwister/xdp/latency"
 	"github.com/celestiaorg/bittwister/xdp/packetloss"
+	"github.com/celestiaorg/bittwister/xdp/partition"
+	"github.com/celestiaorg/bittwister/xdp/reorder"
 	"go.uber.org/zap"
 )
 
@@ -17,58 +23,47 @@ type ServiceStatus struct {
 }
 
 // NetServicesStatus implements GET /services/status
+//
+// It lists every service instance, one per network interface it was started on,
+// and the current phase of the last schedule started, if any.
 func (a *RESTApiV1) NetServicesStatus(resp http.ResponseWriter, req *http.Request) {
-	out := make([]ServiceStatus, 0, 3)
-	for _, ns := range []*netRestrictService{a.pl, a.bw, a.lt} {
-		if ns == nil {
-			continue
-		}
-
-		var (
-			params       = make(map[string]interface{})
-			name         string
-			netIfaceName string
-		)
-
-		if s, ok := ns.service.(*packetloss.PacketLoss); ok {
-			name = "packetloss"
-			params["packet_loss_rate"] = s.PacketLossRate
-			if s.NetworkInterface != nil {
-				netIfaceName = s.NetworkInterface.Name
-			}
-
-		} else if s, ok := ns.service.(*bandwidth.Bandwidth); ok {
-			name = "bandwidth"
-			params["limit"] = s.Limit
-			if s.NetworkInterface != nil {
-				netIfaceName = s.NetworkInterface.Name
-			}
-
-		} else if s, ok := ns.service.(*latency.Latency); ok {
-			name = "latency"
-			params["latency_ms"] = s.Latency.Milliseconds()
-			params["jitter_ms"] = s.Jitter.Milliseconds()
-			if s.NetworkInterface != nil {
-				netIfaceName = s.NetworkInterface.Name
-			}
+	nss := []*netRestrictService{}
+	for _, g := range []*netServiceGroup{a.pl, a.bw, a.lt, a.dp, a.ro, a.cr, a.pt} {
+		nss = append(nss, g.Instances("")...)
+	}
 
-		} else {
+	out := make([]ServiceStatus, 0, len(nss))
+	for _, ns := range nss {
+		st, err := netServiceParams(ns)
+		if err != nil {
 			sendJSONError(resp,
 				MetaMessage{
 					Type:    APIMetaMessageTypeError,
 					Slug:    SlugTypeError,
 					Title:   "Type cast error",
-					Message: "could not cast netRestrictService.service to *packetloss.PacketLoss, *bandwidth.Bandwidth or *latency.Latency",
+					Message: err.Error(),
 				},
 				http.StatusInternalServerError)
 			return
 		}
+		out = append(out, st)
+	}
 
+	if st, ok := a.scheduleStatus(); ok {
+		params := map[string]interface{}{
+			"phase":       st.Phase,
+			"phase_index": st.PhaseIndex,
+			"phases":      st.Phases,
+			"elapsed":     st.Elapsed,
+			"duration":    st.Duration,
+		}
+		if st.Error != "" {
+			params["error"] = st.Error
+		}
 		out = append(out, ServiceStatus{
-			Name:                 name,
-			Ready:                ns.ready,
-			NetworkInterfaceName: netIfaceName,
-			Params:               params,
+			Name:   "schedule",
+			Ready:  st.Running,
+			Params: params,
 		})
 	}
 
@@ -76,3 +71,128 @@ func (a *RESTApiV1) NetServicesStatus(resp http.ResponseWriter, req *http.Reques
 		a.loggerNoStack.Error("sendJSON failed", zap.Error(err))
 	}
 }
+
+// netServiceParams returns the status of the service instance with its
+// configured parameters.
+func netServiceParams(ns *netRestrictService) (ServiceStatus, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	var (
+		params       = make(map[string]interface{})
+		name         string
+		netIfaceName string
+	)
+
+	if s, ok := ns.service.(*packetloss.PacketLoss); ok {
+		name = "packetloss"
+		params["packet_loss_rate"] = s.PacketLossRate
+		params["packet_loss_rate_ingress"] = s.PacketLossRateIngress
+		params["packet_loss_rate_egress"] = s.PacketLossRateEgress
+		params["model"] = s.Model
+		if s.Model == packetloss.ModelGilbertElliott {
+			params["gilbert_elliott"] = GilbertElliottParams{
+				P:        s.GilbertElliott.P,
+				R:        s.GilbertElliott.R,
+				LossGood: s.GilbertElliott.LossGood,
+				LossBad:  s.GilbertElliott.LossBad,
+			}
+		}
+		params["direction"] = s.Direction
+		params["peers"] = peerStrings(s.Peers)
+		params["protocol"] = s.Protocol
+		params["ports"] = s.Ports.String()
+		if s.NetworkInterface != nil {
+			netIfaceName = s.NetworkInterface.Name
+		}
+
+	} else if s, ok := ns.service.(*bandwidth.Bandwidth); ok {
+		name = "bandwidth"
+		params["limit"] = s.Limit
+		params["limit_ingress"] = s.LimitIngress
+		params["limit_egress"] = s.LimitEgress
+		params["burst"] = s.Burst
+		params["direction"] = s.Direction
+		params["peers"] = peerStrings(s.Peers)
+		params["protocol"] = s.Protocol
+		params["ports"] = s.Ports.String()
+		if s.NetworkInterface != nil {
+			netIfaceName = s.NetworkInterface.Name
+		}
+
+	} else if s, ok := ns.service.(*latency.Latency); ok {
+		name = "latency"
+		params["latency_ms"] = s.Latency.Milliseconds()
+		params["jitter_ms"] = s.Jitter.Milliseconds()
+		params["latency_us"] = s.Latency.Microseconds()
+		params["jitter_us"] = s.Jitter.Microseconds()
+		params["distribution"] = s.Distribution
+		params["correlation"] = s.Correlation
+		params["engine"] = s.Engine
+		params["peers"] = peerStrings(s.Peers)
+		params["protocol"] = s.Protocol
+		params["ports"] = s.Ports.String()
+		if s.NetworkInterface != nil {
+			netIfaceName = s.NetworkInterface.Name
+		}
+
+	} else if s, ok := ns.service.(*duplicate.Duplicate); ok {
+		name = "duplicate"
+		params["duplicate_rate"] = s.DuplicateRate
+		params["peers"] = peerStrings(s.Peers)
+		params["protocol"] = s.Protocol
+		params["ports"] = s.Ports.String()
+		if s.NetworkInterface != nil {
+			netIfaceName = s.NetworkInterface.Name
+		}
+
+	} else if s, ok := ns.service.(*reorder.Reorder); ok {
+		name = "reorder"
+		params["reorder_rate"] = s.ReorderRate
+		params["gap"] = s.Gap
+		params["delay_ms"] = s.Delay.Milliseconds()
+		params["peers"] = peerStrings(s.Peers)
+		params["protocol"] = s.Protocol
+		params["ports"] = s.Ports.String()
+		if s.NetworkInterface != nil {
+			netIfaceName = s.NetworkInterface.Name
+		}
+
+	} else if s, ok := ns.service.(*corrupt.Corrupt); ok {
+		name = "corrupt"
+		params["corrupt_rate"] = s.CorruptRate
+		params["payload_only"] = s.PayloadOnly
+		params["fix_checksum"] = s.FixChecksum
+		params["peers"] = peerStrings(s.Peers)
+		params["protocol"] = s.Protocol
+		params["ports"] = s.Ports.String()
+		if s.NetworkInterface != nil {
+			netIfaceName = s.NetworkInterface.Name
+		}
+
+	} else if s, ok := ns.service.(*partition.Partition); ok {
+		name = "partition"
+		params["peers"] = peerStrings(s.Peers)
+		if s.NetworkInterface != nil {
+			netIfaceName = s.NetworkInterface.Name
+		}
+
+	} else {
+		return ServiceStatus{}, errors.New("could not cast netRestrictService.service to *packetloss.PacketLoss, *bandwidth.Bandwidth, *latency.Latency, *duplicate.Duplicate, *reorder.Reorder, *corrupt.Corrupt or *partition.Partition")
+	}
+
+	return ServiceStatus{
+		Name:                 name,
+		Ready:                ns.ready,
+		NetworkInterfaceName: netIfaceName,
+		Params:               params,
+	}, nil
+}
+
+func peerStrings(peers []*net.IPNet) []string {
+	out := make([]string, 0, len(peers))
+	for _, p := range peers {
+		out = append(out, p.String())
+	}
+	return out
+}
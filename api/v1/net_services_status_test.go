@@ -0,0 +1,48 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *APITestSuite) TestNetServicesStatus() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(s.getDefaultPacketLossStartRequest())
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	defer func() {
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}()
+
+	rr = httptest.NewRecorder()
+	s.restAPI.NetServicesStatus(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var statuses []api.ServiceStatus
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&statuses))
+
+	found := false
+	for _, st := range statuses {
+		if st.Name == "packetloss" && st.NetworkInterfaceName == s.ifaceName {
+			found = true
+			assert.True(t, st.Ready)
+		}
+	}
+	assert.True(t, found, "packetloss instance of %q not listed", s.ifaceName)
+}
@@ -1,27 +1,89 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/gorilla/mux"
 )
 
-func netServiceStart(resp http.ResponseWriter, ns *netRestrictService, ifaceName string) error {
+// requestNetworkInterfaceName returns the network interface name given in
+// the request path, or the fallback if the path does not address one.
+func requestNetworkInterfaceName(req *http.Request, fallback string) string {
+	if req == nil {
+		return fallback
+	}
+	if name, ok := mux.Vars(req)[pathVarNetworkInterface]; ok && name != "" {
+		return name
+	}
+	return fallback
+}
+
+// netServiceGet returns the service instance of the given network interface
+// and sends an error response if there is none.
+func netServiceGet(resp http.ResponseWriter, g *netServiceGroup, ifaceName string) *netRestrictService {
+	if !ensureServiceGroupInitialized(resp, g) {
+		return nil
+	}
+
+	ns, err := g.Get(ifaceName)
+	if err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceStartFailed,
+				Title:   "Service start failed",
+				Message: err.Error(),
+			},
+			http.StatusInternalServerError)
+		return nil
+	}
+
 	if !ensureServiceInitialized(resp, ns) {
+		return nil
+	}
+	return ns
+}
+
+// netServiceStart starts the service instance of the given network interface
+// with the parameters of the request. The request is applied to a new service,
+// which replaces the one of the instance once started, so the instance is left
+// as is if it runs already or the parameters are invalid.
+func netServiceStart(resp http.ResponseWriter, g *netServiceGroup, ifaceName string, r interface {
+	apply(*netRestrictService) error
+}) error {
+	ns := netServiceGet(resp, g, ifaceName)
+	if ns == nil {
 		return ErrServiceNotInitialized
 	}
 
-	if ns.ready {
-		sendJSONError(resp, MetaMessage{
-			Type:    APIMetaMessageTypeError,
-			Slug:    SlugServiceAlreadyStarted,
-			Title:   "Service already started",
-			Message: "To start the service again, it must be stopped first.",
-		}, http.StatusBadRequest)
+	if ns.Ready() {
+		sendServiceAlreadyStarted(resp)
 		return ErrServiceAlreadyStarted
 	}
 
-	if err := ns.Start(ifaceName); err != nil {
+	next := &netRestrictService{service: g.newService()}
+	if err := r.apply(next); err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceSetParamFailed,
+				Title:   "Service set param failed",
+				Message: err.Error(),
+			},
+			http.StatusInternalServerError)
+		return err
+	}
+
+	if err := ns.Start(ifaceName, next.service); err != nil {
+		if errors.Is(err, ErrServiceAlreadyStarted) {
+			sendServiceAlreadyStarted(resp)
+			return err
+		}
+
 		slug := SlugServiceStartFailed
 		if err == ErrServiceNotInitialized {
 			slug = SlugServiceNotInitialized
@@ -41,7 +103,121 @@ func netServiceStart(resp http.ResponseWriter, ns *netRestrictService, ifaceName
 	return nil
 }
 
-func netServiceStop(resp http.ResponseWriter, ns *netRestrictService) error {
+func sendServiceAlreadyStarted(resp http.ResponseWriter) {
+	sendJSONError(resp, MetaMessage{
+		Type:    APIMetaMessageTypeError,
+		Slug:    SlugServiceAlreadyStarted,
+		Title:   "Service already started",
+		Message: "To start the service again, it must be stopped first.",
+	}, http.StatusBadRequest)
+}
+
+// netServiceUpdate changes the parameters of the running service instances of
+// the given network interface, or of all of them if it is empty, to the ones
+// of the request.
+func netServiceUpdate(resp http.ResponseWriter, g *netServiceGroup, ifaceName string, r interface {
+	apply(*netRestrictService) error
+}) error {
+	if !ensureServiceGroupInitialized(resp, g) {
+		return ErrServiceNotInitialized
+	}
+
+	nss := g.ReadyInstances(ifaceName)
+	if len(nss) == 0 {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceNotStarted,
+				Title:   "Service update failed",
+				Message: "To update the service, it must be started first.",
+			},
+			http.StatusBadRequest)
+		return ErrServiceNotStarted
+	}
+
+	// The previous parameters of the instances updated so far, restored if
+	// another instance rejects the update.
+	var updated []*netRestrictService
+	var previous []xdp.XdpLoader
+	for _, ns := range nss {
+		// The request is applied to a new instance first, so the running
+		// one is left as is if the parameters are invalid.
+		next := &netRestrictService{service: g.newService()}
+		err := r.apply(next)
+		var prev xdp.XdpLoader
+		if err == nil {
+			prev, err = ns.Update(next.service)
+		}
+		if err == nil {
+			updated = append(updated, ns)
+			previous = append(previous, prev)
+			continue
+		}
+
+		if len(nss) > 1 {
+			err = fmt.Errorf("%s: %w", ns.NetworkInterfaceName(), err)
+		}
+		for i, u := range updated {
+			if _, rErr := u.Update(previous[i]); rErr != nil {
+				err = errors.Join(err, fmt.Errorf("restore the parameters on %s: %w", u.NetworkInterfaceName(), rErr))
+			}
+		}
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceSetParamFailed,
+				Title:   "Service update failed",
+				Message: err.Error(),
+			},
+			http.StatusInternalServerError)
+		return err
+	}
+
+	err := sendJSON(resp, MetaMessage{
+		Type:  APIMetaMessageTypeInfo,
+		Slug:  SlugServiceReady,
+		Title: "Service updated",
+	})
+	if err != nil {
+		return fmt.Errorf("sendJSON failed: %w", err)
+	}
+	return nil
+}
+
+// netServiceStop stops all the given service instances and sends a single
+// response for all of them.
+func netServiceStop(resp http.ResponseWriter, nss ...*netRestrictService) error {
+	if len(nss) == 0 {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceNotStarted,
+				Title:   "Service stop failed",
+				Message: ErrServiceNotStarted.Error(),
+			},
+			http.StatusInternalServerError)
+		return ErrServiceNotStarted
+	}
+
+	for _, ns := range nss {
+		if err := stopNetService(resp, ns); err != nil {
+			return err
+		}
+	}
+
+	err := sendJSON(resp, MetaMessage{
+		Type:  APIMetaMessageTypeInfo,
+		Slug:  SlugServiceNotReady,
+		Title: "Service stopped",
+	})
+	if err != nil {
+		return fmt.Errorf("sendJSON failed: %w", err)
+	}
+
+	return nil
+}
+
+func stopNetService(resp http.ResponseWriter, ns *netRestrictService) error {
 	if !ensureServiceInitialized(resp, ns) {
 		return ErrServiceNotInitialized
 	}
@@ -68,12 +244,12 @@ func netServiceStop(resp http.ResponseWriter, ns *netRestrictService) error {
 	timeout := ServiceStopTimeout * 1000 / 100
 	for range ticker.C {
 		timeout--
-		if !ns.ready || timeout <= 0 {
+		if !ns.Ready() || timeout <= 0 {
 			break
 		}
 	}
 
-	if ns.ready {
+	if ns.Ready() {
 		sendJSONError(resp, MetaMessage{
 			Type:    APIMetaMessageTypeError,
 			Slug:    SlugServiceStopFailed,
@@ -83,20 +259,13 @@ func netServiceStop(resp http.ResponseWriter, ns *netRestrictService) error {
 		return ErrServiceStopFailed
 	}
 
-	err := sendJSON(resp, MetaMessage{
-		Type:  APIMetaMessageTypeInfo,
-		Slug:  SlugServiceNotReady,
-		Title: "Service stopped",
-	})
-	if err != nil {
-		return fmt.Errorf("sendJSON failed: %w", err)
-	}
-
 	return nil
 }
 
-func netServiceStatus(resp http.ResponseWriter, ns *netRestrictService) error {
-	if ns == nil || ns.service == nil {
+// netServiceStatus reports the service as ready if any of the given
+// service instances is ready.
+func netServiceStatus(resp http.ResponseWriter, g *netServiceGroup, nss ...*netRestrictService) error {
+	if g == nil {
 		sendJSONError(resp, MetaMessage{
 			Type:    APIMetaMessageTypeError,
 			Slug:    SlugServiceNotInitialized,
@@ -107,8 +276,11 @@ func netServiceStatus(resp http.ResponseWriter, ns *netRestrictService) error {
 	}
 
 	statusSlug := SlugServiceNotReady
-	if ns.ready {
-		statusSlug = SlugServiceReady
+	for _, ns := range nss {
+		if ns != nil && ns.Ready() {
+			statusSlug = SlugServiceReady
+			break
+		}
 	}
 
 	err := sendJSON(resp, MetaMessage{
@@ -123,7 +295,7 @@ func netServiceStatus(resp http.ResponseWriter, ns *netRestrictService) error {
 }
 
 func ensureServiceInitialized(resp http.ResponseWriter, ns *netRestrictService) bool {
-	if ns != nil && ns.service != nil {
+	if ns != nil && ns.initialized() {
 		return true
 	}
 	sendJSONError(resp,
@@ -137,3 +309,19 @@ func ensureServiceInitialized(resp http.ResponseWriter, ns *netRestrictService)
 
 	return false
 }
+
+func ensureServiceGroupInitialized(resp http.ResponseWriter, g *netServiceGroup) bool {
+	if g != nil {
+		return true
+	}
+	sendJSONError(resp,
+		MetaMessage{
+			Type:    APIMetaMessageTypeError,
+			Slug:    SlugServiceNotInitialized,
+			Title:   "Service not initiated",
+			Message: "a.(g *netServiceGroup) is nil",
+		},
+		http.StatusInternalServerError)
+
+	return false
+}
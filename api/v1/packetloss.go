@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/celestiaorg/bittwister/xdp"
 	"go.uber.org/zap"
 )
 
-// PacketlossStart implements POST /packetloss/start
+// PacketlossStart implements POST /packetloss/start and POST /packetloss/{iface}/start
 func (a *RESTApiV1) PacketlossStart(resp http.ResponseWriter, req *http.Request) {
 	var body PacketLossStartRequest
 	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
@@ -22,38 +23,102 @@ func (a *RESTApiV1) PacketlossStart(resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	if !ensureServiceInitialized(resp, a.pl) {
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	err := netServiceStart(resp, a.pl, ifaceName, body)
+	if err != nil {
+		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	}
+}
+
+// PacketlossStop implements POST /packetloss/stop and POST /packetloss/{iface}/stop
+//
+// Without a network interface in the path, it stops the service on all interfaces.
+func (a *RESTApiV1) PacketlossStop(resp http.ResponseWriter, req *http.Request) {
+	if !ensureServiceGroupInitialized(resp, a.pl) {
 		return
 	}
 
-	if err := a.pl.SetPacketLossRate(body.PacketLossRate); err != nil {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStop(resp, a.pl.ReadyInstances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+	}
+}
+
+// PacketlossUpdate implements PUT /packetloss/params and PUT /packetloss/{iface}/params
+//
+// It changes the parameters of the running service without stopping it.
+// Without a network interface, it updates the service on all interfaces.
+func (a *RESTApiV1) PacketlossUpdate(resp http.ResponseWriter, req *http.Request) {
+	var body PacketLossUpdateRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
 		sendJSONError(resp,
 			MetaMessage{
 				Type:    APIMetaMessageTypeError,
-				Slug:    SlugServiceSetParamFailed,
-				Title:   "Service set param failed",
+				Slug:    SlugJSONDecodeFailed,
+				Title:   "JSON decode failed",
 				Message: err.Error(),
 			},
-			http.StatusInternalServerError)
+			http.StatusBadRequest)
 		return
 	}
 
-	err := netServiceStart(resp, a.pl, body.NetworkInterfaceName)
-	if err != nil {
-		a.loggerNoStack.Error("netServiceStart failed", zap.Error(err))
+	ifaceName := requestNetworkInterfaceName(req, body.NetworkInterfaceName)
+	if err := netServiceUpdate(resp, a.pl, ifaceName, body); err != nil {
+		a.loggerNoStack.Error("netServiceUpdate failed", zap.Error(err))
 	}
 }
 
-// PacketlossStop implements POST /packetloss/stop
-func (a *RESTApiV1) PacketlossStop(resp http.ResponseWriter, req *http.Request) {
-	if err := netServiceStop(resp, a.pl); err != nil {
-		a.loggerNoStack.Error("netServiceStop failed", zap.Error(err))
+// PacketlossStatus implements GET /packetloss/status and GET /packetloss/{iface}/status
+//
+// Without a network interface in the path, the service is reported ready
+// if it is running on any interface.
+func (a *RESTApiV1) PacketlossStatus(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStatus(resp, a.pl, a.pl.Instances(ifaceName)...); err != nil {
+		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
 	}
 }
 
-// PacketlossStatus implements GET /packetloss/status
-func (a *RESTApiV1) PacketlossStatus(resp http.ResponseWriter, _ *http.Request) {
-	if err := netServiceStatus(resp, a.pl); err != nil {
-		a.loggerNoStack.Error("netServiceStatus failed", zap.Error(err))
+// PacketlossStats implements GET /packetloss/stats and GET /packetloss/{iface}/stats
+//
+// Without a network interface in the path, the counters of the service on
+// all the interfaces it runs on are returned.
+func (a *RESTApiV1) PacketlossStats(resp http.ResponseWriter, req *http.Request) {
+	ifaceName := requestNetworkInterfaceName(req, "")
+	if err := netServiceStats(resp, a.pl, ifaceName); err != nil {
+		a.loggerNoStack.Error("netServiceStats failed", zap.Error(err))
+	}
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r PacketLossStartRequest) apply(ns *netRestrictService) error {
+	err := ns.SetPacketLossRate(r.PacketLossRate)
+	if err == nil {
+		err = ns.SetPacketLossDirectionRates(r.PacketLossRateIngress, r.PacketLossRateEgress)
+	}
+	if err == nil {
+		err = ns.SetLossModel(r.Model, r.GilbertElliott.model())
+	}
+	if err == nil {
+		err = ns.SetDirection(xdp.Direction(r.Direction))
+	}
+	if err == nil {
+		err = ns.SetPeers(r.Peers)
+	}
+	if err == nil {
+		err = ns.SetProtocolFilter(r.Protocol, r.Ports)
+	}
+	return err
+}
+
+// apply sets the parameters of the request on the service instance.
+func (r PacketLossUpdateRequest) apply(ns *netRestrictService) error {
+	err := ns.SetPacketLossRate(r.PacketLossRate)
+	if err == nil {
+		err = ns.SetPacketLossDirectionRates(r.PacketLossRateIngress, r.PacketLossRateEgress)
+	}
+	if err == nil {
+		err = ns.SetLossModel(r.Model, r.GilbertElliott.model())
 	}
+	return err
 }
@@ -3,10 +3,12 @@ package api_test
 import (
 	"bytes"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 
 	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -70,9 +72,457 @@ func (s *APITestSuite) TestPacketlossStatus() {
 	assert.Equal(t, api.SlugServiceNotReady, slug)
 }
 
+func (s *APITestSuite) TestPacketlossInterfaceStartStop() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(api.PacketLossStartRequest{PacketLossRate: 10})
+	require.NoError(t, err)
+
+	ifaceVars := map[string]string{"iface": s.ifaceName}
+
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.InterfaceStart(s.ifaceName), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, mux.SetURLVars(req, ifaceVars))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	statusReq, err := http.NewRequest(http.MethodGet, api.PacketlossPath.InterfaceStatus(s.ifaceName), nil)
+	require.NoError(t, err)
+	statusReq = mux.SetURLVars(statusReq, ifaceVars)
+
+	slug, err := getServiceStatusSlug(func(w http.ResponseWriter, _ *http.Request) {
+		s.restAPI.PacketlossStatus(w, statusReq)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceReady, slug)
+
+	stopReq, err := http.NewRequest(http.MethodPost, api.PacketlossPath.InterfaceStop(s.ifaceName), nil)
+	require.NoError(t, err)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStop(rr, mux.SetURLVars(stopReq, ifaceVars))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	slug, err = getServiceStatusSlug(func(w http.ResponseWriter, _ *http.Request) {
+		s.restAPI.PacketlossStatus(w, statusReq)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+}
+
+func (s *APITestSuite) TestPacketlossInterfaceNotFound() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(api.PacketLossStartRequest{PacketLossRate: 10})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.InterfaceStart("bt-missing0"), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, mux.SetURLVars(req, map[string]string{"iface": "bt-missing0"}))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	var msg api.MetaMessage
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+	assert.Equal(t, api.SlugServiceStartFailed, msg.Slug)
+}
+
+func (s *APITestSuite) TestPacketlossDirection() {
+	t := s.T()
+
+	tests := []struct {
+		direction string
+		wantCode  int
+	}{
+		{direction: "egress", wantCode: http.StatusOK},
+		{direction: "both", wantCode: http.StatusOK},
+		{direction: "sideways", wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultPacketLossStartRequest()
+		body.Direction = tt.direction
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossStart(rr, req)
+		require.Equal(t, tt.wantCode, rr.Code, "direction %q: %s", tt.direction, rr.Body.String())
+
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.PacketlossStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
 func (s *APITestSuite) getDefaultPacketLossStartRequest() api.PacketLossStartRequest {
 	return api.PacketLossStartRequest{
 		NetworkInterfaceName: s.ifaceName,
 		PacketLossRate:       10,
 	}
 }
+
+func (s *APITestSuite) TestPacketlossPeers() {
+	t := s.T()
+
+	tests := []struct {
+		peers    []string
+		wantCode int
+	}{
+		{peers: []string{"127.0.0.0/8", "::1"}, wantCode: http.StatusOK},
+		{peers: []string{"127.0.0.0/33"}, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultPacketLossStartRequest()
+		body.Peers = tt.peers
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossStart(rr, req)
+		require.Equal(t, tt.wantCode, rr.Code, "peers %v: %s", tt.peers, rr.Body.String())
+
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.PacketlossStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) TestPacketlossProtocolFilter() {
+	t := s.T()
+
+	tests := []struct {
+		protocol string
+		ports    string
+		wantCode int
+	}{
+		{protocol: "tcp", ports: "26656", wantCode: http.StatusOK},
+		{protocol: "udp", ports: "26656-26660", wantCode: http.StatusOK},
+		{protocol: "icmp", wantCode: http.StatusOK},
+		{protocol: "icmp", ports: "26656", wantCode: http.StatusInternalServerError},
+		{protocol: "sctp", wantCode: http.StatusInternalServerError},
+		{ports: "26660-26656", wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultPacketLossStartRequest()
+		body.Protocol = tt.protocol
+		body.Ports = tt.ports
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossStart(rr, req)
+		require.Equal(t, tt.wantCode, rr.Code, "protocol %q, ports %q: %s", tt.protocol, tt.ports, rr.Body.String())
+
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.PacketlossStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) TestPacketlossGilbertElliott() {
+	t := s.T()
+
+	tests := []struct {
+		name     string
+		model    string
+		params   *api.GilbertElliottParams
+		wantCode int
+	}{
+		{name: "gilbert", model: "gilbert-elliott", params: &api.GilbertElliottParams{P: 1, R: 30, LossBad: 100}, wantCode: http.StatusOK},
+		{name: "gilbert-elliott", model: "gilbert-elliott", params: &api.GilbertElliottParams{P: 0.5, R: 10, LossGood: 0.1, LossBad: 50}, wantCode: http.StatusOK},
+		{name: "out of range", model: "gilbert-elliott", params: &api.GilbertElliottParams{P: 101}, wantCode: http.StatusInternalServerError},
+		{name: "unknown model", model: "pareto", wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultPacketLossStartRequest()
+		body.Model = tt.model
+		body.GilbertElliott = tt.params
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossStart(rr, req)
+		require.Equal(t, tt.wantCode, rr.Code, "%s: %s", tt.name, rr.Body.String())
+
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.PacketlossStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) TestPacketlossFractionalRate() {
+	t := s.T()
+
+	tests := []struct {
+		rate     float64
+		wantCode int
+	}{
+		{rate: 0.0001, wantCode: http.StatusOK},
+		{rate: 0.00001, wantCode: http.StatusInternalServerError},
+		{rate: 0.01, wantCode: http.StatusOK},
+		{rate: 0.1, wantCode: http.StatusOK},
+		{rate: 100, wantCode: http.StatusOK},
+		{rate: 100.5, wantCode: http.StatusInternalServerError},
+		{rate: -1, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		body := s.getDefaultPacketLossStartRequest()
+		body.PacketLossRate = tt.rate
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossStart(rr, req)
+		require.Equal(t, tt.wantCode, rr.Code, "rate %v: %s", tt.rate, rr.Body.String())
+
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		rr = httptest.NewRecorder()
+		s.restAPI.PacketlossStop(rr, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func (s *APITestSuite) TestPacketlossUpdate() {
+	t := s.T()
+
+	update := func(body api.PacketLossUpdateRequest) *httptest.ResponseRecorder {
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPut, api.PacketlossPath.Params(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossUpdate(rr, req)
+		return rr
+	}
+
+	rr := update(api.PacketLossUpdateRequest{NetworkInterfaceName: s.ifaceName, PacketLossRate: 50})
+	require.Equal(t, http.StatusBadRequest, rr.Code, "a stopped service cannot be updated: %s", rr.Body.String())
+
+	body := s.getDefaultPacketLossStartRequest()
+	body.Peers = []string{"10.0.0.0/24"}
+	jsonBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = update(api.PacketLossUpdateRequest{NetworkInterfaceName: s.ifaceName, PacketLossRate: 50})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = update(api.PacketLossUpdateRequest{NetworkInterfaceName: s.ifaceName, PacketLossRate: 101})
+	require.Equal(t, http.StatusInternalServerError, rr.Code, rr.Body.String())
+
+	params := s.packetlossParams()
+	assert.Equal(t, float64(50), params["packet_loss_rate"], "an invalid update must leave the service as is")
+	assert.Equal(t, []interface{}{"10.0.0.0/24"}, params["peers"], "the peers are kept")
+
+	slug, err := getServiceStatusSlug(s.restAPI.PacketlossStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceReady, slug)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func (s *APITestSuite) TestPacketlossAlreadyStarted() {
+	t := s.T()
+
+	start := func(body api.PacketLossStartRequest) *httptest.ResponseRecorder {
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossStart(rr, req)
+		return rr
+	}
+
+	rr := start(s.getDefaultPacketLossStartRequest())
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	body := s.getDefaultPacketLossStartRequest()
+	body.PacketLossRate = 50
+	body.Direction = "ingress"
+	body.Peers = []string{"10.0.0.0/24"}
+	rr = start(body)
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	params := s.packetlossParams()
+	assert.Equal(t, float64(10), params["packet_loss_rate"], "a rejected start must leave the running service as is")
+	assert.Equal(t, "ingress", params["direction"])
+	assert.Empty(t, params["peers"])
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func (s *APITestSuite) TestPacketlossAsymmetric() {
+	t := s.T()
+
+	body := s.getDefaultPacketLossStartRequest()
+	body.PacketLossRate = 0
+	body.PacketLossRateIngress = 5
+	body.PacketLossRateEgress = 20
+	jsonBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	params := s.packetlossParams()
+	assert.Equal(t, "both", params["direction"], "the direction is derived from the rates")
+	assert.Equal(t, float64(5), params["packet_loss_rate_ingress"])
+	assert.Equal(t, float64(20), params["packet_loss_rate_egress"])
+
+	update := func(body api.PacketLossUpdateRequest) *httptest.ResponseRecorder {
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPut, api.PacketlossPath.Params(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.PacketlossUpdate(rr, req)
+		return rr
+	}
+
+	rr = update(api.PacketLossUpdateRequest{NetworkInterfaceName: s.ifaceName, PacketLossRateEgress: 30})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = update(api.PacketLossUpdateRequest{NetworkInterfaceName: s.ifaceName, PacketLossRate: 10, PacketLossRateEgress: 30})
+	require.Equal(t, http.StatusInternalServerError, rr.Code, "a rate cannot be combined with the direction rates: %s", rr.Body.String())
+
+	params = s.packetlossParams()
+	assert.Equal(t, float64(0), params["packet_loss_rate_ingress"])
+	assert.Equal(t, float64(30), params["packet_loss_rate_egress"])
+	assert.Equal(t, "both", params["direction"], "the direction is kept")
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+// packetlossParams returns the parameters of the packetloss service of the
+// loopback interface listed in the services status.
+func (s *APITestSuite) packetlossParams() map[string]interface{} {
+	t := s.T()
+
+	rr := httptest.NewRecorder()
+	s.restAPI.NetServicesStatus(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var out []api.ServiceStatus
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&out))
+	for _, st := range out {
+		if st.Name == "packetloss" && st.NetworkInterfaceName == s.ifaceName {
+			return st.Params
+		}
+	}
+	require.Fail(t, "packetloss status not found")
+	return nil
+}
+
+func (s *APITestSuite) TestPacketlossStats() {
+	t := s.T()
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PacketlossStats(rr, httptest.NewRequest(http.MethodGet, api.PacketlossPath.Stats(), nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	// Drop all the packets of a single loopback address, to count them
+	// without disturbing the other tests.
+	body := s.getDefaultPacketLossStartRequest()
+	body.PacketLossRate = 100
+	body.Peers = []string{"127.0.0.42"}
+	jsonBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PacketlossPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.42"), Port: 9}
+	conn, err := net.DialUDP("udp4", &net.UDPAddr{IP: addr.IP}, addr)
+	require.NoError(t, err)
+	const sent = 5
+	for i := 0; i < sent; i++ {
+		_, err := conn.Write([]byte("bittwister"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, conn.Close())
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStats(rr, httptest.NewRequest(http.MethodGet, api.PacketlossPath.Stats(), nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var stats []api.ServiceStats
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&stats))
+	require.Len(t, stats, 1)
+	assert.Equal(t, "packetloss", stats[0].Name)
+	assert.Equal(t, s.ifaceName, stats[0].NetworkInterfaceName)
+	assert.EqualValues(t, sent, stats[0].Ingress.PacketsDropped)
+	assert.EqualValues(t, sent, stats[0].Ingress.PacketsSeen)
+	assert.Zero(t, stats[0].Ingress.PacketsPassed)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
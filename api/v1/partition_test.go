@@ -0,0 +1,61 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *APITestSuite) TestPartitionStartStop() {
+	t := s.T()
+
+	jsonBody, err := json.Marshal(s.getDefaultPartitionStartRequest())
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PartitionPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PartitionStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	slug, err := getServiceStatusSlug(s.restAPI.PartitionStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceReady, slug)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PartitionStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	slug, err = getServiceStatusSlug(s.restAPI.PartitionStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+}
+
+func (s *APITestSuite) TestPartitionNoPeers() {
+	t := s.T()
+
+	body := s.getDefaultPartitionStartRequest()
+	body.Peers = nil
+	jsonBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, api.PartitionPath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.PartitionStart(rr, req)
+	require.Equal(t, http.StatusInternalServerError, rr.Code, rr.Body.String())
+}
+
+func (s *APITestSuite) getDefaultPartitionStartRequest() api.PartitionStartRequest {
+	return api.PartitionStartRequest{
+		NetworkInterfaceName: s.ifaceName,
+		Peers:                []string{"192.0.2.0/24"},
+	}
+}
@@ -0,0 +1,44 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *APITestSuite) TestReorderInvalidRate() {
+	t := s.T()
+
+	// below one part per million, the rate would read as a stopped service
+	for _, rate := range []float64{101, 0.00001} {
+		body := s.getDefaultReorderStartRequest()
+		body.ReorderRate = rate
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, api.ReorderPath.Start(), bytes.NewReader(jsonBody))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.ReorderStart(rr, req)
+		require.Equal(t, http.StatusInternalServerError, rr.Code, "rate %v: %s", rate, rr.Body.String())
+
+		slug, err := getServiceStatusSlug(s.restAPI.ReorderStatus)
+		require.NoError(t, err)
+		assert.NotEqual(t, api.SlugServiceReady, slug)
+	}
+}
+
+func (s *APITestSuite) getDefaultReorderStartRequest() api.ReorderStartRequest {
+	return api.ReorderStartRequest{
+		NetworkInterfaceName: s.ifaceName,
+		ReorderRate:          25,
+		Gap:                  5,
+		Delay:                10,
+	}
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/celestiaorg/bittwister/xdp/bandwidth"
+	"github.com/celestiaorg/bittwister/xdp/corrupt"
+	"github.com/celestiaorg/bittwister/xdp/duplicate"
+	"github.com/celestiaorg/bittwister/xdp/latency"
+	"github.com/celestiaorg/bittwister/xdp/packetloss"
+	"github.com/celestiaorg/bittwister/xdp/partition"
+	"github.com/celestiaorg/bittwister/xdp/reorder"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes a set of services to start at once. Each entry has the
+// fields of the start request of its service; NetworkInterfaceName is used
+// by the entries which do not name a network interface. A scenario with
+// phases is run by a Scheduler, its services are then set in the phases.
+type Scenario struct {
+	NetworkInterfaceName string                   `json:"network_interface,omitempty"`
+	PacketLoss           []PacketLossStartRequest `json:"packetloss,omitempty"`
+	Bandwidth            []BandwidthStartRequest  `json:"bandwidth,omitempty"`
+	Latency              []LatencyStartRequest    `json:"latency,omitempty"`
+	Duplicate            []DuplicateStartRequest  `json:"duplicate,omitempty"`
+	Reorder              []ReorderStartRequest    `json:"reorder,omitempty"`
+	Corrupt              []CorruptStartRequest    `json:"corrupt,omitempty"`
+	Partition            []PartitionStartRequest  `json:"partition,omitempty"`
+	Phases               []SchedulePhase          `json:"phases,omitempty"`
+}
+
+// ScenarioService is a service of a scenario, validated but not started.
+type ScenarioService struct {
+	Name                 string // e.g. "packetloss"
+	NetworkInterfaceName string
+	Service              xdp.XdpLoader
+}
+
+// ParseScenario decodes a scenario given in YAML or JSON, which is a subset
+// of YAML. Both use the field names of the JSON API, unknown fields are
+// rejected.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+
+	var s Scenario
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	return &s, nil
+}
+
+// Services builds the services of the scenario and validates all of them,
+// nothing is attached to the network interfaces. A service can only appear
+// once per network interface.
+func (s *Scenario) Services() ([]ScenarioService, error) {
+	if len(s.Phases) > 0 {
+		return nil, fmt.Errorf("the scenario has phases, it must be run as a schedule")
+	}
+
+	services, err := s.services(s.NetworkInterfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("the scenario has no services")
+	}
+	return services, nil
+}
+
+// services builds and validates the services of the scenario, defaultIfaceName
+// is the network interface of the entries which do not name one.
+func (s *Scenario) services(defaultIfaceName string) ([]ScenarioService, error) {
+	var (
+		services []ScenarioService
+		seen     = make(map[string]bool)
+	)
+	add := func(name string, i int, ifaceName string, req interface {
+		apply(*netRestrictService) error
+	}, service xdp.XdpLoader) error {
+		if ifaceName == "" {
+			ifaceName = defaultIfaceName
+		}
+		if ifaceName == "" {
+			return fmt.Errorf("%s[%d]: no network interface", name, i)
+		}
+
+		key := name + "/" + ifaceName
+		if seen[key] {
+			return fmt.Errorf("%s[%d]: %s is set more than once on %s", name, i, name, ifaceName)
+		}
+		seen[key] = true
+
+		ns := &netRestrictService{service: service}
+		err := req.apply(ns)
+		if err == nil {
+			err = ns.SetNetworkInterface(ifaceName)
+		}
+		if err == nil {
+			err = service.Validate()
+		}
+		if err != nil {
+			return fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+
+		services = append(services, ScenarioService{
+			Name:                 name,
+			NetworkInterfaceName: ifaceName,
+			Service:              service,
+		})
+		return nil
+	}
+
+	for i, r := range s.PacketLoss {
+		if err := add("packetloss", i, r.NetworkInterfaceName, r, &packetloss.PacketLoss{}); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range s.Bandwidth {
+		if err := add("bandwidth", i, r.NetworkInterfaceName, r, &bandwidth.Bandwidth{}); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range s.Latency {
+		if err := add("latency", i, r.NetworkInterfaceName, r, &latency.Latency{}); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range s.Duplicate {
+		if err := add("duplicate", i, r.NetworkInterfaceName, r, &duplicate.Duplicate{}); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range s.Reorder {
+		if err := add("reorder", i, r.NetworkInterfaceName, r, &reorder.Reorder{}); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range s.Corrupt {
+		if err := add("corrupt", i, r.NetworkInterfaceName, r, &corrupt.Corrupt{}); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range s.Partition {
+		if err := add("partition", i, r.NetworkInterfaceName, r, &partition.Partition{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return services, nil
+}
+
+// ScenarioStart implements POST /scenario
+//
+// The whole scenario is validated before any service is started, and the
+// services already started are stopped if one of them fails to start.
+func (a *RESTApiV1) ScenarioStart(resp http.ResponseWriter, req *http.Request) {
+	var (
+		scenario *Scenario
+		services []ScenarioService
+	)
+	data, err := io.ReadAll(req.Body)
+	if err == nil {
+		scenario, err = ParseScenario(data)
+	}
+	if err == nil {
+		services, err = scenario.Services()
+	}
+	if err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugScenarioInvalid,
+				Title:   "Scenario invalid",
+				Message: err.Error(),
+			},
+			http.StatusBadRequest)
+		return
+	}
+
+	nss := make([]*netRestrictService, 0, len(services))
+	for _, s := range services {
+		ns, err := a.scenarioInstance(s)
+		if err != nil {
+			sendScenarioInstanceError(resp, err)
+			return
+		}
+		nss = append(nss, ns)
+	}
+
+	for i, s := range services {
+		if err := nss[i].Start(s.NetworkInterfaceName, s.Service); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if sErr := nss[j].Stop(); sErr != nil {
+					a.loggerNoStack.Error("stop scenario service", zap.String("service", services[j].Name), zap.Error(sErr))
+				}
+			}
+
+			sendJSONError(resp,
+				MetaMessage{
+					Type:    APIMetaMessageTypeError,
+					Slug:    SlugServiceStartFailed,
+					Title:   "Service start failed",
+					Message: fmt.Sprintf("%s on %s: %v", s.Name, s.NetworkInterfaceName, err),
+				},
+				http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = sendJSON(resp, MetaMessage{
+		Type:    APIMetaMessageTypeInfo,
+		Slug:    SlugServiceReady,
+		Title:   "Scenario started",
+		Message: fmt.Sprintf("%d services started", len(services)),
+	})
+	if err != nil {
+		a.loggerNoStack.Error("sendJSON failed", zap.Error(err))
+	}
+}
+
+// scenarioInstance returns the instance of the group of the service running
+// on its network interface, which must not be running already.
+func (a *RESTApiV1) scenarioInstance(s ScenarioService) (*netRestrictService, error) {
+	g := a.serviceGroup(s.Name)
+	if g == nil {
+		return nil, ErrServiceNotInitialized
+	}
+
+	ns, err := g.Get(s.NetworkInterfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if ns.Ready() {
+		return nil, fmt.Errorf("%w: %s is already running on %s, it must be stopped first", ErrServiceAlreadyStarted, s.Name, s.NetworkInterfaceName)
+	}
+	return ns, nil
+}
+
+func sendScenarioInstanceError(resp http.ResponseWriter, err error) {
+	msg := MetaMessage{
+		Type:    APIMetaMessageTypeError,
+		Slug:    SlugServiceStartFailed,
+		Title:   "Service start failed",
+		Message: err.Error(),
+	}
+	code := http.StatusInternalServerError
+	if errors.Is(err, ErrServiceAlreadyStarted) {
+		msg.Slug = SlugServiceAlreadyStarted
+		msg.Title = "Service already started"
+		code = http.StatusBadRequest
+	}
+	sendJSONError(resp, msg, code)
+}
+
+// serviceGroup returns the group of the service with the given name, as
+// named in the scenarios.
+func (a *RESTApiV1) serviceGroup(name string) *netServiceGroup {
+	switch name {
+	case "packetloss":
+		return a.pl
+	case "bandwidth":
+		return a.bw
+	case "latency":
+		return a.lt
+	case "duplicate":
+		return a.dp
+	case "reorder":
+		return a.ro
+	case "corrupt":
+		return a.cr
+	case "partition":
+		return a.pt
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *APITestSuite) TestParseScenario() {
+	t := s.T()
+
+	fromYAML, err := api.ParseScenario([]byte(`
+network_interface: eth0
+packetloss:
+  - packet_loss_rate: 0.5
+    peers: [10.0.0.0/24]
+duplicate:
+  - network_interface: eth1
+    duplicate_rate: 5
+    protocol: udp
+    ports: 26656-26660
+`))
+	require.NoError(t, err)
+
+	fromJSON, err := api.ParseScenario([]byte(`{
+		"network_interface": "eth0",
+		"packetloss": [{"packet_loss_rate": 0.5, "peers": ["10.0.0.0/24"]}],
+		"duplicate": [{"network_interface": "eth1", "duplicate_rate": 5, "protocol": "udp", "ports": "26656-26660"}]
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, fromJSON, fromYAML)
+	assert.Equal(t, "eth0", fromYAML.NetworkInterfaceName)
+	require.Len(t, fromYAML.Duplicate, 1)
+	assert.Equal(t, "eth1", fromYAML.Duplicate[0].NetworkInterfaceName)
+
+	_, err = api.ParseScenario([]byte("packetloss:\n  - packet_loss: 10\n"))
+	assert.Error(t, err, "unknown fields must be rejected")
+}
+
+func (s *APITestSuite) TestScenarioStartStop() {
+	t := s.T()
+
+	body := fmt.Sprintf(`
+network_interface: %s
+packetloss:
+  - packet_loss_rate: 10
+duplicate:
+  - duplicate_rate: 5
+    protocol: udp
+`, s.ifaceName)
+
+	req, err := http.NewRequest(http.MethodPost, api.ScenarioPath, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.ScenarioStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	for _, status := range []func(http.ResponseWriter, *http.Request){s.restAPI.PacketlossStatus, s.restAPI.DuplicateStatus} {
+		slug, err := getServiceStatusSlug(status)
+		require.NoError(t, err)
+		assert.Equal(t, api.SlugServiceReady, slug)
+	}
+
+	// The services of a scenario cannot be started twice
+	req, err = http.NewRequest(http.MethodPost, api.ScenarioPath, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	s.restAPI.ScenarioStart(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	s.restAPI.PacketlossStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.DuplicateStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func (s *APITestSuite) TestScenarioInvalid() {
+	t := s.T()
+
+	body := fmt.Sprintf(`{
+		"network_interface": %q,
+		"packetloss": [{"packet_loss_rate": 10}],
+		"duplicate": [{"duplicate_rate": 101}]
+	}`, s.ifaceName)
+
+	req, err := http.NewRequest(http.MethodPost, api.ScenarioPath, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.ScenarioStart(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	// Nothing is started if any service of the scenario is invalid
+	slug, err := getServiceStatusSlug(s.restAPI.PacketlossStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+}
@@ -0,0 +1,400 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"go.uber.org/zap"
+)
+
+// SchedulePhase is a phase of a schedule: its services run for Duration,
+// then they are stopped and the next phase starts. A phase without services
+// lets the network heal. The last phase may have no duration, it then runs
+// until the schedule is stopped.
+type SchedulePhase struct {
+	Name     string `json:"name,omitempty"`
+	Duration string `json:"duration,omitempty"` // e.g. "30s" or "2m"
+	Scenario
+}
+
+// ScheduleStatus describes the progress of a schedule.
+type ScheduleStatus struct {
+	Running    bool   `json:"running"`
+	Phase      string `json:"phase"`
+	PhaseIndex int    `json:"phase_index"`
+	Phases     int    `json:"phases"`
+	Elapsed    string `json:"elapsed"`            // time spent in the current phase
+	Duration   string `json:"duration,omitempty"` // empty if the phase runs until stopped
+	Error      string `json:"error,omitempty"`    // why the schedule stopped early, if it failed
+}
+
+type scheduledPhase struct {
+	name     string
+	duration time.Duration // 0: until the schedule is stopped
+	services []ScenarioService
+}
+
+// StartScheduledServiceFunc starts a service of a phase, the returned
+// function stops it at the end of the phase.
+type StartScheduledServiceFunc func(s ScenarioService) (xdp.CancelFunc, error)
+
+// Scheduler runs the phases of a scenario one after the other.
+type Scheduler struct {
+	phases       []scheduledPhase
+	startService StartScheduledServiceFunc
+	logger       *zap.Logger
+
+	mu             sync.Mutex
+	running        bool
+	current        int
+	phaseStartedAt time.Time
+	err            error
+}
+
+// NewScheduler validates all the phases of the scenario, the services of a
+// phase use the network interface of the phase, or else the one of the
+// scenario, if they do not name one.
+func NewScheduler(s *Scenario, startService StartScheduledServiceFunc, logger *zap.Logger) (*Scheduler, error) {
+	if len(s.Phases) == 0 {
+		return nil, fmt.Errorf("the scenario has no phases")
+	}
+	if len(s.PacketLoss)+len(s.Bandwidth)+len(s.Latency)+len(s.Duplicate)+len(s.Reorder)+len(s.Corrupt)+len(s.Partition) > 0 {
+		return nil, fmt.Errorf("the services of a scenario with phases must be set in the phases")
+	}
+
+	phases := make([]scheduledPhase, 0, len(s.Phases))
+	for i, p := range s.Phases {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("phase-%d", i)
+		}
+		if len(p.Phases) > 0 {
+			return nil, fmt.Errorf("phase %q: phases cannot be nested", name)
+		}
+
+		var duration time.Duration
+		if p.Duration != "" {
+			d, err := time.ParseDuration(p.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("phase %q: %w", name, err)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("phase %q: duration must be positive, got %s", name, p.Duration)
+			}
+			duration = d
+		} else if i != len(s.Phases)-1 {
+			return nil, fmt.Errorf("phase %q: only the last phase may have no duration", name)
+		}
+
+		ifaceName := p.NetworkInterfaceName
+		if ifaceName == "" {
+			ifaceName = s.NetworkInterfaceName
+		}
+		services, err := p.services(ifaceName)
+		if err != nil {
+			return nil, fmt.Errorf("phase %q: %w", name, err)
+		}
+
+		phases = append(phases, scheduledPhase{
+			name:     name,
+			duration: duration,
+			services: services,
+		})
+	}
+
+	return &Scheduler{
+		phases:       phases,
+		startService: startService,
+		logger:       logger,
+	}, nil
+}
+
+// Services returns the services of all the phases.
+func (s *Scheduler) Services() []ScenarioService {
+	var out []ScenarioService
+	for _, p := range s.phases {
+		out = append(out, p.services...)
+	}
+	return out
+}
+
+// Run runs the phases until the last one ends or ctx is canceled, the
+// services of the current phase are then stopped. It returns an error if
+// the services of a phase fail to start or to stop, the error is then
+// reported by Status too.
+func (s *Scheduler) Run(ctx context.Context) (err error) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return fmt.Errorf("the schedule is already running")
+	}
+	s.running = true
+	s.err = nil
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.err = err
+		s.mu.Unlock()
+	}()
+
+	for i, p := range s.phases {
+		s.mu.Lock()
+		s.current = i
+		s.phaseStartedAt = time.Now()
+		s.mu.Unlock()
+
+		s.logger.Info("schedule phase started", zap.String("phase", p.name), zap.Int("index", i))
+
+		cancels, err := s.startPhase(p)
+		if err != nil {
+			return fmt.Errorf("phase %q: %w", p.name, err)
+		}
+
+		canceled := waitPhase(ctx, p.duration)
+
+		var errs []error
+		for j := len(cancels) - 1; j >= 0; j-- {
+			if err := cancels[j](); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("phase %q: stop services: %w", p.name, err)
+		}
+
+		if canceled {
+			s.logger.Info("schedule stopped", zap.String("phase", p.name))
+			return nil
+		}
+	}
+
+	s.logger.Info("schedule finished")
+	return nil
+}
+
+// startPhase starts the services of the phase, the ones already started are
+// stopped if one of them fails to start.
+func (s *Scheduler) startPhase(p scheduledPhase) ([]xdp.CancelFunc, error) {
+	cancels := make([]xdp.CancelFunc, 0, len(p.services))
+	for _, svc := range p.services {
+		cancel, err := s.startService(svc)
+		if err != nil {
+			for j := len(cancels) - 1; j >= 0; j-- {
+				if sErr := cancels[j](); sErr != nil {
+					s.logger.Error("stop scheduled service", zap.Error(sErr))
+				}
+			}
+			return nil, fmt.Errorf("%s on %s: %w", svc.Name, svc.NetworkInterfaceName, err)
+		}
+		cancels = append(cancels, cancel)
+	}
+	return cancels, nil
+}
+
+// waitPhase waits for the end of the phase and reports whether ctx was
+// canceled first. A zero duration waits for ctx only.
+func waitPhase(ctx context.Context, duration time.Duration) bool {
+	if duration == 0 {
+		<-ctx.Done()
+		return true
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Status returns the current phase of the schedule.
+func (s *Scheduler) Status() ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phases[s.current]
+	st := ScheduleStatus{
+		Running:    s.running,
+		Phase:      p.name,
+		PhaseIndex: s.current,
+		Phases:     len(s.phases),
+	}
+	if s.running {
+		st.Elapsed = time.Since(s.phaseStartedAt).Round(time.Millisecond).String()
+	}
+	if p.duration > 0 {
+		st.Duration = p.duration.String()
+	}
+	if s.err != nil {
+		st.Error = s.err.Error()
+	}
+	return st
+}
+
+// ScheduleStart implements POST /schedule
+//
+// The body is a scenario with phases. All the phases are validated before
+// the schedule starts, it then runs in the background until its last phase
+// ends or it is stopped.
+func (a *RESTApiV1) ScheduleStart(resp http.ResponseWriter, req *http.Request) {
+	var (
+		scenario  *Scenario
+		scheduler *Scheduler
+	)
+	data, err := io.ReadAll(req.Body)
+	if err == nil {
+		scenario, err = ParseScenario(data)
+	}
+	if err == nil {
+		scheduler, err = NewScheduler(scenario, a.startScheduledService, a.logger)
+	}
+	if err != nil {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugScenarioInvalid,
+				Title:   "Schedule invalid",
+				Message: err.Error(),
+			},
+			http.StatusBadRequest)
+		return
+	}
+
+	a.scheduleMu.Lock()
+	defer a.scheduleMu.Unlock()
+
+	if a.scheduleDone != nil {
+		select {
+		case <-a.scheduleDone:
+		default:
+			sendJSONError(resp, MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceAlreadyStarted,
+				Title:   "Schedule already started",
+				Message: "To start a schedule, the running one must be stopped first.",
+			}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, s := range scheduler.Services() {
+		if _, err := a.scenarioInstance(s); err != nil {
+			sendScenarioInstanceError(resp, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	a.schedule, a.scheduleCancel, a.scheduleDone = scheduler, cancel, done
+	go func() {
+		defer close(done)
+		if err := scheduler.Run(ctx); err != nil {
+			a.loggerNoStack.Error("schedule failed", zap.Error(err))
+		}
+	}()
+
+	err = sendJSON(resp, MetaMessage{
+		Type:    APIMetaMessageTypeInfo,
+		Slug:    SlugServiceReady,
+		Title:   "Schedule started",
+		Message: fmt.Sprintf("%d phases scheduled", len(scheduler.phases)),
+	})
+	if err != nil {
+		a.loggerNoStack.Error("sendJSON failed", zap.Error(err))
+	}
+}
+
+// ScheduleStop implements POST /schedule/stop
+//
+// The services of the current phase are stopped.
+func (a *RESTApiV1) ScheduleStop(resp http.ResponseWriter, req *http.Request) {
+	if !a.stopSchedule() {
+		sendJSONError(resp,
+			MetaMessage{
+				Type:    APIMetaMessageTypeError,
+				Slug:    SlugServiceNotStarted,
+				Title:   "Schedule not started",
+				Message: "No schedule is running.",
+			},
+			http.StatusBadRequest)
+		return
+	}
+
+	err := sendJSON(resp, MetaMessage{
+		Type:    APIMetaMessageTypeInfo,
+		Slug:    SlugServiceNotReady,
+		Title:   "Schedule stopped",
+		Message: "Schedule stopped successfully",
+	})
+	if err != nil {
+		a.loggerNoStack.Error("sendJSON failed", zap.Error(err))
+	}
+}
+
+// stopSchedule stops the running schedule and waits for the services of its
+// current phase to stop. It reports whether a schedule was running.
+func (a *RESTApiV1) stopSchedule() bool {
+	// the lock is not held while the services stop, so the status stays
+	// available meanwhile
+	a.scheduleMu.Lock()
+	cancel, done := a.scheduleCancel, a.scheduleDone
+	a.scheduleMu.Unlock()
+
+	if done == nil {
+		return false
+	}
+	select {
+	case <-done:
+		return false
+	default:
+	}
+
+	cancel()
+	<-done
+	return true
+}
+
+// scheduleStatus returns the status of the last schedule started, if any.
+func (a *RESTApiV1) scheduleStatus() (ScheduleStatus, bool) {
+	a.scheduleMu.Lock()
+	defer a.scheduleMu.Unlock()
+
+	if a.schedule == nil {
+		return ScheduleStatus{}, false
+	}
+	return a.schedule.Status(), true
+}
+
+// startScheduledService starts a service of a schedule phase on the service
+// instance of its network interface, so it shows up in the services status.
+// It fails if the instance was started meanwhile, e.g. by a start request.
+// The returned function leaves the instance alone if the service was stopped
+// and another one started on it since.
+func (a *RESTApiV1) startScheduledService(s ScenarioService) (xdp.CancelFunc, error) {
+	ns, err := a.scenarioInstance(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ns.Start(s.NetworkInterfaceName, s.Service); err != nil {
+		if errors.Is(err, ErrServiceAlreadyStarted) {
+			return nil, fmt.Errorf("%w: %s is already running on %s", err, s.Name, s.NetworkInterfaceName)
+		}
+		return nil, err
+	}
+
+	return func() error {
+		return ns.StopService(s.Service)
+	}, nil
+}
@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *APITestSuite) TestScheduleStartStop() {
+	t := s.T()
+
+	body := fmt.Sprintf(`
+network_interface: %s
+phases:
+  - name: loss
+    duration: 300ms
+    packetloss:
+      - packet_loss_rate: 10
+  - name: dup
+    duplicate:
+      - duplicate_rate: 5
+`, s.ifaceName)
+
+	req, err := http.NewRequest(http.MethodPost, api.SchedulePath, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.ScheduleStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// A second schedule cannot start while the first one runs
+	req, err = http.NewRequest(http.MethodPost, api.SchedulePath, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	s.restAPI.ScheduleStart(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	require.Eventually(t, func() bool {
+		st := s.scheduleStatus()
+		return st != nil && st.Params["phase"] == "dup"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	slug, err := getServiceStatusSlug(s.restAPI.PacketlossStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug, "the services of a phase are stopped when it ends")
+
+	require.Eventually(t, func() bool {
+		slug, err := getServiceStatusSlug(s.restAPI.DuplicateStatus)
+		return err == nil && slug == api.SlugServiceReady
+	}, 5*time.Second, 50*time.Millisecond)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.ScheduleStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	slug, err = getServiceStatusSlug(s.restAPI.DuplicateStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+
+	st := s.scheduleStatus()
+	require.NotNil(t, st)
+	assert.False(t, st.Ready)
+
+	rr = httptest.NewRecorder()
+	s.restAPI.ScheduleStop(rr, nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+}
+
+func (s *APITestSuite) TestScheduleConflict() {
+	t := s.T()
+
+	body := fmt.Sprintf(`
+network_interface: %s
+phases:
+  - name: loss
+    duration: 300ms
+    packetloss:
+      - packet_loss_rate: 10
+  - name: dup
+    duplicate:
+      - duplicate_rate: 5
+`, s.ifaceName)
+
+	req, err := http.NewRequest(http.MethodPost, api.SchedulePath, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.restAPI.ScheduleStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// the service of the next phase is started by a request meanwhile
+	jsonBody, err := json.Marshal(api.DuplicateStartRequest{NetworkInterfaceName: s.ifaceName, DuplicateRate: 20})
+	require.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, api.DuplicatePath.Start(), bytes.NewReader(jsonBody))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	s.restAPI.DuplicateStart(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var st *api.ServiceStatus
+	require.Eventually(t, func() bool {
+		st = s.scheduleStatus()
+		return st != nil && !st.Ready
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Contains(t, st.Params["error"], "already running", "the failed schedule reports why")
+
+	slug, err := getServiceStatusSlug(s.restAPI.DuplicateStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceReady, slug, "the service started by the request is left running")
+
+	rr = httptest.NewRecorder()
+	s.restAPI.DuplicateStop(rr, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func (s *APITestSuite) TestScheduleInvalid() {
+	t := s.T()
+
+	for _, body := range []string{
+		// only the last phase may run until stopped
+		fmt.Sprintf(`{"network_interface": %q, "phases": [{"packetloss": [{"packet_loss_rate": 10}]}, {}]}`, s.ifaceName),
+		// the services of all the phases are validated up front
+		fmt.Sprintf(`{"network_interface": %q, "phases": [{"duration": "1s", "packetloss": [{"packet_loss_rate": 10}]}, {"duplicate": [{"duplicate_rate": 101}]}]}`, s.ifaceName),
+		fmt.Sprintf(`{"network_interface": %q, "phases": [{"duration": "-1s", "packetloss": [{"packet_loss_rate": 10}]}]}`, s.ifaceName),
+		fmt.Sprintf(`{"network_interface": %q, "packetloss": [{"packet_loss_rate": 10}]}`, s.ifaceName),
+	} {
+		req, err := http.NewRequest(http.MethodPost, api.SchedulePath, bytes.NewReader([]byte(body)))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.restAPI.ScheduleStart(rr, req)
+		require.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+
+	slug, err := getServiceStatusSlug(s.restAPI.PacketlossStatus)
+	require.NoError(t, err)
+	assert.Equal(t, api.SlugServiceNotReady, slug)
+}
+
+// scheduleStatus returns the schedule entry of the services status, if any.
+func (s *APITestSuite) scheduleStatus() *api.ServiceStatus {
+	rr := httptest.NewRecorder()
+	s.restAPI.NetServicesStatus(rr, nil)
+	if rr.Code != http.StatusOK {
+		return nil
+	}
+
+	var out []api.ServiceStatus
+	if err := json.NewDecoder(rr.Body).Decode(&out); err != nil {
+		return nil
+	}
+	for i := range out {
+		if out[i].Name == "schedule" {
+			return &out[i]
+		}
+	}
+	return nil
+}
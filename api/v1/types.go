@@ -1,9 +1,13 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"sync"
 
+	"github.com/celestiaorg/bittwister/xdp/packetloss"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -13,23 +17,162 @@ type RESTApiV1 struct {
 	logger        *zap.Logger
 	loggerNoStack *zap.Logger
 
-	pl, bw, lt *netRestrictService
+	pl, bw, lt, dp, ro, cr, pt *netServiceGroup
+
+	// see initMetrics
+	requestDuration *prometheus.HistogramVec
+	metricsHandler  http.Handler
+	metricsServer   *http.Server
+
+	authToken string // see WithAuthToken
+
+	// the last schedule started, see ScheduleStart
+	schedule       *Scheduler
+	scheduleCancel context.CancelFunc
+	scheduleDone   chan struct{}
+	scheduleMu     sync.Mutex
 
 	productionMode bool
 }
 
 type PacketLossStartRequest struct {
-	NetworkInterfaceName string `json:"network_interface"`
-	PacketLossRate       int32  `json:"packet_loss_rate"`
+	NetworkInterfaceName  string                `json:"network_interface"`
+	PacketLossRate        float64               `json:"packet_loss_rate"`                   // Percent, e.g. 0.01 for 0.01%
+	PacketLossRateIngress float64               `json:"packet_loss_rate_ingress,omitempty"` // Percent on ingress, instead of packet_loss_rate
+	PacketLossRateEgress  float64               `json:"packet_loss_rate_egress,omitempty"`  // Percent on egress, instead of packet_loss_rate
+	Model                 string                `json:"model,omitempty"`                    // "bernoulli" (default) or "gilbert-elliott"
+	Direction             string                `json:"direction,omitempty"`                // "ingress" (default), "egress" or "both"
+	Peers                 []string              `json:"peers,omitempty"`                    // CIDRs, default: all the traffic
+	Protocol              string                `json:"protocol,omitempty"`                 // "tcp", "udp" or "icmp", default: all the protocols
+	Ports                 string                `json:"ports,omitempty"`                    // e.g. "26656" or "26656-26660", default: all the ports
+	GilbertElliott        *GilbertElliottParams `json:"gilbert_elliott,omitempty"`          // parameters of the "gilbert-elliott" model
+}
+
+// GilbertElliottParams holds the parameters of the Gilbert-Elliott loss
+// model, in percent: the transition probabilities from the good to the bad
+// state (p) and back (r), and the loss probabilities in each state.
+type GilbertElliottParams struct {
+	P        float64 `json:"p"`
+	R        float64 `json:"r"`
+	LossGood float64 `json:"loss_good"`
+	LossBad  float64 `json:"loss_bad"`
+}
+
+func (g *GilbertElliottParams) model() packetloss.GilbertElliott {
+	if g == nil {
+		return packetloss.GilbertElliott{}
+	}
+	return packetloss.GilbertElliott{
+		P:        g.P,
+		R:        g.R,
+		LossGood: g.LossGood,
+		LossBad:  g.LossBad,
+	}
 }
 
 type BandwidthStartRequest struct {
-	NetworkInterfaceName string `json:"network_interface"`
-	Limit                int64  `json:"limit"`
+	NetworkInterfaceName string   `json:"network_interface"`
+	Limit                int64    `json:"limit"`                   // Bits per second
+	LimitIngress         int64    `json:"limit_ingress,omitempty"` // Bits per second on ingress, instead of limit
+	LimitEgress          int64    `json:"limit_egress,omitempty"`  // Bits per second on egress, instead of limit
+	Burst                int64    `json:"burst,omitempty"`         // Bytes, default: 100ms worth of traffic, at least 64KiB
+	Direction            string   `json:"direction,omitempty"`     // "ingress" (default), "egress" or "both"
+	Peers                []string `json:"peers,omitempty"`         // CIDRs, default: all the traffic
+	Protocol             string   `json:"protocol,omitempty"`      // "tcp", "udp" or "icmp", default: all the protocols
+	Ports                string   `json:"ports,omitempty"`         // e.g. "26656" or "26656-26660", default: all the ports
 }
 
 type LatencyStartRequest struct {
-	NetworkInterfaceName string `json:"network_interface"`
-	Latency              int64  `json:"latency_ms"`
-	Jitter               int64  `json:"jitter_ms"`
+	NetworkInterfaceName string   `json:"network_interface"`
+	Latency              int64    `json:"latency_ms"`
+	Jitter               int64    `json:"jitter_ms"`
+	LatencyDuration      string   `json:"latency,omitempty"`      // e.g. "150us" or "1.5ms", instead of latency_ms
+	JitterDuration       string   `json:"jitter,omitempty"`       // e.g. "20us", instead of jitter_ms
+	Distribution         string   `json:"distribution,omitempty"` // "normal", "pareto", "paretonormal" or the path of a .dist table, default: uniform
+	Correlation          float64  `json:"correlation,omitempty"`  // Percent, of the jitter with the one of the previous packet
+	Engine               string   `json:"engine,omitempty"`       // "tc" (default) or "ebpf"
+	Peers                []string `json:"peers,omitempty"`        // CIDRs, requires the "ebpf" engine
+	Protocol             string   `json:"protocol,omitempty"`     // "tcp", "udp" or "icmp", requires the "ebpf" engine
+	Ports                string   `json:"ports,omitempty"`        // e.g. "26656" or "26656-26660", requires the "ebpf" engine
+}
+
+type DuplicateStartRequest struct {
+	NetworkInterfaceName string   `json:"network_interface"`
+	DuplicateRate        float64  `json:"duplicate_rate"`     // Percent, e.g. 1 for 1%
+	Peers                []string `json:"peers,omitempty"`    // CIDRs, default: all the traffic
+	Protocol             string   `json:"protocol,omitempty"` // "tcp", "udp" or "icmp", default: all the protocols
+	Ports                string   `json:"ports,omitempty"`    // e.g. "26656" or "26656-26660", default: all the ports
+}
+
+type ReorderStartRequest struct {
+	NetworkInterfaceName string   `json:"network_interface"`
+	ReorderRate          float64  `json:"reorder_rate"`       // Percent, e.g. 25 for 25%
+	Gap                  uint32   `json:"gap,omitempty"`      // At least gap-1 packets are sent in order between two reordered packets
+	Delay                int64    `json:"delay_ms,omitempty"` // How long a reordered packet is held back, default: 10ms
+	Peers                []string `json:"peers,omitempty"`    // CIDRs, default: all the traffic
+	Protocol             string   `json:"protocol,omitempty"` // "tcp", "udp" or "icmp", default: all the protocols
+	Ports                string   `json:"ports,omitempty"`    // e.g. "26656" or "26656-26660", default: all the ports
+}
+
+type CorruptStartRequest struct {
+	NetworkInterfaceName string   `json:"network_interface"`
+	CorruptRate          float64  `json:"corrupt_rate"`           // Percent, e.g. 1 for 1%
+	PayloadOnly          bool     `json:"payload_only,omitempty"` // Only flip the bits of the TCP/UDP payload
+	FixChecksum          bool     `json:"fix_checksum,omitempty"` // Fix the TCP/UDP checksum, so the application gets the corrupted payload
+	Peers                []string `json:"peers,omitempty"`        // CIDRs, default: all the traffic
+	Protocol             string   `json:"protocol,omitempty"`     // "tcp", "udp" or "icmp", default: all the protocols
+	Ports                string   `json:"ports,omitempty"`        // e.g. "26656" or "26656-26660", default: all the ports
+}
+
+type PartitionStartRequest struct {
+	NetworkInterfaceName string   `json:"network_interface"`
+	Peers                []string `json:"peers"` // CIDRs, all the traffic to and from them is dropped
+}
+
+// PacketLossUpdateRequest holds the parameters of a running packetloss
+// service that can be changed without stopping it.
+type PacketLossUpdateRequest struct {
+	NetworkInterfaceName  string                `json:"network_interface,omitempty"`        // default: all the running instances
+	PacketLossRate        float64               `json:"packet_loss_rate"`                   // Percent, e.g. 0.01 for 0.01%
+	PacketLossRateIngress float64               `json:"packet_loss_rate_ingress,omitempty"` // Percent on ingress, instead of packet_loss_rate
+	PacketLossRateEgress  float64               `json:"packet_loss_rate_egress,omitempty"`  // Percent on egress, instead of packet_loss_rate
+	Model                 string                `json:"model,omitempty"`                    // "bernoulli" (default) or "gilbert-elliott"
+	GilbertElliott        *GilbertElliottParams `json:"gilbert_elliott,omitempty"`          // parameters of the "gilbert-elliott" model
+}
+
+type BandwidthUpdateRequest struct {
+	NetworkInterfaceName string `json:"network_interface,omitempty"` // default: all the running instances
+	Limit                int64  `json:"limit"`                       // Bits per second
+	LimitIngress         int64  `json:"limit_ingress,omitempty"`     // Bits per second on ingress, instead of limit
+	LimitEgress          int64  `json:"limit_egress,omitempty"`      // Bits per second on egress, instead of limit
+	Burst                int64  `json:"burst,omitempty"`             // Bytes, default: 100ms worth of traffic, at least 64KiB
+}
+
+type LatencyUpdateRequest struct {
+	NetworkInterfaceName string  `json:"network_interface,omitempty"` // default: all the running instances
+	Latency              int64   `json:"latency_ms"`
+	Jitter               int64   `json:"jitter_ms"`
+	LatencyDuration      string  `json:"latency,omitempty"`      // e.g. "150us", instead of latency_ms
+	JitterDuration       string  `json:"jitter,omitempty"`       // e.g. "20us", instead of jitter_ms
+	Distribution         string  `json:"distribution,omitempty"` // see LatencyStartRequest
+	Correlation          float64 `json:"correlation,omitempty"`  // Percent
+}
+
+type DuplicateUpdateRequest struct {
+	NetworkInterfaceName string  `json:"network_interface,omitempty"` // default: all the running instances
+	DuplicateRate        float64 `json:"duplicate_rate"`              // Percent, e.g. 1 for 1%
+}
+
+type ReorderUpdateRequest struct {
+	NetworkInterfaceName string  `json:"network_interface,omitempty"` // default: all the running instances
+	ReorderRate          float64 `json:"reorder_rate"`                // Percent, e.g. 25 for 25%
+	Gap                  uint32  `json:"gap,omitempty"`               // At least gap-1 packets are sent in order between two reordered packets
+	Delay                int64   `json:"delay_ms,omitempty"`          // How long a reordered packet is held back, default: 10ms
+}
+
+type CorruptUpdateRequest struct {
+	NetworkInterfaceName string  `json:"network_interface,omitempty"` // default: all the running instances
+	CorruptRate          float64 `json:"corrupt_rate"`                // Percent, e.g. 1 for 1%
+	PayloadOnly          bool    `json:"payload_only,omitempty"`      // Only flip the bits of the TCP/UDP payload
+	FixChecksum          bool    `json:"fix_checksum,omitempty"`      // Fix the TCP/UDP checksum, so the application gets the corrupted payload
 }
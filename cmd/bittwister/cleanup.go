@@ -0,0 +1,117 @@
+package bittwister
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/celestiaorg/bittwister/xdp/qdisc"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var flagsCleanup struct {
+	force          bool
+	pinPath        string
+	logLevel       string
+	productionMode bool
+}
+
+func init() {
+	rootCmd.AddCommand(cleanupCmd)
+
+	cleanupCmd.PersistentFlags().BoolVar(&flagsCleanup.force, "force", false, "also remove the qdiscs and the pinned objects of the bittwister processes which still run")
+	cleanupCmd.PersistentFlags().StringVar(&flagsCleanup.pinPath, flagPinPath, xdp.DefaultPinPath, "bpffs directory of the pinned BPF objects to remove")
+	cleanupCmd.PersistentFlags().StringVar(&flagsCleanup.logLevel, flagLogLevel, "info", "log level (e.g. debug, info, warn, error, dpanic, panic, fatal)")
+	cleanupCmd.PersistentFlags().BoolVar(&flagsCleanup.productionMode, flagProductionMode, false, "production mode (e.g. disable debug logs)")
+}
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "removes the qdiscs and the BPF objects left behind by a bittwister process",
+	Long: `Removes the BPF objects pinned by bittwister processes which are gone, which
+detaches their programs, and the qdiscs they installed: the netem qdisc with
+its ifb device and the redirection of the egress traffic, and the root fq
+qdisc. An fq qdisc which was replaced since, e.g. by the user, is left alone.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger, err := getLogger(flagsCleanup.logLevel, flagsCleanup.productionMode)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// The error is ignored because of this issue: https://github.com/uber-go/zap/issues/328
+			_ = logger.Sync()
+		}()
+
+		var errs []error
+		if err := cleanupPins(logger, flagsCleanup.pinPath, flagsCleanup.force); err != nil {
+			errs = append(errs, err)
+		}
+
+		records, err := qdisc.Records()
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		for _, r := range records {
+			if r.Alive() && !flagsCleanup.force {
+				logger.Info("qdisc still used by a running bittwister process, skipped",
+					zap.String("device", r.NetworkInterfaceName),
+					zap.String("kind", r.Kind),
+					zap.Int("pid", r.PID))
+				continue
+			}
+
+			deleted, err := qdisc.Cleanup(r)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", r, err))
+				continue
+			}
+			msg := "qdisc removed"
+			if !deleted {
+				msg = "qdisc already gone or replaced, record dropped"
+			}
+			logger.Info(msg,
+				zap.String("device", r.NetworkInterfaceName),
+				zap.String("kind", r.Kind),
+				zap.Int("pid", r.PID))
+		}
+
+		if len(records) == 0 {
+			logger.Info("no qdisc to clean up")
+		}
+		return errors.Join(errs...)
+	},
+}
+
+// cleanupPins removes the objects pinned in the directory, unless a running
+// bittwister process uses them and force is not set.
+func cleanupPins(logger *zap.Logger, pinPath string, force bool) error {
+	entries, err := os.ReadDir(pinPath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read pinned objects: %w", err)
+	}
+
+	inUse, err := xdp.PinsInUse(pinPath)
+	if err != nil {
+		return fmt.Errorf("check pinned objects: %w", err)
+	}
+	if inUse && !force {
+		logger.Info("pinned objects still used by a running bittwister process, skipped", zap.String("path", pinPath))
+		return nil
+	}
+
+	for _, e := range entries {
+		if err := xdp.RemovePins(filepath.Join(pinPath, e.Name())); err != nil {
+			return fmt.Errorf("remove pinned objects: %w", err)
+		}
+	}
+	logger.Info("pinned objects removed", zap.String("path", pinPath))
+	return nil
+}
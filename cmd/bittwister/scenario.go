@@ -0,0 +1,123 @@
+package bittwister
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/celestiaorg/bittwister/xdp/latency"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// ensureNoServiceFlags returns an error if a service flag is given along
+// with a scenario file, which describes all the services to start.
+func ensureNoServiceFlags(cmd *cobra.Command) error {
+	var flags []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		switch f.Name {
+		case flagConfig, flagNetworkInterfaceName, flagTcBinPath, flagLogLevel, flagProductionMode:
+		default:
+			flags = append(flags, "--"+f.Name)
+		}
+	})
+
+	if len(flags) > 0 {
+		return fmt.Errorf("%s cannot be used with --%s, the services are set in the scenario file", strings.Join(flags, ", "), flagConfig)
+	}
+	return nil
+}
+
+// loadScenario reads the scenario file, defaultIfaceName is used if the
+// scenario does not name a network interface.
+func loadScenario(path, defaultIfaceName string) (*api.Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+
+	scenario, err := api.ParseScenario(data)
+	if err != nil {
+		return nil, err
+	}
+	if scenario.NetworkInterfaceName == "" {
+		scenario.NetworkInterfaceName = defaultIfaceName
+	}
+	return scenario, nil
+}
+
+// startScenario starts the services of the scenario, after validating all of
+// them. It returns a function stopping them.
+func startScenario(logger *zap.Logger, scenario *api.Scenario, tcBinPath string) (func(), error) {
+	services, err := scenario.Services()
+	if err != nil {
+		return nil, err
+	}
+
+	cancels := make([]xdp.CancelFunc, 0, len(services))
+	stop := func() {
+		for i := len(cancels) - 1; i >= 0; i-- {
+			s := services[i]
+			if err := cancels[i](); err != nil {
+				logger.Error("cancel "+s.Name, zap.Error(err))
+			}
+			logger.Info("Scenario service stopped", zap.String("service", s.Name), zap.String("device", s.NetworkInterfaceName))
+		}
+	}
+
+	for _, s := range services {
+		if l, ok := s.Service.(*latency.Latency); ok {
+			l.TcBinPath = tcBinPath
+		}
+
+		cancel, err := s.Service.Start()
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("start %s on %s: %w", s.Name, s.NetworkInterfaceName, err)
+		}
+		cancels = append(cancels, cancel)
+		logger.Info("Scenario service started", zap.String("service", s.Name), zap.String("device", s.NetworkInterfaceName))
+	}
+
+	return stop, nil
+}
+
+// runSchedule runs the phases of the scenario until the last one ends or the
+// process receives an interrupt signal.
+func runSchedule(logger *zap.Logger, scenario *api.Scenario, tcBinPath string) error {
+	startService := func(s api.ScenarioService) (xdp.CancelFunc, error) {
+		if l, ok := s.Service.(*latency.Latency); ok {
+			l.TcBinPath = tcBinPath
+		}
+
+		cancel, err := s.Service.Start()
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Scheduled service started", zap.String("service", s.Name), zap.String("device", s.NetworkInterfaceName))
+
+		return func() error {
+			if err := cancel(); err != nil {
+				return fmt.Errorf("cancel %s: %w", s.Name, err)
+			}
+			logger.Info("Scheduled service stopped", zap.String("service", s.Name), zap.String("device", s.NetworkInterfaceName))
+			return nil
+		}, nil
+	}
+
+	scheduler, err := api.NewScheduler(scenario, startService, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT)
+	defer stop()
+
+	return scheduler.Run(ctx)
+}
@@ -1,18 +1,33 @@
 package bittwister
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	api "github.com/celestiaorg/bittwister/api/v1"
+	"github.com/celestiaorg/bittwister/xdp"
 	"github.com/spf13/cobra"
 )
 
 const (
-	flagServeAddr = "serve-addr"
+	flagServeAddr   = "serve-addr"
+	flagMetricsAddr = "metrics-addr"
+	flagPinPath     = "pin-path"
+
+	// envAuthToken is read when --auth-token-file is not given
+	envAuthToken = "BITTWISTER_AUTH_TOKEN"
 )
 
 var flagsServe struct {
 	serveAddr      string
+	metricsAddr    string
+	pinPath        string
+	authTokenFile  string
+	tlsCert        string
+	tlsKey         string
+	tlsClientCA    string
 	originAllowed  string
 	logLevel       string
 	productionMode bool
@@ -22,6 +37,12 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 
 	serveCmd.PersistentFlags().StringVar(&flagsServe.serveAddr, flagServeAddr, ":9007", "address to serve on")
+	serveCmd.PersistentFlags().StringVar(&flagsServe.metricsAddr, flagMetricsAddr, "", "address to also serve the Prometheus metrics on, e.g. :9008 (default: only on the serve address)")
+	serveCmd.PersistentFlags().StringVar(&flagsServe.authTokenFile, "auth-token-file", "", "file holding the bearer token required by the API (default: the "+envAuthToken+" environment variable, no authentication if empty)")
+	serveCmd.PersistentFlags().StringVar(&flagsServe.tlsCert, "tls-cert", "", "certificate file to serve the API over HTTPS")
+	serveCmd.PersistentFlags().StringVar(&flagsServe.tlsKey, "tls-key", "", "key file of the TLS certificate")
+	serveCmd.PersistentFlags().StringVar(&flagsServe.tlsClientCA, "tls-client-ca", "", "CA file the client certificates must be signed with (mutual TLS)")
+	serveCmd.PersistentFlags().StringVar(&flagsServe.pinPath, flagPinPath, "", "bpffs directory to pin the BPF objects in, so the services outlive the server and the next one adopts them, e.g. "+xdp.DefaultPinPath)
 	serveCmd.PersistentFlags().StringVar(&flagsServe.originAllowed, "origin-allowed", "*", "origin allowed for CORS")
 
 	serveCmd.PersistentFlags().StringVar(&flagsServe.logLevel, flagLogLevel, "info", "log level (e.g. debug, info, warn, error, dpanic, panic, fatal)")
@@ -44,9 +65,58 @@ var serveCmd = &cobra.Command{
 
 		logger.Info("Starting the API server...")
 
+		token, err := readAuthToken(flagsServe.authTokenFile)
+		if err != nil {
+			return err
+		}
+		opts := []api.ServeOption{api.WithAuthToken(token)}
+		if token == "" {
+			logger.Warn("the API accepts requests without authentication, see --auth-token-file")
+		}
+		if flagsServe.tlsCert != "" || flagsServe.tlsKey != "" {
+			opts = append(opts, api.WithTLS(flagsServe.tlsCert, flagsServe.tlsKey))
+		}
+		if flagsServe.tlsClientCA != "" {
+			opts = append(opts, api.WithClientCA(flagsServe.tlsClientCA))
+		}
+
 		restAPI := api.NewRESTApiV1(flagsServe.productionMode, logger)
-		logger.Fatal(fmt.Sprintf("REST API server: %v", restAPI.Serve(flagsServe.serveAddr, flagsServe.originAllowed)))
+		if flagsServe.pinPath != "" {
+			xdp.PinPath = flagsServe.pinPath
+			if err := restAPI.AdoptPinned(); err != nil {
+				return fmt.Errorf("adopt the pinned services: %w", err)
+			}
+			warnLeftovers(logger, "")
+		} else {
+			warnLeftovers(logger, xdp.DefaultPinPath)
+		}
+
+		if flagsServe.metricsAddr != "" {
+			go func() {
+				logger.Info(fmt.Sprintf("serving metrics on %s", flagsServe.metricsAddr))
+				logger.Fatal(fmt.Sprintf("metrics server: %v", restAPI.ServeMetrics(flagsServe.metricsAddr)))
+			}()
+		}
+		logger.Fatal(fmt.Sprintf("REST API server: %v", restAPI.Serve(flagsServe.serveAddr, flagsServe.originAllowed, opts...)))
 
 		return nil
 	},
 }
+
+// readAuthToken returns the API token of the file, or of the environment if
+// no file is given.
+func readAuthToken(file string) (string, error) {
+	if file == "" {
+		return strings.TrimSpace(os.Getenv(envAuthToken)), nil
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read auth token: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", errors.New("the auth token file is empty")
+	}
+	return token, nil
+}
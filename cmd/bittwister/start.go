@@ -3,14 +3,16 @@ package bittwister
 import (
 	"fmt"
 	"net"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"github.com/celestiaorg/bittwister/xdp"
 	"github.com/celestiaorg/bittwister/xdp/bandwidth"
+	"github.com/celestiaorg/bittwister/xdp/corrupt"
+	"github.com/celestiaorg/bittwister/xdp/duplicate"
 	"github.com/celestiaorg/bittwister/xdp/latency"
 	"github.com/celestiaorg/bittwister/xdp/packetloss"
+	"github.com/celestiaorg/bittwister/xdp/partition"
+	"github.com/celestiaorg/bittwister/xdp/reorder"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -24,15 +26,96 @@ const (
 	flagLatency              = "latency"
 	flagJitter               = "jitter"
 	flagTcBinPath            = "tc-path"
+	flagLatencyEngine        = "latency-engine"
+	flagJitterDistribution   = "jitter-distribution"
+	flagJitterCorrelation    = "jitter-correlation"
+	flagPacketLossDirection  = "packet-loss-direction"
+	flagBandwidthDirection   = "bandwidth-direction"
+	flagBandwidthBurst       = "bandwidth-burst"
+	flagBandwidthIngress     = "bandwidth-ingress"
+	flagBandwidthEgress      = "bandwidth-egress"
+	flagPacketLossRateIn     = "packet-loss-rate-ingress"
+	flagPacketLossRateOut    = "packet-loss-rate-egress"
+	flagPacketLossPeers      = "packet-loss-peers"
+	flagBandwidthPeers       = "bandwidth-peers"
+	flagLatencyPeers         = "latency-peers"
+	flagPacketLossProtocol   = "packet-loss-protocol"
+	flagPacketLossPorts      = "packet-loss-ports"
+	flagBandwidthProtocol    = "bandwidth-protocol"
+	flagBandwidthPorts       = "bandwidth-ports"
+	flagLatencyProtocol      = "latency-protocol"
+	flagLatencyPorts         = "latency-ports"
+	flagDuplicateRate        = "duplicate-rate"
+	flagDuplicatePeers       = "duplicate-peers"
+	flagDuplicateProtocol    = "duplicate-protocol"
+	flagDuplicatePorts       = "duplicate-ports"
+	flagReorderRate          = "reorder-rate"
+	flagReorderGap           = "reorder-gap"
+	flagReorderDelay         = "reorder-delay"
+	flagReorderPeers         = "reorder-peers"
+	flagReorderProtocol      = "reorder-protocol"
+	flagReorderPorts         = "reorder-ports"
+	flagCorruptRate          = "corrupt-rate"
+	flagCorruptPayloadOnly   = "corrupt-payload-only"
+	flagCorruptFixChecksum   = "corrupt-fix-checksum"
+	flagCorruptPeers         = "corrupt-peers"
+	flagCorruptProtocol      = "corrupt-protocol"
+	flagCorruptPorts         = "corrupt-ports"
+	flagPartition            = "partition"
+	flagConfig               = "config"
+	flagLossModel            = "loss-model"
+	flagLossGEP              = "loss-ge-p"
+	flagLossGER              = "loss-ge-r"
+	flagLossGEGood           = "loss-ge-good"
+	flagLossGEBad            = "loss-ge-bad"
 )
 
 var flagsStart struct {
 	networkInterfaceName string
-	packetLossRate       int32
+	configPath           string
+	packetLossRate       float64
+	packetLossRateIn     float64
+	packetLossRateOut    float64
 	bandwidth            int64
-	latency              int64
-	jitter               int64
+	bandwidthIngress     int64
+	bandwidthEgress      int64
+	bandwidthBurst       int64
+	latency              msDuration
+	jitter               msDuration
 	tcBinPath            string
+	latencyEngine        string
+	jitterDistribution   string
+	jitterCorrelation    float64
+	packetLossDirection  string
+	bandwidthDirection   string
+	packetLossPeers      []string
+	bandwidthPeers       []string
+	latencyPeers         []string
+	packetLossProtocol   string
+	packetLossPorts      string
+	bandwidthProtocol    string
+	bandwidthPorts       string
+	latencyProtocol      string
+	latencyPorts         string
+	duplicateRate        float64
+	duplicatePeers       []string
+	duplicateProtocol    string
+	duplicatePorts       string
+	reorderRate          float64
+	reorderGap           uint32
+	reorderDelay         int64
+	reorderPeers         []string
+	reorderProtocol      string
+	reorderPorts         string
+	corruptRate          float64
+	corruptPayloadOnly   bool
+	corruptFixChecksum   bool
+	corruptPeers         []string
+	corruptProtocol      string
+	corruptPorts         string
+	partitionPeers       []string
+	lossModel            string
+	lossGilbertElliott   packetloss.GilbertElliott
 
 	logLevel       string
 	productionMode bool
@@ -41,12 +124,55 @@ var flagsStart struct {
 func init() {
 	rootCmd.AddCommand(startCmd)
 
-	startCmd.PersistentFlags().Int32VarP(&flagsStart.packetLossRate, flagPacketLossRate, "p", 0, "packet loss rate (e.g. 10 for 10% packet loss)")
+	startCmd.PersistentFlags().Float64VarP(&flagsStart.packetLossRate, flagPacketLossRate, "p", 0, "packet loss rate in percent, down to 0.0001 (e.g. 10 for 10%, 0.01 for 0.01% packet loss)")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.packetLossRateIn, flagPacketLossRateIn, 0, "ingress packet loss rate in percent, instead of --packet-loss-rate (e.g. 1 for 1%)")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.packetLossRateOut, flagPacketLossRateOut, 0, "egress packet loss rate in percent, instead of --packet-loss-rate (e.g. 5 for 5%)")
+	startCmd.PersistentFlags().StringVar(&flagsStart.lossModel, flagLossModel, packetloss.ModelBernoulli, "packet loss model (e.g. bernoulli for independent losses at the packet loss rate, gilbert-elliott for bursty losses)")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.lossGilbertElliott.P, flagLossGEP, 0, "gilbert-elliott probability in percent to go from the good to the bad state (e.g. 1 for 1%)")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.lossGilbertElliott.R, flagLossGER, 0, "gilbert-elliott probability in percent to go from the bad to the good state (e.g. 30 for 30%)")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.lossGilbertElliott.LossGood, flagLossGEGood, 0, "gilbert-elliott packet loss rate in percent in the good state")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.lossGilbertElliott.LossBad, flagLossGEBad, 100, "gilbert-elliott packet loss rate in percent in the bad state")
 	startCmd.PersistentFlags().StringVarP(&flagsStart.networkInterfaceName, flagNetworkInterfaceName, "d", "", "network interface name")
+	startCmd.PersistentFlags().StringVar(&flagsStart.configPath, flagConfig, "", "scenario file in YAML or JSON to start instead of the service flags, -d is the default network interface of its services")
 	startCmd.PersistentFlags().Int64VarP(&flagsStart.bandwidth, flagBandwidth, "b", 0, "bandwidth limit in bps (e.g. 1000 for 1Kbps)")
-	startCmd.PersistentFlags().Int64VarP(&flagsStart.latency, flagLatency, "l", 0, "latency in milliseconds (e.g. 100 for 100ms)")
-	startCmd.PersistentFlags().Int64VarP(&flagsStart.jitter, flagJitter, "j", 0, "jitter in milliseconds (e.g. 10 for 10ms)")
+	startCmd.PersistentFlags().Int64Var(&flagsStart.bandwidthIngress, flagBandwidthIngress, 0, "ingress bandwidth limit in bps, instead of --bandwidth (e.g. 20000000 for 20Mbps)")
+	startCmd.PersistentFlags().Int64Var(&flagsStart.bandwidthEgress, flagBandwidthEgress, 0, "egress bandwidth limit in bps, instead of --bandwidth (e.g. 1000000 for 1Mbps)")
+	startCmd.PersistentFlags().VarP(&flagsStart.latency, flagLatency, "l", "latency in milliseconds or as a duration (e.g. 100 for 100ms, 150us)")
+	startCmd.PersistentFlags().VarP(&flagsStart.jitter, flagJitter, "j", "jitter in milliseconds or as a duration (e.g. 10 for 10ms, 20us)")
 	startCmd.PersistentFlags().StringVar(&flagsStart.tcBinPath, flagTcBinPath, "tc", "path to tc binary")
+	startCmd.PersistentFlags().StringVar(&flagsStart.packetLossDirection, flagPacketLossDirection, string(xdp.DirectionIngress), "packet loss traffic direction (e.g. ingress, egress or both)")
+	startCmd.PersistentFlags().Int64Var(&flagsStart.bandwidthBurst, flagBandwidthBurst, 0, "bandwidth limit burst size in bytes (default 100ms worth of traffic, at least 64KiB)")
+	startCmd.PersistentFlags().StringVar(&flagsStart.bandwidthDirection, flagBandwidthDirection, string(xdp.DirectionIngress), "bandwidth limit traffic direction (e.g. ingress, egress or both)")
+	startCmd.PersistentFlags().StringVar(&flagsStart.latencyEngine, flagLatencyEngine, latency.EngineTc, "latency/jitter engine (e.g. tc for netem, ebpf for the TC-BPF program with fq)")
+	startCmd.PersistentFlags().StringVar(&flagsStart.jitterDistribution, flagJitterDistribution, "", "jitter distribution, requires the tc engine (e.g. normal, pareto, paretonormal or the path of a .dist table), default: uniform")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.jitterCorrelation, flagJitterCorrelation, 0, "jitter correlation percentage with the previous packet, requires the tc engine (e.g. 25 for 25%)")
+	startCmd.PersistentFlags().StringSliceVar(&flagsStart.packetLossPeers, flagPacketLossPeers, nil, "packet loss peers in CIDR notation (e.g. 10.0.0.0/24,fd00::1), default: all the traffic")
+	startCmd.PersistentFlags().StringSliceVar(&flagsStart.bandwidthPeers, flagBandwidthPeers, nil, "bandwidth limit peers in CIDR notation (e.g. 10.0.0.0/24,fd00::1), default: all the traffic")
+	startCmd.PersistentFlags().StringSliceVar(&flagsStart.latencyPeers, flagLatencyPeers, nil, "latency/jitter peers in CIDR notation, requires the ebpf engine (e.g. 10.0.0.0/24,fd00::1), default: all the traffic")
+	startCmd.PersistentFlags().StringVar(&flagsStart.packetLossProtocol, flagPacketLossProtocol, "", "packet loss transport protocol (e.g. tcp, udp or icmp), default: all the protocols")
+	startCmd.PersistentFlags().StringVar(&flagsStart.packetLossPorts, flagPacketLossPorts, "", "packet loss TCP/UDP source or destination ports (e.g. 26656 or 26656-26660), default: all the ports")
+	startCmd.PersistentFlags().StringVar(&flagsStart.bandwidthProtocol, flagBandwidthProtocol, "", "bandwidth limit transport protocol (e.g. tcp, udp or icmp), default: all the protocols")
+	startCmd.PersistentFlags().StringVar(&flagsStart.bandwidthPorts, flagBandwidthPorts, "", "bandwidth limit TCP/UDP source or destination ports (e.g. 26656 or 26656-26660), default: all the ports")
+	startCmd.PersistentFlags().StringVar(&flagsStart.latencyProtocol, flagLatencyProtocol, "", "latency/jitter transport protocol, requires the ebpf engine (e.g. tcp, udp or icmp), default: all the protocols")
+	startCmd.PersistentFlags().StringVar(&flagsStart.latencyPorts, flagLatencyPorts, "", "latency/jitter TCP/UDP source or destination ports, requires the ebpf engine (e.g. 26656 or 26656-26660), default: all the ports")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.duplicateRate, flagDuplicateRate, 0, "egress packet duplicate rate in percent (e.g. 1 for 1% duplicated packets)")
+	startCmd.PersistentFlags().StringSliceVar(&flagsStart.duplicatePeers, flagDuplicatePeers, nil, "duplicate peers in CIDR notation (e.g. 10.0.0.0/24,fd00::1), default: all the traffic")
+	startCmd.PersistentFlags().StringVar(&flagsStart.duplicateProtocol, flagDuplicateProtocol, "", "duplicate transport protocol (e.g. tcp, udp or icmp), default: all the protocols")
+	startCmd.PersistentFlags().StringVar(&flagsStart.duplicatePorts, flagDuplicatePorts, "", "duplicate TCP/UDP source or destination ports (e.g. 26656 or 26656-26660), default: all the ports")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.reorderRate, flagReorderRate, 0, "egress packet reorder rate in percent (e.g. 25 for 25% reordered packets), requires the fq qdisc")
+	startCmd.PersistentFlags().Uint32Var(&flagsStart.reorderGap, flagReorderGap, 0, "minimum number of packets between two reordered packets (e.g. 5 sends at least 4 packets in order in between)")
+	startCmd.PersistentFlags().Int64Var(&flagsStart.reorderDelay, flagReorderDelay, reorder.DefaultDelay.Milliseconds(), "delay in milliseconds of the reordered packets, so the next packets overtake them (e.g. 10 for 10ms)")
+	startCmd.PersistentFlags().StringSliceVar(&flagsStart.reorderPeers, flagReorderPeers, nil, "reorder peers in CIDR notation (e.g. 10.0.0.0/24,fd00::1), default: all the traffic")
+	startCmd.PersistentFlags().StringVar(&flagsStart.reorderProtocol, flagReorderProtocol, "", "reorder transport protocol (e.g. tcp, udp or icmp), default: all the protocols")
+	startCmd.PersistentFlags().StringVar(&flagsStart.reorderPorts, flagReorderPorts, "", "reorder TCP/UDP source or destination ports (e.g. 26656 or 26656-26660), default: all the ports")
+	startCmd.PersistentFlags().Float64Var(&flagsStart.corruptRate, flagCorruptRate, 0, "egress packet corrupt rate in percent, one random bit is flipped per corrupted packet (e.g. 1 for 1% corrupted packets)")
+	startCmd.PersistentFlags().BoolVar(&flagsStart.corruptPayloadOnly, flagCorruptPayloadOnly, false, "only corrupt the TCP/UDP payload, so the TCP/UDP checksum catches it")
+	startCmd.PersistentFlags().BoolVar(&flagsStart.corruptFixChecksum, flagCorruptFixChecksum, false, "fix the TCP/UDP checksum of the corrupted payload, so the corruption reaches the application (implies --corrupt-payload-only)")
+	startCmd.PersistentFlags().StringSliceVar(&flagsStart.corruptPeers, flagCorruptPeers, nil, "corrupt peers in CIDR notation (e.g. 10.0.0.0/24,fd00::1), default: all the traffic")
+	startCmd.PersistentFlags().StringVar(&flagsStart.corruptProtocol, flagCorruptProtocol, "", "corrupt transport protocol (e.g. tcp, udp or icmp), default: all the protocols")
+	startCmd.PersistentFlags().StringVar(&flagsStart.corruptPorts, flagCorruptPorts, "", "corrupt TCP/UDP source or destination ports (e.g. 26656 or 26656-26660), default: all the ports")
+
+	startCmd.PersistentFlags().StringSliceVar(&flagsStart.partitionPeers, flagPartition, nil, "drop all the traffic to and from the peers in CIDR notation (e.g. 10.0.0.0/24,fd00::1)")
 
 	startCmd.PersistentFlags().StringVar(&flagsStart.logLevel, flagLogLevel, "info", "log level (e.g. debug, info, warn, error, dpanic, panic, fatal)")
 	startCmd.PersistentFlags().BoolVar(&flagsStart.productionMode, flagProductionMode, false, "production mode (e.g. disable debug logs)")
@@ -67,6 +193,30 @@ var startCmd = &cobra.Command{
 		}()
 
 		logger.Info("Starting Bit Twister...")
+		warnLeftovers(logger, xdp.DefaultPinPath)
+
+		if flagsStart.configPath != "" {
+			if err := ensureNoServiceFlags(cmd); err != nil {
+				return err
+			}
+
+			scenario, err := loadScenario(flagsStart.configPath, flagsStart.networkInterfaceName)
+			if err != nil {
+				return err
+			}
+			if len(scenario.Phases) > 0 {
+				return runSchedule(logger, scenario, flagsStart.tcBinPath)
+			}
+
+			stop, err := startScenario(logger, scenario, flagsStart.tcBinPath)
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			waitForInterrupt(logger)
+			return nil
+		}
 
 		iface, err := net.InterfaceByName(flagsStart.networkInterfaceName)
 		if err != nil {
@@ -75,16 +225,68 @@ var startCmd = &cobra.Command{
 
 		/*---------*/
 
-		if flagsStart.packetLossRate > 0 {
-			pl := packetloss.PacketLoss{
-				PacketLossRate:   flagsStart.packetLossRate,
+		if len(flagsStart.partitionPeers) > 0 {
+			peers, err := xdp.ParsePeers(flagsStart.partitionPeers)
+			if err != nil {
+				return err
+			}
+			pt := partition.Partition{
 				NetworkInterface: iface,
+				Peers:            peers,
+			}
+			cancel, err := pt.Start()
+			if err != nil {
+				return err
+			}
+			logger.Info("Partition started",
+				zap.Strings("peers", flagsStart.partitionPeers),
+				zap.String("device", flagsStart.networkInterfaceName))
+			defer func() {
+				if err := cancel(); err != nil {
+					logger.Error("cancel partition", zap.Error(err))
+				}
+				logger.Info("Partition stopped", zap.String("device", flagsStart.networkInterfaceName))
+			}()
+		}
+
+		/*---------*/
+
+		plAsymmetric := flagsStart.packetLossRateIn > 0 || flagsStart.packetLossRateOut > 0
+		if flagsStart.packetLossRate > 0 || plAsymmetric || flagsStart.lossModel == packetloss.ModelGilbertElliott {
+			peers, err := xdp.ParsePeers(flagsStart.packetLossPeers)
+			if err != nil {
+				return err
+			}
+			ports, err := xdp.ParsePortRange(flagsStart.packetLossPorts)
+			if err != nil {
+				return err
+			}
+			pl := packetloss.PacketLoss{
+				PacketLossRate:        flagsStart.packetLossRate,
+				PacketLossRateIngress: flagsStart.packetLossRateIn,
+				PacketLossRateEgress:  flagsStart.packetLossRateOut,
+				Model:                 flagsStart.lossModel,
+				GilbertElliott:        flagsStart.lossGilbertElliott,
+				NetworkInterface:      iface,
+				Direction:             serviceDirection(cmd, flagPacketLossDirection, flagsStart.packetLossDirection, plAsymmetric),
+				Peers:                 peers,
+				Protocol:              xdp.Protocol(flagsStart.packetLossProtocol),
+				Ports:                 ports,
 			}
 			cancel, err := pl.Start()
 			if err != nil {
 				return err
 			}
-			logger.Info("Packetloss started", zap.Int32("rate (%)", flagsStart.packetLossRate), zap.String("device", flagsStart.networkInterfaceName))
+			logger.Info("Packetloss started",
+				zap.Float64("rate (%)", flagsStart.packetLossRate),
+				zap.Float64("ingress rate (%)", flagsStart.packetLossRateIn),
+				zap.Float64("egress rate (%)", flagsStart.packetLossRateOut),
+				zap.String("model", flagsStart.lossModel),
+				zap.String("direction", string(pl.Direction)),
+				zap.Strings("peers", flagsStart.packetLossPeers),
+				zap.String("protocol", flagsStart.packetLossProtocol),
+				zap.String("ports", flagsStart.packetLossPorts),
+				zap.String("device", flagsStart.networkInterfaceName))
 			defer func() {
 				if err := cancel(); err != nil {
 					logger.Error("cancel packetloss", zap.Error(err))
@@ -95,16 +297,41 @@ var startCmd = &cobra.Command{
 
 		/*---------*/
 
-		if flagsStart.bandwidth > 0 {
+		bwAsymmetric := flagsStart.bandwidthIngress > 0 || flagsStart.bandwidthEgress > 0
+		if flagsStart.bandwidth > 0 || bwAsymmetric {
+			peers, err := xdp.ParsePeers(flagsStart.bandwidthPeers)
+			if err != nil {
+				return err
+			}
+			ports, err := xdp.ParsePortRange(flagsStart.bandwidthPorts)
+			if err != nil {
+				return err
+			}
 			b := bandwidth.Bandwidth{
 				Limit:            flagsStart.bandwidth,
+				LimitIngress:     flagsStart.bandwidthIngress,
+				LimitEgress:      flagsStart.bandwidthEgress,
+				Burst:            flagsStart.bandwidthBurst,
 				NetworkInterface: iface,
+				Direction:        serviceDirection(cmd, flagBandwidthDirection, flagsStart.bandwidthDirection, bwAsymmetric),
+				Peers:            peers,
+				Protocol:         xdp.Protocol(flagsStart.bandwidthProtocol),
+				Ports:            ports,
 			}
 			cancel, err := b.Start()
 			if err != nil {
 				return err
 			}
-			logger.Info("Bandwidth started", zap.Int64("limit (bps)", flagsStart.bandwidth), zap.String("device", flagsStart.networkInterfaceName))
+			logger.Info("Bandwidth started",
+				zap.Int64("limit (bps)", flagsStart.bandwidth),
+				zap.Int64("ingress limit (bps)", flagsStart.bandwidthIngress),
+				zap.Int64("egress limit (bps)", flagsStart.bandwidthEgress),
+				zap.Int64("burst (bytes)", b.Burst),
+				zap.String("direction", string(b.Direction)),
+				zap.Strings("peers", flagsStart.bandwidthPeers),
+				zap.String("protocol", flagsStart.bandwidthProtocol),
+				zap.String("ports", flagsStart.bandwidthPorts),
+				zap.String("device", flagsStart.networkInterfaceName))
 			defer func() {
 				if err := cancel(); err != nil {
 					logger.Error("cancel bandwidth", zap.Error(err))
@@ -116,19 +343,39 @@ var startCmd = &cobra.Command{
 		/*---------*/
 
 		if flagsStart.latency > 0 || flagsStart.jitter > 0 {
+			peers, err := xdp.ParsePeers(flagsStart.latencyPeers)
+			if err != nil {
+				return err
+			}
+			ports, err := xdp.ParsePortRange(flagsStart.latencyPorts)
+			if err != nil {
+				return err
+			}
 			l := latency.Latency{
-				Latency:          time.Duration(flagsStart.latency) * time.Millisecond,
-				Jitter:           time.Duration(flagsStart.jitter) * time.Millisecond,
+				Latency:          time.Duration(flagsStart.latency),
+				Jitter:           time.Duration(flagsStart.jitter),
 				NetworkInterface: iface,
 				TcBinPath:        flagsStart.tcBinPath,
+				Engine:           flagsStart.latencyEngine,
+				Distribution:     flagsStart.jitterDistribution,
+				Correlation:      flagsStart.jitterCorrelation,
+				Peers:            peers,
+				Protocol:         xdp.Protocol(flagsStart.latencyProtocol),
+				Ports:            ports,
 			}
 			cancel, err := l.Start()
 			if err != nil {
 				return err
 			}
 			logger.Info("Latency/Jitter started",
-				zap.Int64("latency (ms)", l.Latency.Milliseconds()),
-				zap.Int64("jitter (ms)", l.Jitter.Milliseconds()),
+				zap.Duration("latency", l.Latency),
+				zap.Duration("jitter", l.Jitter),
+				zap.String("engine", flagsStart.latencyEngine),
+				zap.String("distribution", flagsStart.jitterDistribution),
+				zap.Float64("correlation (%)", flagsStart.jitterCorrelation),
+				zap.Strings("peers", flagsStart.latencyPeers),
+				zap.String("protocol", flagsStart.latencyProtocol),
+				zap.String("ports", flagsStart.latencyPorts),
 				zap.String("device", flagsStart.networkInterfaceName))
 			defer func() {
 				if err := cancel(); err != nil {
@@ -140,13 +387,123 @@ var startCmd = &cobra.Command{
 
 		/*---------*/
 
-		// Handle interrupt signal (Ctrl+C)
-		signalChan := make(chan os.Signal, 1)
-		signal.Notify(signalChan, os.Interrupt, syscall.SIGINT)
+		if flagsStart.duplicateRate > 0 {
+			peers, err := xdp.ParsePeers(flagsStart.duplicatePeers)
+			if err != nil {
+				return err
+			}
+			ports, err := xdp.ParsePortRange(flagsStart.duplicatePorts)
+			if err != nil {
+				return err
+			}
+			d := duplicate.Duplicate{
+				DuplicateRate:    flagsStart.duplicateRate,
+				NetworkInterface: iface,
+				Peers:            peers,
+				Protocol:         xdp.Protocol(flagsStart.duplicateProtocol),
+				Ports:            ports,
+			}
+			cancel, err := d.Start()
+			if err != nil {
+				return err
+			}
+			logger.Info("Duplicate started",
+				zap.Float64("rate (%)", flagsStart.duplicateRate),
+				zap.Strings("peers", flagsStart.duplicatePeers),
+				zap.String("protocol", flagsStart.duplicateProtocol),
+				zap.String("ports", flagsStart.duplicatePorts),
+				zap.String("device", flagsStart.networkInterfaceName))
+			defer func() {
+				if err := cancel(); err != nil {
+					logger.Error("cancel duplicate", zap.Error(err))
+				}
+				logger.Info("Duplicate stopped", zap.String("device", flagsStart.networkInterfaceName))
+			}()
+		}
 
-		<-signalChan
-		logger.Info("Received interrupt signal. Shutting down...")
+		/*---------*/
+
+		if flagsStart.corruptRate > 0 {
+			peers, err := xdp.ParsePeers(flagsStart.corruptPeers)
+			if err != nil {
+				return err
+			}
+			ports, err := xdp.ParsePortRange(flagsStart.corruptPorts)
+			if err != nil {
+				return err
+			}
+			c := corrupt.Corrupt{
+				CorruptRate:      flagsStart.corruptRate,
+				PayloadOnly:      flagsStart.corruptPayloadOnly,
+				FixChecksum:      flagsStart.corruptFixChecksum,
+				NetworkInterface: iface,
+				Peers:            peers,
+				Protocol:         xdp.Protocol(flagsStart.corruptProtocol),
+				Ports:            ports,
+			}
+			cancel, err := c.Start()
+			if err != nil {
+				return err
+			}
+			logger.Info("Corrupt started",
+				zap.Float64("rate (%)", flagsStart.corruptRate),
+				zap.Bool("payload only", flagsStart.corruptPayloadOnly),
+				zap.Bool("fix checksum", flagsStart.corruptFixChecksum),
+				zap.Strings("peers", flagsStart.corruptPeers),
+				zap.String("protocol", flagsStart.corruptProtocol),
+				zap.String("ports", flagsStart.corruptPorts),
+				zap.String("device", flagsStart.networkInterfaceName))
+			defer func() {
+				if err := cancel(); err != nil {
+					logger.Error("cancel corrupt", zap.Error(err))
+				}
+				logger.Info("Corrupt stopped", zap.String("device", flagsStart.networkInterfaceName))
+			}()
+		}
+
+		/*---------*/
+
+		if flagsStart.reorderRate > 0 {
+			peers, err := xdp.ParsePeers(flagsStart.reorderPeers)
+			if err != nil {
+				return err
+			}
+			ports, err := xdp.ParsePortRange(flagsStart.reorderPorts)
+			if err != nil {
+				return err
+			}
+			r := reorder.Reorder{
+				ReorderRate:      flagsStart.reorderRate,
+				Gap:              flagsStart.reorderGap,
+				Delay:            time.Duration(flagsStart.reorderDelay) * time.Millisecond,
+				NetworkInterface: iface,
+				Peers:            peers,
+				Protocol:         xdp.Protocol(flagsStart.reorderProtocol),
+				Ports:            ports,
+			}
+			cancel, err := r.Start()
+			if err != nil {
+				return err
+			}
+			logger.Info("Reorder started",
+				zap.Float64("rate (%)", flagsStart.reorderRate),
+				zap.Uint32("gap", flagsStart.reorderGap),
+				zap.Int64("delay (ms)", flagsStart.reorderDelay),
+				zap.Strings("peers", flagsStart.reorderPeers),
+				zap.String("protocol", flagsStart.reorderProtocol),
+				zap.String("ports", flagsStart.reorderPorts),
+				zap.String("device", flagsStart.networkInterfaceName))
+			defer func() {
+				if err := cancel(); err != nil {
+					logger.Error("cancel reorder", zap.Error(err))
+				}
+				logger.Info("Reorder stopped", zap.String("device", flagsStart.networkInterfaceName))
+			}()
+		}
+
+		/*---------*/
 
+		waitForInterrupt(logger)
 		return nil
 	},
 }
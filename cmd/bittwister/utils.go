@@ -2,7 +2,15 @@ package bittwister
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/celestiaorg/bittwister/xdp/qdisc"
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -53,3 +61,80 @@ func getLogger(logLevel string, productionMode bool) (*zap.Logger, error) {
 
 	return cfg.Build()
 }
+
+// waitForInterrupt blocks until the process receives an interrupt signal (Ctrl+C).
+func waitForInterrupt(logger *zap.Logger) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGINT)
+
+	<-signalChan
+	logger.Info("Received interrupt signal. Shutting down...")
+}
+
+// warnLeftovers warns about the qdiscs left behind by a bittwister process
+// which crashed, and about the objects it pinned in pinPath, if any. They
+// keep applying to the traffic until they are cleaned up.
+func warnLeftovers(logger *zap.Logger, pinPath string) {
+	leftovers, err := qdisc.Leftovers()
+	if err != nil {
+		logger.Warn("read the qdiscs installed by bittwister", zap.Error(err))
+	}
+	for _, r := range leftovers {
+		logger.Warn("qdisc left behind by a previous bittwister process, remove it with `bittwister cleanup`",
+			zap.String("device", r.NetworkInterfaceName),
+			zap.String("kind", r.Kind),
+			zap.Int("pid", r.PID),
+			zap.Time("installed", r.Installed))
+	}
+
+	if pinPath == "" {
+		return
+	}
+	entries, err := os.ReadDir(pinPath)
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	if inUse, err := xdp.PinsInUse(pinPath); err == nil && !inUse {
+		logger.Warn("BPF objects pinned by a previous bittwister process keep its services running, adopt them with `bittwister serve --"+flagPinPath+"` or remove them with `bittwister cleanup`",
+			zap.String("path", pinPath))
+	}
+}
+
+// serviceDirection returns the direction of a service given by its direction
+// flag. The direction of an asymmetric service, which has its own values for
+// ingress and egress, is derived from them unless the flag is set.
+func serviceDirection(cmd *cobra.Command, flagName, direction string, asymmetric bool) xdp.Direction {
+	if asymmetric && !cmd.Flags().Changed(flagName) {
+		return ""
+	}
+	return xdp.Direction(direction)
+}
+
+// msDuration is a flag taking a number of milliseconds, or a duration such as
+// 150us for a finer resolution.
+type msDuration time.Duration
+
+func (d *msDuration) Set(s string) error {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*d = msDuration(time.Duration(ms) * time.Millisecond)
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%q is neither milliseconds nor a duration", s)
+	}
+	*d = msDuration(v)
+	return nil
+}
+
+func (d *msDuration) String() string {
+	if *d == 0 {
+		return "0"
+	}
+	return time.Duration(*d).String()
+}
+
+func (d *msDuration) Type() string {
+	return "duration"
+}
@@ -2,6 +2,7 @@ package sdk
 
 import (
 	"encoding/json"
+	"net/url"
 
 	"github.com/celestiaorg/bittwister/api/v1"
 )
@@ -9,7 +10,20 @@ import (
 type PacketLossStartRequest = api.PacketLossStartRequest
 type BandwidthStartRequest = api.BandwidthStartRequest
 type LatencyStartRequest = api.LatencyStartRequest
+type DuplicateStartRequest = api.DuplicateStartRequest
+type ReorderStartRequest = api.ReorderStartRequest
+type CorruptStartRequest = api.CorruptStartRequest
+type PartitionStartRequest = api.PartitionStartRequest
+type PacketLossUpdateRequest = api.PacketLossUpdateRequest
+type BandwidthUpdateRequest = api.BandwidthUpdateRequest
+type LatencyUpdateRequest = api.LatencyUpdateRequest
+type DuplicateUpdateRequest = api.DuplicateUpdateRequest
+type ReorderUpdateRequest = api.ReorderUpdateRequest
+type CorruptUpdateRequest = api.CorruptUpdateRequest
+type Scenario = api.Scenario
+type SchedulePhase = api.SchedulePhase
 type ServiceStatus = api.ServiceStatus
+type ServiceStats = api.ServiceStats
 type MetaMessage = api.MetaMessage
 
 func (c *Client) PacketlossStart(req PacketLossStartRequest) error {
@@ -20,10 +34,37 @@ func (c *Client) PacketlossStop() error {
 	return c.postServiceAction(api.PacketlossPath.Stop(), nil)
 }
 
+// PacketlossUpdate changes the parameters of the running packetloss service without
+// stopping it, on the network interface of the request or on all of them.
+func (c *Client) PacketlossUpdate(req PacketLossUpdateRequest) error {
+	return c.putServiceAction(api.PacketlossPath.Params(), req)
+}
+
 func (c *Client) PacketlossStatus() (*MetaMessage, error) {
 	return c.getServiceStatus(api.PacketlossPath.Status())
 }
 
+// PacketlossInterfaceStop stops the packetloss service on the given network interface only.
+func (c *Client) PacketlossInterfaceStop(networkInterfaceName string) error {
+	return c.postServiceAction(api.PacketlossPath.InterfaceStop(url.PathEscape(networkInterfaceName)), nil)
+}
+
+// PacketlossInterfaceStatus returns the status of the packetloss service on the given network interface.
+func (c *Client) PacketlossInterfaceStatus(networkInterfaceName string) (*MetaMessage, error) {
+	return c.getServiceStatus(api.PacketlossPath.InterfaceStatus(url.PathEscape(networkInterfaceName)))
+}
+
+// PacketlossStats returns the packet counters of the packetloss service on each network
+// interface it runs on.
+func (c *Client) PacketlossStats() ([]ServiceStats, error) {
+	return c.getServiceStats(api.PacketlossPath.Stats())
+}
+
+// PacketlossInterfaceStats returns the packet counters of the packetloss service on the given network interface.
+func (c *Client) PacketlossInterfaceStats(networkInterfaceName string) ([]ServiceStats, error) {
+	return c.getServiceStats(api.PacketlossPath.InterfaceStats(url.PathEscape(networkInterfaceName)))
+}
+
 func (c *Client) BandwidthStart(req BandwidthStartRequest) error {
 	return c.postServiceAction(api.BandwidthPath.Start(), req)
 }
@@ -32,10 +73,37 @@ func (c *Client) BandwidthStop() error {
 	return c.postServiceAction(api.BandwidthPath.Stop(), nil)
 }
 
+// BandwidthUpdate changes the parameters of the running bandwidth service without
+// stopping it, on the network interface of the request or on all of them.
+func (c *Client) BandwidthUpdate(req BandwidthUpdateRequest) error {
+	return c.putServiceAction(api.BandwidthPath.Params(), req)
+}
+
 func (c *Client) BandwidthStatus() (*MetaMessage, error) {
 	return c.getServiceStatus(api.BandwidthPath.Status())
 }
 
+// BandwidthInterfaceStop stops the bandwidth service on the given network interface only.
+func (c *Client) BandwidthInterfaceStop(networkInterfaceName string) error {
+	return c.postServiceAction(api.BandwidthPath.InterfaceStop(url.PathEscape(networkInterfaceName)), nil)
+}
+
+// BandwidthInterfaceStatus returns the status of the bandwidth service on the given network interface.
+func (c *Client) BandwidthInterfaceStatus(networkInterfaceName string) (*MetaMessage, error) {
+	return c.getServiceStatus(api.BandwidthPath.InterfaceStatus(url.PathEscape(networkInterfaceName)))
+}
+
+// BandwidthStats returns the packet counters of the bandwidth service on each network
+// interface it runs on.
+func (c *Client) BandwidthStats() ([]ServiceStats, error) {
+	return c.getServiceStats(api.BandwidthPath.Stats())
+}
+
+// BandwidthInterfaceStats returns the packet counters of the bandwidth service on the given network interface.
+func (c *Client) BandwidthInterfaceStats(networkInterfaceName string) ([]ServiceStats, error) {
+	return c.getServiceStats(api.BandwidthPath.InterfaceStats(url.PathEscape(networkInterfaceName)))
+}
+
 func (c *Client) LatencyStart(req LatencyStartRequest) error {
 	return c.postServiceAction(api.LatencyPath.Start(), req)
 }
@@ -44,10 +112,204 @@ func (c *Client) LatencyStop() error {
 	return c.postServiceAction(api.LatencyPath.Stop(), nil)
 }
 
+// LatencyUpdate changes the parameters of the running latency service without
+// stopping it, on the network interface of the request or on all of them.
+func (c *Client) LatencyUpdate(req LatencyUpdateRequest) error {
+	return c.putServiceAction(api.LatencyPath.Params(), req)
+}
+
 func (c *Client) LatencyStatus() (*MetaMessage, error) {
 	return c.getServiceStatus(api.LatencyPath.Status())
 }
 
+// LatencyInterfaceStop stops the latency service on the given network interface only.
+func (c *Client) LatencyInterfaceStop(networkInterfaceName string) error {
+	return c.postServiceAction(api.LatencyPath.InterfaceStop(url.PathEscape(networkInterfaceName)), nil)
+}
+
+// LatencyInterfaceStatus returns the status of the latency service on the given network interface.
+func (c *Client) LatencyInterfaceStatus(networkInterfaceName string) (*MetaMessage, error) {
+	return c.getServiceStatus(api.LatencyPath.InterfaceStatus(url.PathEscape(networkInterfaceName)))
+}
+
+// LatencyStats returns the packet counters of the latency service on each network
+// interface it runs on.
+func (c *Client) LatencyStats() ([]ServiceStats, error) {
+	return c.getServiceStats(api.LatencyPath.Stats())
+}
+
+// LatencyInterfaceStats returns the packet counters of the latency service on the given network interface.
+func (c *Client) LatencyInterfaceStats(networkInterfaceName string) ([]ServiceStats, error) {
+	return c.getServiceStats(api.LatencyPath.InterfaceStats(url.PathEscape(networkInterfaceName)))
+}
+
+func (c *Client) DuplicateStart(req DuplicateStartRequest) error {
+	return c.postServiceAction(api.DuplicatePath.Start(), req)
+}
+
+func (c *Client) DuplicateStop() error {
+	return c.postServiceAction(api.DuplicatePath.Stop(), nil)
+}
+
+// DuplicateUpdate changes the parameters of the running duplicate service without
+// stopping it, on the network interface of the request or on all of them.
+func (c *Client) DuplicateUpdate(req DuplicateUpdateRequest) error {
+	return c.putServiceAction(api.DuplicatePath.Params(), req)
+}
+
+func (c *Client) DuplicateStatus() (*MetaMessage, error) {
+	return c.getServiceStatus(api.DuplicatePath.Status())
+}
+
+// DuplicateInterfaceStop stops the duplicate service on the given network interface only.
+func (c *Client) DuplicateInterfaceStop(networkInterfaceName string) error {
+	return c.postServiceAction(api.DuplicatePath.InterfaceStop(url.PathEscape(networkInterfaceName)), nil)
+}
+
+// DuplicateInterfaceStatus returns the status of the duplicate service on the given network interface.
+func (c *Client) DuplicateInterfaceStatus(networkInterfaceName string) (*MetaMessage, error) {
+	return c.getServiceStatus(api.DuplicatePath.InterfaceStatus(url.PathEscape(networkInterfaceName)))
+}
+
+// DuplicateStats returns the packet counters of the duplicate service on each network
+// interface it runs on.
+func (c *Client) DuplicateStats() ([]ServiceStats, error) {
+	return c.getServiceStats(api.DuplicatePath.Stats())
+}
+
+// DuplicateInterfaceStats returns the packet counters of the duplicate service on the given network interface.
+func (c *Client) DuplicateInterfaceStats(networkInterfaceName string) ([]ServiceStats, error) {
+	return c.getServiceStats(api.DuplicatePath.InterfaceStats(url.PathEscape(networkInterfaceName)))
+}
+
+func (c *Client) ReorderStart(req ReorderStartRequest) error {
+	return c.postServiceAction(api.ReorderPath.Start(), req)
+}
+
+func (c *Client) ReorderStop() error {
+	return c.postServiceAction(api.ReorderPath.Stop(), nil)
+}
+
+// ReorderUpdate changes the parameters of the running reorder service without
+// stopping it, on the network interface of the request or on all of them.
+func (c *Client) ReorderUpdate(req ReorderUpdateRequest) error {
+	return c.putServiceAction(api.ReorderPath.Params(), req)
+}
+
+func (c *Client) ReorderStatus() (*MetaMessage, error) {
+	return c.getServiceStatus(api.ReorderPath.Status())
+}
+
+// ReorderInterfaceStop stops the reorder service on the given network interface only.
+func (c *Client) ReorderInterfaceStop(networkInterfaceName string) error {
+	return c.postServiceAction(api.ReorderPath.InterfaceStop(url.PathEscape(networkInterfaceName)), nil)
+}
+
+// ReorderInterfaceStatus returns the status of the reorder service on the given network interface.
+func (c *Client) ReorderInterfaceStatus(networkInterfaceName string) (*MetaMessage, error) {
+	return c.getServiceStatus(api.ReorderPath.InterfaceStatus(url.PathEscape(networkInterfaceName)))
+}
+
+// ReorderStats returns the packet counters of the reorder service on each network
+// interface it runs on.
+func (c *Client) ReorderStats() ([]ServiceStats, error) {
+	return c.getServiceStats(api.ReorderPath.Stats())
+}
+
+// ReorderInterfaceStats returns the packet counters of the reorder service on the given network interface.
+func (c *Client) ReorderInterfaceStats(networkInterfaceName string) ([]ServiceStats, error) {
+	return c.getServiceStats(api.ReorderPath.InterfaceStats(url.PathEscape(networkInterfaceName)))
+}
+
+func (c *Client) CorruptStart(req CorruptStartRequest) error {
+	return c.postServiceAction(api.CorruptPath.Start(), req)
+}
+
+func (c *Client) CorruptStop() error {
+	return c.postServiceAction(api.CorruptPath.Stop(), nil)
+}
+
+// CorruptUpdate changes the parameters of the running corrupt service without
+// stopping it, on the network interface of the request or on all of them.
+func (c *Client) CorruptUpdate(req CorruptUpdateRequest) error {
+	return c.putServiceAction(api.CorruptPath.Params(), req)
+}
+
+func (c *Client) CorruptStatus() (*MetaMessage, error) {
+	return c.getServiceStatus(api.CorruptPath.Status())
+}
+
+// CorruptInterfaceStop stops the corrupt service on the given network interface only.
+func (c *Client) CorruptInterfaceStop(networkInterfaceName string) error {
+	return c.postServiceAction(api.CorruptPath.InterfaceStop(url.PathEscape(networkInterfaceName)), nil)
+}
+
+// CorruptInterfaceStatus returns the status of the corrupt service on the given network interface.
+func (c *Client) CorruptInterfaceStatus(networkInterfaceName string) (*MetaMessage, error) {
+	return c.getServiceStatus(api.CorruptPath.InterfaceStatus(url.PathEscape(networkInterfaceName)))
+}
+
+// CorruptStats returns the packet counters of the corrupt service on each network
+// interface it runs on.
+func (c *Client) CorruptStats() ([]ServiceStats, error) {
+	return c.getServiceStats(api.CorruptPath.Stats())
+}
+
+// CorruptInterfaceStats returns the packet counters of the corrupt service on the given network interface.
+func (c *Client) CorruptInterfaceStats(networkInterfaceName string) ([]ServiceStats, error) {
+	return c.getServiceStats(api.CorruptPath.InterfaceStats(url.PathEscape(networkInterfaceName)))
+}
+
+func (c *Client) PartitionStart(req PartitionStartRequest) error {
+	return c.postServiceAction(api.PartitionPath.Start(), req)
+}
+
+func (c *Client) PartitionStop() error {
+	return c.postServiceAction(api.PartitionPath.Stop(), nil)
+}
+
+func (c *Client) PartitionStatus() (*MetaMessage, error) {
+	return c.getServiceStatus(api.PartitionPath.Status())
+}
+
+// PartitionInterfaceStop stops the partition service on the given network interface only.
+func (c *Client) PartitionInterfaceStop(networkInterfaceName string) error {
+	return c.postServiceAction(api.PartitionPath.InterfaceStop(url.PathEscape(networkInterfaceName)), nil)
+}
+
+// PartitionInterfaceStatus returns the status of the partition service on the given network interface.
+func (c *Client) PartitionInterfaceStatus(networkInterfaceName string) (*MetaMessage, error) {
+	return c.getServiceStatus(api.PartitionPath.InterfaceStatus(url.PathEscape(networkInterfaceName)))
+}
+
+// PartitionStats returns the packet counters of the partition service on each network
+// interface it runs on.
+func (c *Client) PartitionStats() ([]ServiceStats, error) {
+	return c.getServiceStats(api.PartitionPath.Stats())
+}
+
+// PartitionInterfaceStats returns the packet counters of the partition service on the given network interface.
+func (c *Client) PartitionInterfaceStats(networkInterfaceName string) ([]ServiceStats, error) {
+	return c.getServiceStats(api.PartitionPath.InterfaceStats(url.PathEscape(networkInterfaceName)))
+}
+
+// ScenarioStart starts all the services of the scenario, or none of them if
+// any is invalid or fails to start.
+func (c *Client) ScenarioStart(scenario Scenario) error {
+	return c.postServiceAction(api.ScenarioPath, scenario)
+}
+
+// ScheduleStart starts the phases of the scenario one after the other, the
+// current phase is listed in AllServicesStatus.
+func (c *Client) ScheduleStart(scenario Scenario) error {
+	return c.postServiceAction(api.SchedulePath, scenario)
+}
+
+// ScheduleStop stops the running schedule and the services of its current phase.
+func (c *Client) ScheduleStop() error {
+	return c.postServiceAction(api.SchedulePath+"/stop", nil)
+}
+
 func (c *Client) AllServicesStatus() ([]ServiceStatus, error) {
 	resp, err := c.getResource(api.ServicesPath.Status())
 	if err != nil {
@@ -2,10 +2,14 @@ package sdk
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 
 	"github.com/celestiaorg/bittwister/api/v1"
 )
@@ -13,17 +17,89 @@ import (
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	authToken  string
 }
 
-func NewClient(baseURL string) *Client {
-	return &Client{
+// ClientOption configures how a Client reaches the API server.
+type ClientOption func(*Client)
+
+// WithAuthToken sends the bearer token the API server is started with in
+// every request.
+func WithAuthToken(token string) ClientOption {
+	return func(c *Client) {
+		c.authToken = token
+	}
+}
+
+// WithTLSConfig connects to the API server with the TLS configuration, see
+// NewTLSConfig. The base URL must start with https://.
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: cfg,
+			},
+		}
+	}
+}
+
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{
 		baseURL:    baseURL,
 		httpClient: http.DefaultClient,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewTLSConfig returns the TLS configuration of a client which trusts the
+// server certificates signed by the CA of caFile, or by the system CAs if it
+// is empty, and presents the certificate of certFile and keyFile when the
+// server requires one (mutual TLS). The certificate is optional.
+func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in the CA file")
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// do sends the request with the auth token, if any.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	return c.httpClient.Do(req)
 }
 
 func (c *Client) getResource(resPath string) ([]byte, error) {
-	resp, err := c.httpClient.Get(c.baseURL + resPath)
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+resPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -43,18 +119,26 @@ func (c *Client) getResource(resPath string) ([]byte, error) {
 }
 
 func (c *Client) postResource(resPath string, requestBody interface{}) ([]byte, error) {
+	return c.sendResource(http.MethodPost, resPath, requestBody)
+}
+
+func (c *Client) putResource(resPath string, requestBody interface{}) ([]byte, error) {
+	return c.sendResource(http.MethodPut, resPath, requestBody)
+}
+
+func (c *Client) sendResource(method, resPath string, requestBody interface{}) ([]byte, error) {
 	requestBodyJSON, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL+resPath, bytes.NewBuffer(requestBodyJSON))
+	req, err := http.NewRequest(method, c.baseURL+resPath, bytes.NewBuffer(requestBodyJSON))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +170,22 @@ func (c *Client) getServiceStatus(resPath string) (*api.MetaMessage, error) {
 	return msg, nil
 }
 
+func (c *Client) getServiceStats(resPath string) ([]api.ServiceStats, error) {
+	resp, err := c.getResource(resPath)
+	if err != nil {
+		if len(resp) == 0 {
+			return nil, fmt.Errorf("getResource: %w", err)
+		}
+		return nil, serviceActionError(resp)
+	}
+
+	stats := []api.ServiceStats{}
+	if err := json.Unmarshal(resp, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
 func (c *Client) postServiceAction(resPath string, req interface{}) error {
 	resp, err := c.postResource(resPath, req)
 	if err == nil {
@@ -95,7 +195,22 @@ func (c *Client) postServiceAction(resPath string, req interface{}) error {
 	if len(resp) == 0 {
 		return fmt.Errorf("postResource: %w", err)
 	}
+	return serviceActionError(resp)
+}
+
+func (c *Client) putServiceAction(resPath string, req interface{}) error {
+	resp, err := c.putResource(resPath, req)
+	if err == nil {
+		return nil
+	}
+
+	if len(resp) == 0 {
+		return fmt.Errorf("putResource: %w", err)
+	}
+	return serviceActionError(resp)
+}
 
+func serviceActionError(resp []byte) error {
 	msg := api.MetaMessage{}
 	if err := json.Unmarshal(resp, &msg); err != nil {
 		return fmt.Errorf("raw output: %s", string(resp))
@@ -63,3 +63,11 @@ func IsErrorServiceNotReady(err error) bool {
 	}
 	return e.Message.Slug == api.SlugServiceNotReady
 }
+
+func IsErrorUnauthorized(err error) bool {
+	e, ok := err.(Error)
+	if !ok {
+		return false
+	}
+	return e.Message.Slug == api.SlugUnauthorized
+}
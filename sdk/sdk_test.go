@@ -2,10 +2,14 @@ package sdk
 
 import (
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/celestiaorg/bittwister/api/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -364,3 +368,437 @@ func Test_SDK_Client_ServiceStatus_Unmarshal(t *testing.T) {
 		})
 	}
 }
+
+func Test_SDK_Client_PacketlossInterfaceStop_Success(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.PacketlossPath.InterfaceStop("eth1"), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.PacketlossInterfaceStop("eth1")
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_BandwidthInterfaceStatus_Success(t *testing.T) {
+	expectedStatus := MetaMessage{
+		Type:    "info",
+		Slug:    "service-ready",
+		Title:   "Bandwidth Service",
+		Message: "Bandwidth service is ready",
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.BandwidthPath.InterfaceStatus("eth1"), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		jsonBytes, err := json.Marshal(expectedStatus)
+		require.NoError(t, err)
+
+		_, err = w.Write(jsonBytes)
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	status, err := client.BandwidthInterfaceStatus("eth1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedStatus, *status)
+}
+
+func Test_SDK_Client_DuplicateStart_Success(t *testing.T) {
+	expectedRequest := DuplicateStartRequest{
+		NetworkInterfaceName: "eth0",
+		DuplicateRate:        5,
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.DuplicatePath.Start(), r.URL.Path)
+
+		var req DuplicateStartRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, expectedRequest, req)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.DuplicateStart(expectedRequest)
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_DuplicateStop_Success(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.DuplicatePath.Stop(), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.DuplicateStop()
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_DuplicateStatus_Success(t *testing.T) {
+	expectedStatus := MetaMessage{
+		Type:    "info",
+		Slug:    "service-ready",
+		Title:   "Duplicate Service",
+		Message: "Duplicate service is ready",
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.DuplicatePath.Status(), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		jsonBytes, err := json.Marshal(expectedStatus)
+		require.NoError(t, err)
+
+		_, err = w.Write(jsonBytes)
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	status, err := client.DuplicateStatus()
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedStatus, *status)
+}
+
+func Test_SDK_Client_ReorderStart_Success(t *testing.T) {
+	expectedRequest := ReorderStartRequest{
+		NetworkInterfaceName: "eth0",
+		ReorderRate:          25,
+		Gap:                  3,
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.ReorderPath.Start(), r.URL.Path)
+
+		var req ReorderStartRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, expectedRequest, req)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.ReorderStart(expectedRequest)
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_ReorderStop_Success(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.ReorderPath.Stop(), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.ReorderStop()
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_ReorderStatus_Success(t *testing.T) {
+	expectedStatus := MetaMessage{
+		Type:    "info",
+		Slug:    "service-ready",
+		Title:   "Reorder Service",
+		Message: "Reorder service is ready",
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.ReorderPath.Status(), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		jsonBytes, err := json.Marshal(expectedStatus)
+		require.NoError(t, err)
+
+		_, err = w.Write(jsonBytes)
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	status, err := client.ReorderStatus()
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedStatus, *status)
+}
+
+func Test_SDK_Client_CorruptStart_Success(t *testing.T) {
+	expectedRequest := CorruptStartRequest{
+		NetworkInterfaceName: "eth0",
+		CorruptRate:          1,
+		FixChecksum:          true,
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.CorruptPath.Start(), r.URL.Path)
+
+		var req CorruptStartRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, expectedRequest, req)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.CorruptStart(expectedRequest)
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_CorruptStop_Success(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.CorruptPath.Stop(), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.CorruptStop()
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_CorruptStatus_Success(t *testing.T) {
+	expectedStatus := MetaMessage{
+		Type:    "info",
+		Slug:    "service-ready",
+		Title:   "Corrupt Service",
+		Message: "Corrupt service is ready",
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.CorruptPath.Status(), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		jsonBytes, err := json.Marshal(expectedStatus)
+		require.NoError(t, err)
+
+		_, err = w.Write(jsonBytes)
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	status, err := client.CorruptStatus()
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedStatus, *status)
+}
+
+func Test_SDK_Client_PartitionStart_Success(t *testing.T) {
+	expectedRequest := PartitionStartRequest{
+		NetworkInterfaceName: "eth0",
+		Peers:                []string{"10.0.0.2", "10.1.0.0/16"},
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.PartitionPath.Start(), r.URL.Path)
+
+		var req PartitionStartRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, expectedRequest, req)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.PartitionStart(expectedRequest)
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_PartitionStop_Success(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.PartitionPath.Stop(), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.PartitionStop()
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_ScenarioStart_Success(t *testing.T) {
+	expectedScenario := Scenario{
+		NetworkInterfaceName: "eth0",
+		PacketLoss:           []PacketLossStartRequest{{PacketLossRate: 10}},
+		Duplicate:            []DuplicateStartRequest{{NetworkInterfaceName: "eth1", DuplicateRate: 5}},
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.ScenarioPath, r.URL.Path)
+
+		var scenario Scenario
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&scenario))
+		assert.Equal(t, expectedScenario, scenario)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.ScenarioStart(expectedScenario)
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_ScheduleStart_Success(t *testing.T) {
+	expectedScenario := Scenario{
+		NetworkInterfaceName: "eth0",
+		Phases: []SchedulePhase{
+			{Name: "lossy", Duration: "30s", Scenario: Scenario{PacketLoss: []PacketLossStartRequest{{PacketLossRate: 10}}}},
+			{Name: "heal"},
+		},
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.SchedulePath, r.URL.Path)
+
+		var scenario Scenario
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&scenario))
+		assert.Equal(t, expectedScenario, scenario)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.ScheduleStart(expectedScenario)
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_ScheduleStop_Success(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.SchedulePath+"/stop", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.ScheduleStop()
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_PacketlossUpdate_Success(t *testing.T) {
+	expectedReq := PacketLossUpdateRequest{NetworkInterfaceName: "eth0", PacketLossRate: 25}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, api.PacketlossPath.Params(), r.URL.Path)
+
+		var req PacketLossUpdateRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, expectedReq, req)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.PacketlossUpdate(expectedReq)
+
+	assert.NoError(t, err)
+}
+
+func Test_SDK_Client_BandwidthUpdate_NotStarted(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, api.BandwidthPath.Params(), r.URL.Path)
+
+		w.WriteHeader(http.StatusBadRequest)
+		require.NoError(t, json.NewEncoder(w).Encode(MetaMessage{Type: api.APIMetaMessageTypeError, Slug: api.SlugServiceNotStarted}))
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.BandwidthUpdate(BandwidthUpdateRequest{Limit: 1000})
+
+	assert.True(t, IsErrorServiceNotStarted(err))
+}
+
+func Test_SDK_Client_PacketlossStats_Success(t *testing.T) {
+	expectedStats := []ServiceStats{{Name: "packetloss", NetworkInterfaceName: "eth0"}}
+	expectedStats[0].Ingress.PacketsSeen = 10
+	expectedStats[0].Ingress.PacketsDropped = 3
+	expectedStats[0].Ingress.PacketsPassed = 7
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, api.PacketlossPath.InterfaceStats("eth0"), r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		jsonBytes, err := json.Marshal(expectedStats)
+		require.NoError(t, err)
+
+		_, err = w.Write(jsonBytes)
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	stats, err := client.PacketlossInterfaceStats("eth0")
+
+	require.NoError(t, err)
+	assert.Equal(t, expectedStats, stats)
+}
+
+func Test_SDK_Client_PacketlossStats_NotStarted(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, err := w.Write([]byte(`{"type": "error", "slug": "service-not-started", "title": "Service stats failed"}`))
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	_, err := client.PacketlossStats()
+
+	assert.True(t, IsErrorServiceNotStarted(err), err)
+}
+
+func Test_SDK_Client_WithAuthToken(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer s3cret", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`{"type": "info", "slug": "service-ready"}`))
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL, WithAuthToken("s3cret"))
+
+	_, err := client.PacketlossStatus()
+	require.NoError(t, err)
+	require.NoError(t, client.PacketlossStop())
+}
+
+func Test_SDK_Client_Unauthorized(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, err := w.Write([]byte(`{"type": "error", "slug": "unauthorized", "title": "Unauthorized"}`))
+		require.NoError(t, err)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.PacketlossStop()
+
+	assert.True(t, IsErrorUnauthorized(err), err)
+}
+
+func Test_SDK_Client_WithTLSConfig(t *testing.T) {
+	mockServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	// the server certificate is not trusted without its CA
+	err := NewClient(mockServer.URL).PacketlossStop()
+	require.Error(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mockServer.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+
+	cfg, err := NewTLSConfig("", "", caFile)
+	require.NoError(t, err)
+
+	client := NewClient(mockServer.URL, WithTLSConfig(cfg))
+	assert.NoError(t, client.PacketlossStop())
+}
@@ -2,35 +2,216 @@ package bandwidth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
 
 	"github.com/celestiaorg/bittwister/xdp"
 	"github.com/cilium/ebpf"
 )
 
+// MinBurst is the smallest token bucket size, so that the largest packets
+// (e.g. GSO packets on egress) can still go through.
+const MinBurst = 64 * 1024 // Bytes
+
+// MaxBurst is the largest token bucket size, so the refill arithmetic of the
+// kernel program, in bytes times nanoseconds, does not overflow 64 bits.
+const MaxBurst = math.MaxUint64 / 1_000_000_000 // Bytes
+
 type Bandwidth struct {
 	NetworkInterface *net.Interface
-	Limit            int64 // Bytes per second
+	Limit            int64         // Bits per second
+	LimitIngress     int64         // Bits per second on ingress, instead of Limit, see IsAsymmetric
+	LimitEgress      int64         // Bits per second on egress, instead of Limit, see IsAsymmetric
+	Burst            int64         // Bytes, default: DefaultBurst of the limit of each direction
+	Direction        xdp.Direction // default: ingress
+	Peers            []*net.IPNet  // default: all the traffic
+	Protocol         xdp.Protocol  // default: all the protocols
+	Ports            xdp.PortRange // default: all the ports
+
+	x *xdp.XdpObject // set while the service runs
+}
+
+// DefaultBurst returns the token bucket size used when no burst is given:
+// 100ms worth of traffic at the given limit, between MinBurst and MaxBurst.
+func DefaultBurst(limit int64) int64 {
+	burst := limit / 8 / 10
+	if burst < MinBurst {
+		return MinBurst
+	}
+	if burst > MaxBurst {
+		return MaxBurst
+	}
+	return burst
 }
 
-var _ xdp.XdpLoader = (*Bandwidth)(nil)
+var (
+	_ xdp.XdpLoader   = (*Bandwidth)(nil)
+	_ xdp.Updater     = (*Bandwidth)(nil)
+	_ xdp.StatsReader = (*Bandwidth)(nil)
+	_ xdp.Adopter     = (*Bandwidth)(nil)
+)
+
+func (b *Bandwidth) Validate() error {
+	if b.NetworkInterface == nil {
+		return xdp.ErrNetworkInterfaceNotSet
+	}
+	if b.Limit < 0 || b.LimitIngress < 0 || b.LimitEgress < 0 || b.Burst < 0 {
+		return fmt.Errorf("bandwidth limit and burst must not be negative")
+	}
+	if b.Burst != 0 && b.Burst < MinBurst {
+		// the packets larger than the token bucket would be dropped forever
+		return fmt.Errorf("bandwidth burst must be at least %d bytes", MinBurst)
+	}
+	if b.Burst > MaxBurst {
+		return fmt.Errorf("bandwidth burst must be at most %d bytes", MaxBurst)
+	}
+
+	if b.IsAsymmetric() {
+		if b.Limit != 0 {
+			return fmt.Errorf("bandwidth limit cannot be combined with the ingress and egress limits")
+		}
+		if _, err := xdp.AsymmetricDirection(b.Direction, b.LimitIngress != 0, b.LimitEgress != 0); err != nil {
+			return fmt.Errorf("bandwidth limit: %w", err)
+		}
+	} else if b.Direction != "" {
+		if _, err := b.Direction.MapKeys(); err != nil {
+			return err
+		}
+	}
+
+	_, err := xdp.NewProtocolFilter(b.Protocol, b.Ports)
+	return err
+}
+
+// setDefaults fills in the defaults of the unset parameters.
+func (b *Bandwidth) setDefaults() {
+	if b.Direction == "" {
+		// the directions which have a limit, ingress for a single limit
+		b.Direction, _ = xdp.AsymmetricDirection("", b.LimitIngress != 0, b.LimitEgress != 0)
+	}
+	if b.Burst == 0 && !b.IsAsymmetric() {
+		b.Burst = DefaultBurst(b.Limit)
+	}
+}
 
 func (b *Bandwidth) Start() (xdp.CancelFunc, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	b.setDefaults()
+
+	keys, err := b.Direction.MapKeys()
+	if err != nil {
+		return nil, err
+	}
+
 	x, err := xdp.GetPreparedXdpObject(b.NetworkInterface.Index)
 	if err != nil {
 		return nil, fmt.Errorf("prepare XDP object: %w", err)
 	}
 
-	key := uint32(0)
-	err = x.BpfObjs.BandwidthLimitMap.Update(key, b.Limit, ebpf.UpdateAny)
+	if b.Direction.HasEgress() {
+		if err := x.AttachTc(); err != nil {
+			if cErr := x.Close(); cErr != nil {
+				return nil, fmt.Errorf("close XDP object: %w", cErr)
+			}
+			return nil, err
+		}
+	}
+
+	err = x.ResetStats(xdp.ServiceBandwidth)
+	if err == nil {
+		err = x.SetPeers(xdp.ServiceBandwidth, b.Peers)
+	}
+	if err == nil {
+		err = x.SetProtocolFilter(xdp.ServiceBandwidth, b.Protocol, b.Ports)
+	}
 	if err != nil {
 		if cErr := x.Close(); cErr != nil {
 			return nil, fmt.Errorf("close XDP object: %w", cErr)
 		}
-		return nil, fmt.Errorf("update bandwidth limit rate: %w", err)
+		return nil, fmt.Errorf("set bandwidth rules: %w", err)
 	}
 
+	for _, key := range keys {
+		// Start over with a full token bucket.
+		err = x.BpfObjs.TokenBucketMap.Delete(key)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			err = nil
+		}
+		if err == nil {
+			err = x.BpfObjs.BandwidthLimitMap.Update(key, b.mapValue(key), ebpf.UpdateAny)
+		}
+		if err != nil {
+			if cErr := x.Close(); cErr != nil {
+				return nil, fmt.Errorf("close XDP object: %w", cErr)
+			}
+			return nil, fmt.Errorf("update bandwidth limit rate: %w", err)
+		}
+	}
+
+	return b.run(x, keys), nil
+}
+
+// Adopt takes over the bandwidth limit a previous process left in the
+// pinned maps, with the tokens left in the buckets. The direction is derived
+// from the directions with a limit.
+func (b *Bandwidth) Adopt() (xdp.CancelFunc, bool, error) {
+	x, ok, err := xdp.AdoptObject(b.NetworkInterface.Index, b.readMaps)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	// readMaps validated the direction
+	keys, _ := b.Direction.MapKeys()
+	return b.run(x, keys), true, nil
+}
+
+// readMaps sets the parameters of the service from the maps, and reports
+// whether it runs.
+func (b *Bandwidth) readMaps(x *xdp.XdpObject) (bool, error) {
+	ingress, egress, err := xdp.LookupDirections[xdp.BandwidthLimit](x.BpfObjs.BandwidthLimitMap)
+	if err != nil {
+		return false, err
+	}
+
+	hasIngress, hasEgress := ingress != xdp.BandwidthLimit{}, egress != xdp.BandwidthLimit{}
+	if !hasIngress && !hasEgress {
+		return false, nil
+	}
+	if b.Direction, err = xdp.AsymmetricDirection("", hasIngress, hasEgress); err != nil {
+		return false, err
+	}
+
+	switch {
+	case hasIngress && hasEgress && ingress.RateBps != egress.RateBps:
+		b.LimitIngress, b.LimitEgress = int64(ingress.RateBps), int64(egress.RateBps)
+		// the burst is shared, unless each direction has its default one
+		if ingress.BurstBytes == egress.BurstBytes {
+			b.Burst = int64(ingress.BurstBytes)
+		}
+	case hasIngress:
+		b.Limit, b.Burst = int64(ingress.RateBps), int64(ingress.BurstBytes)
+	default:
+		b.Limit, b.Burst = int64(egress.RateBps), int64(egress.BurstBytes)
+	}
+
+	if b.Peers, err = x.Peers(xdp.ServiceBandwidth); err != nil {
+		return false, err
+	}
+	if b.Protocol, b.Ports, err = x.ProtocolFilter(xdp.ServiceBandwidth); err != nil {
+		return false, err
+	}
+	return true, b.Validate()
+}
+
+// run marks the service as running on the XDP object, and returns the
+// function which stops it.
+func (b *Bandwidth) run(x *xdp.XdpObject, keys []uint32) xdp.CancelFunc {
+	b.x = x
+
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		<-ctx.Done()
@@ -38,10 +219,20 @@ func (b *Bandwidth) Start() (xdp.CancelFunc, error) {
 
 	cancelFunc := xdp.CancelFunc(func() error {
 		// Update the map with a rate of 0 to disable the bandwidth limiter.
-		zero := int64(0)
-		err := x.BpfObjs.BandwidthLimitMap.Update(key, zero, ebpf.UpdateAny)
-		if err != nil {
-			return fmt.Errorf("update bandwidth limit rate to zero: %w", err)
+		for _, key := range keys {
+			err := x.BpfObjs.BandwidthLimitMap.Update(key, xdp.BandwidthLimit{}, ebpf.UpdateAny)
+			if err != nil {
+				return fmt.Errorf("update bandwidth limit rate to zero: %w", err)
+			}
+		}
+
+		b.x = nil
+
+		if err := x.SetPeers(xdp.ServiceBandwidth, nil); err != nil {
+			return fmt.Errorf("clear bandwidth peers: %w", err)
+		}
+		if err := x.SetProtocolFilter(xdp.ServiceBandwidth, xdp.ProtocolAny, xdp.PortRange{}); err != nil {
+			return fmt.Errorf("clear bandwidth protocol filter: %w", err)
 		}
 
 		if err := x.Close(); err != nil {
@@ -51,5 +242,75 @@ func (b *Bandwidth) Start() (xdp.CancelFunc, error) {
 		return nil
 	})
 
-	return cancelFunc, nil
+	return cancelFunc
+}
+
+// Update changes the limits and burst of the running service, the tokens
+// left in the bucket are kept. The direction is kept too, so the new limits
+// must fit it.
+func (b *Bandwidth) Update(next xdp.XdpLoader) error {
+	n, ok := next.(*Bandwidth)
+	if !ok {
+		return fmt.Errorf("cannot update %T with %T", b, next)
+	}
+	if b.x == nil {
+		return xdp.ErrServiceNotRunning
+	}
+
+	u := *b
+	u.Limit, u.LimitIngress, u.LimitEgress, u.Burst = n.Limit, n.LimitIngress, n.LimitEgress, n.Burst
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	u.setDefaults()
+
+	keys, err := u.Direction.MapKeys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := b.x.BpfObjs.BandwidthLimitMap.Update(key, u.mapValue(key), ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("update bandwidth limit rate: %w", err)
+		}
+	}
+
+	*b = u
+	return nil
+}
+
+// IsAsymmetric reports whether the service has its own limit for each
+// direction: LimitIngress and LimitEgress are used instead of Limit, and a
+// zero value leaves its direction unlimited. The direction defaults to the
+// ones they are set for.
+func (b *Bandwidth) IsAsymmetric() bool {
+	return b.LimitIngress != 0 || b.LimitEgress != 0
+}
+
+// mapValue returns the limit of the direction of the map key.
+func (b *Bandwidth) mapValue(key uint32) xdp.BandwidthLimit {
+	limit := b.Limit
+	if b.IsAsymmetric() {
+		limit = b.LimitEgress
+		if xdp.IsIngressKey(key) {
+			limit = b.LimitIngress
+		}
+	}
+
+	burst := b.Burst
+	if burst == 0 {
+		burst = DefaultBurst(limit)
+	}
+	return xdp.BandwidthLimit{
+		RateBps:    uint64(limit),
+		BurstBytes: uint64(burst),
+	}
+}
+
+// Stats returns the counters of the packets the service applied to since it
+// was started.
+func (b *Bandwidth) Stats() (xdp.ServiceStats, error) {
+	if b.x == nil {
+		return xdp.ServiceStats{}, xdp.ErrServiceNotRunning
+	}
+	return b.x.Stats(xdp.ServiceBandwidth)
 }
@@ -1,5 +1,5 @@
 // Code generated by bpf2go; DO NOT EDIT.
-//go:build 386 || amd64 || amd64p32 || arm || arm64 || loong64 || mips64le || mips64p32le || mipsle || ppc64le || riscv64
+//go:build 386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64
 
 package xdp
 
@@ -12,6 +12,66 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type bpfBandwidthLimit struct {
+	RateBps    uint64
+	BurstBytes uint64
+}
+
+type bpfCorruptParams struct {
+	Rate        uint32
+	PayloadOnly uint8
+	FixChecksum uint8
+	_           [2]byte
+}
+
+type bpfGilbertElliottParams struct {
+	Enabled  uint32
+	P        uint32
+	R        uint32
+	LossGood uint32
+	LossBad  uint32
+}
+
+type bpfLatencyParams struct {
+	LatencyNs uint64
+	JitterNs  uint64
+}
+
+type bpfPeerKey struct {
+	Prefixlen uint32
+	Addr      [16]uint8
+}
+
+type bpfProtocolFilter struct {
+	Protocol uint32
+	PortMin  uint16
+	PortMax  uint16
+}
+
+type bpfReorderParams struct {
+	Rate    uint32
+	Gap     uint32
+	DelayNs uint64
+}
+
+type bpfServiceStats struct {
+	PacketsSeen     uint64
+	BytesSeen       uint64
+	PacketsPassed   uint64
+	BytesPassed     uint64
+	PacketsDropped  uint64
+	BytesDropped    uint64
+	PacketsDelayed  uint64
+	BytesDelayed    uint64
+	PacketsModified uint64
+	BytesModified   uint64
+}
+
+type bpfTokenBucket struct {
+	Tokens       uint64
+	LastRefillNs uint64
+}
+
 // loadBpf returns the embedded CollectionSpec for bpf.
 func loadBpf() (*ebpf.CollectionSpec, error) {
 	reader := bytes.NewReader(_BpfBytes)
@@ -53,6 +113,7 @@ type bpfSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfProgramSpecs struct {
+	TcMain  *ebpf.ProgramSpec `ebpf:"tc_main"`
 	XdpMain *ebpf.ProgramSpec `ebpf:"xdp_main"`
 }
 
@@ -60,10 +121,28 @@ type bpfProgramSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
-	BandwidthLimitMap   *ebpf.MapSpec `ebpf:"bandwidth_limit_map"`
-	ByteCounter         *ebpf.MapSpec `ebpf:"byte_counter"`
-	LastPacketTimestamp *ebpf.MapSpec `ebpf:"last_packet_timestamp"`
-	PacketlossRateMap   *ebpf.MapSpec `ebpf:"packetloss_rate_map"`
+	BandwidthLimitMap       *ebpf.MapSpec `ebpf:"bandwidth_limit_map"`
+	BandwidthPeers          *ebpf.MapSpec `ebpf:"bandwidth_peers"`
+	CorruptParamsMap        *ebpf.MapSpec `ebpf:"corrupt_params_map"`
+	CorruptPeers            *ebpf.MapSpec `ebpf:"corrupt_peers"`
+	DuplicateCloningMap     *ebpf.MapSpec `ebpf:"duplicate_cloning_map"`
+	DuplicatePeers          *ebpf.MapSpec `ebpf:"duplicate_peers"`
+	DuplicateRateMap        *ebpf.MapSpec `ebpf:"duplicate_rate_map"`
+	GilbertElliottParamsMap *ebpf.MapSpec `ebpf:"gilbert_elliott_params_map"`
+	GilbertElliottStateMap  *ebpf.MapSpec `ebpf:"gilbert_elliott_state_map"`
+	LatencyIfbMap           *ebpf.MapSpec `ebpf:"latency_ifb_map"`
+	LatencyParamsMap        *ebpf.MapSpec `ebpf:"latency_params_map"`
+	LatencyPeers            *ebpf.MapSpec `ebpf:"latency_peers"`
+	PacketlossPeers         *ebpf.MapSpec `ebpf:"packetloss_peers"`
+	PacketlossRateMap       *ebpf.MapSpec `ebpf:"packetloss_rate_map"`
+	PartitionPeers          *ebpf.MapSpec `ebpf:"partition_peers"`
+	PeerFilterMap           *ebpf.MapSpec `ebpf:"peer_filter_map"`
+	ProtocolFilterMap       *ebpf.MapSpec `ebpf:"protocol_filter_map"`
+	ReorderGapMap           *ebpf.MapSpec `ebpf:"reorder_gap_map"`
+	ReorderParamsMap        *ebpf.MapSpec `ebpf:"reorder_params_map"`
+	ReorderPeers            *ebpf.MapSpec `ebpf:"reorder_peers"`
+	StatsMap                *ebpf.MapSpec `ebpf:"stats_map"`
+	TokenBucketMap          *ebpf.MapSpec `ebpf:"token_bucket_map"`
 }
 
 // bpfObjects contains all objects after they have been loaded into the kernel.
@@ -85,18 +164,54 @@ func (o *bpfObjects) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
-	BandwidthLimitMap   *ebpf.Map `ebpf:"bandwidth_limit_map"`
-	ByteCounter         *ebpf.Map `ebpf:"byte_counter"`
-	LastPacketTimestamp *ebpf.Map `ebpf:"last_packet_timestamp"`
-	PacketlossRateMap   *ebpf.Map `ebpf:"packetloss_rate_map"`
+	BandwidthLimitMap       *ebpf.Map `ebpf:"bandwidth_limit_map"`
+	BandwidthPeers          *ebpf.Map `ebpf:"bandwidth_peers"`
+	CorruptParamsMap        *ebpf.Map `ebpf:"corrupt_params_map"`
+	CorruptPeers            *ebpf.Map `ebpf:"corrupt_peers"`
+	DuplicateCloningMap     *ebpf.Map `ebpf:"duplicate_cloning_map"`
+	DuplicatePeers          *ebpf.Map `ebpf:"duplicate_peers"`
+	DuplicateRateMap        *ebpf.Map `ebpf:"duplicate_rate_map"`
+	GilbertElliottParamsMap *ebpf.Map `ebpf:"gilbert_elliott_params_map"`
+	GilbertElliottStateMap  *ebpf.Map `ebpf:"gilbert_elliott_state_map"`
+	LatencyIfbMap           *ebpf.Map `ebpf:"latency_ifb_map"`
+	LatencyParamsMap        *ebpf.Map `ebpf:"latency_params_map"`
+	LatencyPeers            *ebpf.Map `ebpf:"latency_peers"`
+	PacketlossPeers         *ebpf.Map `ebpf:"packetloss_peers"`
+	PacketlossRateMap       *ebpf.Map `ebpf:"packetloss_rate_map"`
+	PartitionPeers          *ebpf.Map `ebpf:"partition_peers"`
+	PeerFilterMap           *ebpf.Map `ebpf:"peer_filter_map"`
+	ProtocolFilterMap       *ebpf.Map `ebpf:"protocol_filter_map"`
+	ReorderGapMap           *ebpf.Map `ebpf:"reorder_gap_map"`
+	ReorderParamsMap        *ebpf.Map `ebpf:"reorder_params_map"`
+	ReorderPeers            *ebpf.Map `ebpf:"reorder_peers"`
+	StatsMap                *ebpf.Map `ebpf:"stats_map"`
+	TokenBucketMap          *ebpf.Map `ebpf:"token_bucket_map"`
 }
 
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
 		m.BandwidthLimitMap,
-		m.ByteCounter,
-		m.LastPacketTimestamp,
+		m.BandwidthPeers,
+		m.CorruptParamsMap,
+		m.CorruptPeers,
+		m.DuplicateCloningMap,
+		m.DuplicatePeers,
+		m.DuplicateRateMap,
+		m.GilbertElliottParamsMap,
+		m.GilbertElliottStateMap,
+		m.LatencyIfbMap,
+		m.LatencyParamsMap,
+		m.LatencyPeers,
+		m.PacketlossPeers,
 		m.PacketlossRateMap,
+		m.PartitionPeers,
+		m.PeerFilterMap,
+		m.ProtocolFilterMap,
+		m.ReorderGapMap,
+		m.ReorderParamsMap,
+		m.ReorderPeers,
+		m.StatsMap,
+		m.TokenBucketMap,
 	)
 }
 
@@ -104,11 +219,13 @@ func (m *bpfMaps) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfPrograms struct {
+	TcMain  *ebpf.Program `ebpf:"tc_main"`
 	XdpMain *ebpf.Program `ebpf:"xdp_main"`
 }
 
 func (p *bpfPrograms) Close() error {
 	return _BpfClose(
+		p.TcMain,
 		p.XdpMain,
 	)
 }
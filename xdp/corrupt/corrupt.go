@@ -0,0 +1,216 @@
+package corrupt
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/cilium/ebpf"
+)
+
+// Corrupt flips one random bit of some of the egress packets. By default the
+// bit is picked anywhere from the network header on and the checksums are
+// left as is, so most corrupted packets are dropped by the receiver's stack.
+type Corrupt struct {
+	NetworkInterface *net.Interface
+	CorruptRate      float64 // Percent, e.g. 1 for 1%
+	// PayloadOnly flips a bit of the TCP/UDP payload only, the headers and
+	// the other packets are left intact, so the TCP/UDP checksum catches it.
+	PayloadOnly bool
+	// FixChecksum updates the TCP/UDP checksum, so the corrupted payload
+	// reaches the application. It implies PayloadOnly.
+	FixChecksum bool
+	Peers       []*net.IPNet  // default: all the traffic
+	Protocol    xdp.Protocol  // default: all the protocols
+	Ports       xdp.PortRange // default: all the ports
+
+	x *xdp.XdpObject // set while the service runs
+}
+
+var (
+	_ xdp.XdpLoader   = (*Corrupt)(nil)
+	_ xdp.Updater     = (*Corrupt)(nil)
+	_ xdp.StatsReader = (*Corrupt)(nil)
+	_ xdp.Adopter     = (*Corrupt)(nil)
+)
+
+// ValidateRate checks that the corrupt rate is a percentage. The kernel applies
+// it with a precision of one part per million (0.0001%).
+func ValidateRate(rate float64) error {
+	if rate < 0 || rate > 100 || math.IsNaN(rate) {
+		return fmt.Errorf("corrupt rate must be between 0 and 100, got %v", rate)
+	}
+	if rate != 0 && xdp.PercentToPPM(rate) == 0 {
+		// it would read as a stopped service in the kernel
+		return fmt.Errorf("corrupt rate %v is below one part per million (0.0001%%)", rate)
+	}
+	return nil
+}
+
+func (c *Corrupt) Validate() error {
+	if c.NetworkInterface == nil {
+		return xdp.ErrNetworkInterfaceNotSet
+	}
+	if err := ValidateRate(c.CorruptRate); err != nil {
+		return err
+	}
+
+	_, err := xdp.NewProtocolFilter(c.Protocol, c.Ports)
+	return err
+}
+
+func (c *Corrupt) Start() (xdp.CancelFunc, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	x, err := xdp.GetPreparedXdpObject(c.NetworkInterface.Index)
+	if err != nil {
+		return nil, fmt.Errorf("prepare XDP object: %w", err)
+	}
+
+	key := uint32(0)
+	err = x.AttachTc()
+	if err == nil {
+		err = x.ResetStats(xdp.ServiceCorrupt)
+	}
+	if err == nil {
+		err = x.SetPeers(xdp.ServiceCorrupt, c.Peers)
+	}
+	if err == nil {
+		err = x.SetProtocolFilter(xdp.ServiceCorrupt, c.Protocol, c.Ports)
+	}
+	if err == nil {
+		err = x.BpfObjs.CorruptParamsMap.Update(key, c.mapValue(), ebpf.UpdateAny)
+	}
+	if err != nil {
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, fmt.Errorf("update corrupt params: %w", err)
+	}
+
+	return c.run(x), nil
+}
+
+// Adopt takes over the corruption a previous process left in the pinned
+// maps.
+func (c *Corrupt) Adopt() (xdp.CancelFunc, bool, error) {
+	x, ok, err := xdp.AdoptObject(c.NetworkInterface.Index, c.readMaps)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return c.run(x), true, nil
+}
+
+// readMaps sets the parameters of the service from the maps, and reports
+// whether it runs.
+func (c *Corrupt) readMaps(x *xdp.XdpObject) (bool, error) {
+	var params xdp.CorruptParams
+	if err := x.BpfObjs.CorruptParamsMap.Lookup(uint32(0), &params); err != nil {
+		return false, err
+	}
+	if params.Rate == 0 {
+		return false, nil
+	}
+	c.CorruptRate = xdp.PPMToPercent(params.Rate)
+	c.PayloadOnly = params.PayloadOnly != 0
+	c.FixChecksum = params.FixChecksum != 0
+
+	var err error
+	if c.Peers, err = x.Peers(xdp.ServiceCorrupt); err != nil {
+		return false, err
+	}
+	if c.Protocol, c.Ports, err = x.ProtocolFilter(xdp.ServiceCorrupt); err != nil {
+		return false, err
+	}
+	return true, c.Validate()
+}
+
+// run marks the service as running on the XDP object, and returns the
+// function which stops it.
+func (c *Corrupt) run(x *xdp.XdpObject) xdp.CancelFunc {
+	key := uint32(0)
+	c.x = x
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+	}()
+
+	cancelFunc := xdp.CancelFunc(func() error {
+		// Update the map with zero values to disable the corruption.
+		err := x.BpfObjs.CorruptParamsMap.Update(key, xdp.CorruptParams{}, ebpf.UpdateAny)
+		if err != nil {
+			return fmt.Errorf("update corrupt params to zero: %w", err)
+		}
+
+		c.x = nil
+
+		if err := x.SetPeers(xdp.ServiceCorrupt, nil); err != nil {
+			return fmt.Errorf("clear corrupt peers: %w", err)
+		}
+		if err := x.SetProtocolFilter(xdp.ServiceCorrupt, xdp.ProtocolAny, xdp.PortRange{}); err != nil {
+			return fmt.Errorf("clear corrupt protocol filter: %w", err)
+		}
+
+		if err := x.Close(); err != nil {
+			return fmt.Errorf("close XDP object: %w", err)
+		}
+		cancel()
+		return nil
+	})
+
+	return cancelFunc
+}
+
+// Update changes the corrupt rate and mode of the running service.
+func (c *Corrupt) Update(next xdp.XdpLoader) error {
+	n, ok := next.(*Corrupt)
+	if !ok {
+		return fmt.Errorf("cannot update %T with %T", c, next)
+	}
+	if c.x == nil {
+		return xdp.ErrServiceNotRunning
+	}
+
+	u := *c
+	u.CorruptRate, u.PayloadOnly, u.FixChecksum = n.CorruptRate, n.PayloadOnly, n.FixChecksum
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	key := uint32(0)
+	if err := c.x.BpfObjs.CorruptParamsMap.Update(key, u.mapValue(), ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("update corrupt params: %w", err)
+	}
+
+	*c = u
+	return nil
+}
+
+func (c *Corrupt) mapValue() xdp.CorruptParams {
+	return xdp.CorruptParams{
+		Rate:        xdp.PercentToPPM(c.CorruptRate),
+		PayloadOnly: boolToUint8(c.PayloadOnly),
+		FixChecksum: boolToUint8(c.FixChecksum),
+	}
+}
+
+func boolToUint8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Stats returns the counters of the packets the service applied to since it
+// was started.
+func (c *Corrupt) Stats() (xdp.ServiceStats, error) {
+	if c.x == nil {
+		return xdp.ServiceStats{}, xdp.ErrServiceNotRunning
+	}
+	return c.x.Stats(xdp.ServiceCorrupt)
+}
@@ -0,0 +1,99 @@
+package xdp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+)
+
+// Direction selects the traffic a service applies to: the ingress traffic
+// is handled by the XDP program and the egress traffic by the TC program.
+type Direction string
+
+const (
+	DirectionIngress Direction = "ingress"
+	DirectionEgress  Direction = "egress"
+	DirectionBoth    Direction = "both"
+)
+
+// The keys of the maps shared by the XDP and the TC programs,
+// see kerns/direction.h
+const (
+	directionKeyIngress uint32 = 0
+	directionKeyEgress  uint32 = 1
+)
+
+// MapKeys returns the keys of the direction in the maps shared by the XDP
+// and the TC programs. An empty direction defaults to ingress.
+func (d Direction) MapKeys() ([]uint32, error) {
+	switch d {
+	case "", DirectionIngress:
+		return []uint32{directionKeyIngress}, nil
+	case DirectionEgress:
+		return []uint32{directionKeyEgress}, nil
+	case DirectionBoth:
+		return []uint32{directionKeyIngress, directionKeyEgress}, nil
+	default:
+		return nil, fmt.Errorf("unknown direction %q (expected %q, %q or %q)",
+			d, DirectionIngress, DirectionEgress, DirectionBoth)
+	}
+}
+
+// HasEgress reports whether the direction includes the egress traffic,
+// which requires the TC program to be attached.
+func (d Direction) HasEgress() bool {
+	return d == DirectionEgress || d == DirectionBoth
+}
+
+// HasIngress reports whether the direction includes the ingress traffic.
+func (d Direction) HasIngress() bool {
+	return d == "" || d == DirectionIngress || d == DirectionBoth
+}
+
+// LookupDirections looks the values of both directions up in a map keyed
+// by direction. A missing key leaves its value untouched.
+func LookupDirections[V any](m *ebpf.Map) (ingress, egress V, err error) {
+	for _, d := range []struct {
+		key   uint32
+		value *V
+	}{
+		{key: directionKeyIngress, value: &ingress},
+		{key: directionKeyEgress, value: &egress},
+	} {
+		if err := m.Lookup(d.key, d.value); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return ingress, egress, err
+		}
+	}
+	return ingress, egress, nil
+}
+
+// IsIngressKey reports whether the map key is the one of the ingress traffic.
+func IsIngressKey(key uint32) bool {
+	return key == directionKeyIngress
+}
+
+// AsymmetricDirection returns the direction of a service which has its own
+// values for the ingress and the egress traffic, hasIngress and hasEgress
+// tell which of them are set. An empty direction is derived from them,
+// otherwise it must include the directions they are set for.
+func AsymmetricDirection(d Direction, hasIngress, hasEgress bool) (Direction, error) {
+	if d == "" {
+		switch {
+		case hasIngress && hasEgress:
+			return DirectionBoth, nil
+		case hasEgress:
+			return DirectionEgress, nil
+		default:
+			return DirectionIngress, nil
+		}
+	}
+
+	if _, err := d.MapKeys(); err != nil {
+		return "", err
+	}
+	if (hasIngress && !d.HasIngress()) || (hasEgress && !d.HasEgress()) {
+		return "", fmt.Errorf("the ingress and egress values do not fit the %q direction", d)
+	}
+	return d, nil
+}
@@ -0,0 +1,67 @@
+package xdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectionMapKeys(t *testing.T) {
+	tests := []struct {
+		direction  Direction
+		keys       []uint32
+		hasEgress  bool
+		shouldFail bool
+	}{
+		{direction: "", keys: []uint32{0}},
+		{direction: DirectionIngress, keys: []uint32{0}},
+		{direction: DirectionEgress, keys: []uint32{1}, hasEgress: true},
+		{direction: DirectionBoth, keys: []uint32{0, 1}, hasEgress: true},
+		{direction: "sideways", shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.direction), func(t *testing.T) {
+			keys, err := tt.direction.MapKeys()
+			if tt.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.keys, keys)
+			assert.Equal(t, tt.hasEgress, tt.direction.HasEgress())
+		})
+	}
+}
+
+func TestAsymmetricDirection(t *testing.T) {
+	tests := []struct {
+		name       string
+		direction  Direction
+		ingress    bool
+		egress     bool
+		want       Direction
+		shouldFail bool
+	}{
+		{name: "derive ingress", ingress: true, want: DirectionIngress},
+		{name: "derive egress", egress: true, want: DirectionEgress},
+		{name: "derive both", ingress: true, egress: true, want: DirectionBoth},
+		{name: "both keeps a zero direction", direction: DirectionBoth, egress: true, want: DirectionBoth},
+		{name: "egress fits", direction: DirectionEgress, egress: true, want: DirectionEgress},
+		{name: "ingress does not fit", direction: DirectionEgress, ingress: true, egress: true, shouldFail: true},
+		{name: "unknown direction", direction: "sideways", ingress: true, shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := AsymmetricDirection(tt.direction, tt.ingress, tt.egress)
+			if tt.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, d)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package duplicate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/cilium/ebpf"
+)
+
+// Duplicate sends a copy of some of the egress packets. The packets are
+// cloned by the TC program with bpf_clone_redirect, so the ingress traffic
+// is not duplicated.
+type Duplicate struct {
+	NetworkInterface *net.Interface
+	DuplicateRate    float64       // Percent, e.g. 1 for 1%
+	Peers            []*net.IPNet  // default: all the traffic
+	Protocol         xdp.Protocol  // default: all the protocols
+	Ports            xdp.PortRange // default: all the ports
+
+	x *xdp.XdpObject // set while the service runs
+}
+
+var (
+	_ xdp.XdpLoader   = (*Duplicate)(nil)
+	_ xdp.Updater     = (*Duplicate)(nil)
+	_ xdp.StatsReader = (*Duplicate)(nil)
+	_ xdp.Adopter     = (*Duplicate)(nil)
+)
+
+// ValidateRate checks that the duplicate rate is a percentage. The kernel applies
+// it with a precision of one part per million (0.0001%).
+func ValidateRate(rate float64) error {
+	if rate < 0 || rate > 100 || math.IsNaN(rate) {
+		return fmt.Errorf("duplicate rate must be between 0 and 100, got %v", rate)
+	}
+	if rate != 0 && xdp.PercentToPPM(rate) == 0 {
+		// it would read as a stopped service in the kernel
+		return fmt.Errorf("duplicate rate %v is below one part per million (0.0001%%)", rate)
+	}
+	return nil
+}
+
+func (d *Duplicate) Validate() error {
+	if d.NetworkInterface == nil {
+		return xdp.ErrNetworkInterfaceNotSet
+	}
+	if err := ValidateRate(d.DuplicateRate); err != nil {
+		return err
+	}
+
+	_, err := xdp.NewProtocolFilter(d.Protocol, d.Ports)
+	return err
+}
+
+func (d *Duplicate) Start() (xdp.CancelFunc, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	x, err := xdp.GetPreparedXdpObject(d.NetworkInterface.Index)
+	if err != nil {
+		return nil, fmt.Errorf("prepare XDP object: %w", err)
+	}
+
+	key := uint32(0)
+	err = x.AttachTc()
+	if err == nil {
+		err = x.ResetStats(xdp.ServiceDuplicate)
+	}
+	if err == nil {
+		err = x.SetPeers(xdp.ServiceDuplicate, d.Peers)
+	}
+	if err == nil {
+		err = x.SetProtocolFilter(xdp.ServiceDuplicate, d.Protocol, d.Ports)
+	}
+	if err == nil {
+		err = x.BpfObjs.DuplicateRateMap.Update(key, xdp.PercentToPPM(d.DuplicateRate), ebpf.UpdateAny)
+	}
+	if err != nil {
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, fmt.Errorf("update duplicate rate: %w", err)
+	}
+
+	return d.run(x), nil
+}
+
+// Adopt takes over the duplication a previous process left in the pinned
+// maps.
+func (d *Duplicate) Adopt() (xdp.CancelFunc, bool, error) {
+	x, ok, err := xdp.AdoptObject(d.NetworkInterface.Index, d.readMaps)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return d.run(x), true, nil
+}
+
+// readMaps sets the parameters of the service from the maps, and reports
+// whether it runs.
+func (d *Duplicate) readMaps(x *xdp.XdpObject) (bool, error) {
+	var rate uint32
+	if err := x.BpfObjs.DuplicateRateMap.Lookup(uint32(0), &rate); err != nil {
+		return false, err
+	}
+	if rate == 0 {
+		return false, nil
+	}
+	d.DuplicateRate = xdp.PPMToPercent(rate)
+
+	var err error
+	if d.Peers, err = x.Peers(xdp.ServiceDuplicate); err != nil {
+		return false, err
+	}
+	if d.Protocol, d.Ports, err = x.ProtocolFilter(xdp.ServiceDuplicate); err != nil {
+		return false, err
+	}
+	return true, d.Validate()
+}
+
+// run marks the service as running on the XDP object, and returns the
+// function which stops it.
+func (d *Duplicate) run(x *xdp.XdpObject) xdp.CancelFunc {
+	key := uint32(0)
+	d.x = x
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+	}()
+
+	cancelFunc := xdp.CancelFunc(func() error {
+		// Update the map with a rate of 0 to disable the duplication.
+		err := x.BpfObjs.DuplicateRateMap.Update(key, uint32(0), ebpf.UpdateAny)
+		if err != nil {
+			return fmt.Errorf("update duplicate rate to zero: %w", err)
+		}
+
+		d.x = nil
+
+		if err := x.SetPeers(xdp.ServiceDuplicate, nil); err != nil {
+			return fmt.Errorf("clear duplicate peers: %w", err)
+		}
+		if err := x.SetProtocolFilter(xdp.ServiceDuplicate, xdp.ProtocolAny, xdp.PortRange{}); err != nil {
+			return fmt.Errorf("clear duplicate protocol filter: %w", err)
+		}
+
+		if err := x.Close(); err != nil {
+			return fmt.Errorf("close XDP object: %w", err)
+		}
+		cancel()
+		return nil
+	})
+
+	return cancelFunc
+}
+
+// Update changes the duplicate rate of the running service.
+func (d *Duplicate) Update(next xdp.XdpLoader) error {
+	n, ok := next.(*Duplicate)
+	if !ok {
+		return fmt.Errorf("cannot update %T with %T", d, next)
+	}
+	if d.x == nil {
+		return xdp.ErrServiceNotRunning
+	}
+
+	u := *d
+	u.DuplicateRate = n.DuplicateRate
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	key := uint32(0)
+	if err := d.x.BpfObjs.DuplicateRateMap.Update(key, xdp.PercentToPPM(u.DuplicateRate), ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("update duplicate rate: %w", err)
+	}
+
+	*d = u
+	return nil
+}
+
+// Stats returns the counters of the packets the service applied to since it
+// was started.
+func (d *Duplicate) Stats() (xdp.ServiceStats, error) {
+	if d.x == nil {
+		return xdp.ServiceStats{}, xdp.ErrServiceNotRunning
+	}
+	return d.x.Stats(xdp.ServiceDuplicate)
+}
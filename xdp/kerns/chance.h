@@ -0,0 +1,17 @@
+// go:build ignore
+#ifndef __CHANCE_H
+#define __CHANCE_H
+
+#include <linux/bpf.h>
+#include <bpf/bpf_helpers.h>
+
+// The probabilities of the services are in parts per million.
+#define PPM 1000000
+
+// Returns 1 with the given probability.
+static __always_inline int chance_ppm(__u32 ppm)
+{
+  return bpf_get_prandom_u32() % PPM < ppm;
+}
+
+#endif
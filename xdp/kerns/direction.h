@@ -0,0 +1,11 @@
+// go:build ignore
+#ifndef __DIRECTION_H
+#define __DIRECTION_H
+
+// The maps shared by the XDP and the TC programs are keyed by the traffic
+// direction, so each direction can be configured independently.
+#define DIRECTION_INGRESS 0
+#define DIRECTION_EGRESS 1
+#define MAX_DIRECTIONS 2
+
+#endif
@@ -1,17 +1,91 @@
 // go:build ignore
 
+#include "xdp_partition.c"
 #include "xdp_bandwidth.c"
 #include "xdp_packetloss.c"
+#include "tc_latency.c"
+#include "tc_duplicate.c"
+#include "tc_reorder.c"
+#include "tc_corrupt.c"
 
 char _license[] SEC("license") = "GPL";
 
 SEC("xdp")
 int xdp_main(struct xdp_md *ctx)
 {
-  int action = xdp_packetloss(ctx);
+  struct packet pkt = {};
+  parse_packet((void *)(long)ctx->data, (void *)(long)ctx->data_end, DIRECTION_INGRESS, &pkt);
+
+  int action = xdp_partition(ctx, &pkt);
+  if (action != XDP_PASS)
+  {
+    return action;
+  }
+  action = xdp_packetloss(ctx, &pkt);
   if (action != XDP_PASS)
   {
     return action;
   }
-  return xdp_bandwidth_limit(ctx);
-}
\ No newline at end of file
+  return xdp_bandwidth_limit(ctx, &pkt);
+}
+
+SEC("tc")
+int tc_main(struct __sk_buff *skb)
+{
+  // The packets the ifb device of the tc latency engine sends back went
+  // through the services already, before they were redirected to it.
+  __u32 ifb = latency_ifb();
+  if (ifb && skb->ingress_ifindex == ifb)
+  {
+    return TC_ACT_OK;
+  }
+
+  struct packet pkt = {};
+  parse_packet((void *)(long)skb->data, (void *)(long)skb->data_end, DIRECTION_EGRESS, &pkt);
+
+  int action;
+  // The copies sent by tc_duplicate went through the services up to it
+  // already, as the original packet.
+  if (!is_duplicate())
+  {
+    action = tc_partition(skb, &pkt);
+    if (action != TC_ACT_OK)
+    {
+      return action;
+    }
+    action = tc_packetloss(skb, &pkt);
+    if (action != TC_ACT_OK)
+    {
+      return action;
+    }
+    action = tc_bandwidth_limit(skb, &pkt);
+    if (action != TC_ACT_OK)
+    {
+      return action;
+    }
+    action = tc_duplicate(skb, &pkt);
+    if (action != TC_ACT_OK)
+    {
+      return action;
+    }
+  }
+  action = tc_corrupt(skb, &pkt);
+  if (action != TC_ACT_OK)
+  {
+    return action;
+  }
+  action = tc_latency(skb, &pkt);
+  if (action != TC_ACT_OK)
+  {
+    return action;
+  }
+  action = tc_reorder(skb, &pkt);
+  if (action == TC_ACT_OK && ifb)
+  {
+    // The filter of the clsact qdisc redirects the packet to the ifb device
+    // next. bpf_redirect cannot: the ifb device drops the packets which do
+    // not carry the index of the network interface, as mirred sets it.
+    return TC_ACT_UNSPEC;
+  }
+  return action;
+}
@@ -0,0 +1,185 @@
+// go:build ignore
+#ifndef __PACKET_H
+#define __PACKET_H
+
+#include <linux/bpf.h>
+#include <linux/if_ether.h>
+#include <linux/in.h>
+#include <linux/in6.h>
+#include <linux/ip.h>
+#include <linux/ipv6.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_endian.h>
+#include "direction.h"
+
+#define IP_OFFSET 0x1FFF
+#define IPV6_FRAG_OFFSET 0xFFF8
+#define MAX_IPV6_EXT_HEADERS 4
+
+// Key of the peer LPM tries. IPv4 addresses are stored as IPv4-mapped IPv6
+// addresses (::ffff:a.b.c.d), so one trie holds both families.
+struct peer_key
+{
+  __u32 prefixlen;
+  __u8 addr[16];
+};
+
+// What the services need to know about a packet; it is parsed once per
+// packet and shared by all of them.
+struct packet
+{
+  __u8 is_ip;
+  // The remote end of the packet: the source address of the ingress
+  // packets and the destination address of the egress packets.
+  struct peer_key peer;
+  __u8 protocol; // IPPROTO_*, 0 if the packet is not an IP packet
+  __u8 has_ports;
+  __u16 src_port; // Host byte order
+  __u16 dst_port; // Host byte order
+  // Offsets from the start of the packet, for the services rewriting it.
+  __u16 l3_off;
+  __u16 l4_off; // Only valid if has_ports is set
+};
+
+struct vlan_hdr
+{
+  __be16 h_vlan_tci;
+  __be16 h_vlan_encapsulated_proto;
+};
+
+// The ports are at the same offset in the TCP and the UDP headers.
+struct ports_hdr
+{
+  __be16 source;
+  __be16 dest;
+};
+
+struct ipv6_ext_hdr
+{
+  __u8 nexthdr;
+  __u8 hdrlen; // In 8-byte units, not including the first 8 bytes
+};
+
+struct ipv6_frag_hdr
+{
+  __u8 nexthdr;
+  __u8 reserved;
+  __be16 frag_off;
+  __be32 identification;
+};
+
+static __always_inline void parse_ports(void *l4, void *data_end, struct packet *pkt)
+{
+  if (pkt->protocol != IPPROTO_TCP && pkt->protocol != IPPROTO_UDP)
+    return;
+
+  struct ports_hdr *ports = l4;
+  if ((void *)(ports + 1) > data_end)
+    return;
+
+  pkt->src_port = bpf_ntohs(ports->source);
+  pkt->dst_port = bpf_ntohs(ports->dest);
+  pkt->has_ports = 1;
+}
+
+static __always_inline void parse_packet(void *data, void *data_end, __u32 direction, struct packet *pkt)
+{
+  struct ethhdr *eth = data;
+  if ((void *)(eth + 1) > data_end)
+    return;
+
+  void *l3 = eth + 1;
+  __u32 l3_off = sizeof(*eth);
+  __u16 proto = eth->h_proto;
+  if (proto == bpf_htons(ETH_P_8021Q) || proto == bpf_htons(ETH_P_8021AD))
+  {
+    struct vlan_hdr *vlan = l3;
+    if ((void *)(vlan + 1) > data_end)
+      return;
+    proto = vlan->h_vlan_encapsulated_proto;
+    l3 = vlan + 1;
+    l3_off += sizeof(*vlan);
+  }
+
+  if (proto == bpf_htons(ETH_P_IP))
+  {
+    struct iphdr *iph = l3;
+    if ((void *)(iph + 1) > data_end)
+      return;
+
+    __u32 addr = direction == DIRECTION_INGRESS ? iph->saddr : iph->daddr;
+    __builtin_memset(pkt->peer.addr, 0, 10);
+    pkt->peer.addr[10] = 0xff;
+    pkt->peer.addr[11] = 0xff;
+    __builtin_memcpy(&pkt->peer.addr[12], &addr, 4);
+    pkt->peer.prefixlen = 128;
+    pkt->is_ip = 1;
+    pkt->protocol = iph->protocol;
+    pkt->l3_off = l3_off;
+
+    // Only the first fragment carries the transport header.
+    if (iph->frag_off & bpf_htons(IP_OFFSET))
+      return;
+
+    __u32 ihl = iph->ihl;
+    if (ihl < 5)
+      return;
+    pkt->l4_off = l3_off + ihl * 4;
+    parse_ports(l3 + ihl * 4, data_end, pkt);
+  }
+  else if (proto == bpf_htons(ETH_P_IPV6))
+  {
+    struct ipv6hdr *ip6h = l3;
+    if ((void *)(ip6h + 1) > data_end)
+      return;
+
+    if (direction == DIRECTION_INGRESS)
+      __builtin_memcpy(pkt->peer.addr, &ip6h->saddr, 16);
+    else
+      __builtin_memcpy(pkt->peer.addr, &ip6h->daddr, 16);
+    pkt->peer.prefixlen = 128;
+    pkt->is_ip = 1;
+    pkt->l3_off = l3_off;
+
+    __u8 nexthdr = ip6h->nexthdr;
+    void *l4 = ip6h + 1;
+    __u32 l4_off = l3_off + sizeof(*ip6h);
+
+#pragma unroll
+    for (int i = 0; i < MAX_IPV6_EXT_HEADERS; i++)
+    {
+      if (nexthdr == IPPROTO_HOPOPTS || nexthdr == IPPROTO_ROUTING || nexthdr == IPPROTO_DSTOPTS)
+      {
+        struct ipv6_ext_hdr *ext = l4;
+        if ((void *)(ext + 1) > data_end)
+          return;
+        nexthdr = ext->nexthdr;
+        l4 += (ext->hdrlen + 1) * 8;
+        l4_off += (ext->hdrlen + 1) * 8;
+      }
+      else if (nexthdr == IPPROTO_FRAGMENT)
+      {
+        struct ipv6_frag_hdr *frag = l4;
+        if ((void *)(frag + 1) > data_end)
+          return;
+        nexthdr = frag->nexthdr;
+        pkt->protocol = nexthdr;
+        // Only the first fragment carries the transport header.
+        if (frag->frag_off & bpf_htons(IPV6_FRAG_OFFSET))
+          return;
+        l4 = frag + 1;
+        l4_off += sizeof(*frag);
+      }
+      else
+      {
+        break;
+      }
+    }
+
+    pkt->protocol = nexthdr;
+    pkt->l4_off = l4_off;
+    parse_ports(l4, data_end, pkt);
+  }
+}
+
+#endif
@@ -0,0 +1,54 @@
+// go:build ignore
+#ifndef __PEERS_H
+#define __PEERS_H
+
+#include <linux/bpf.h>
+#include <bpf/bpf_helpers.h>
+#include "packet.h"
+#include "services.h"
+
+#define MAX_PEERS 1024
+
+// Each service has its own trie of peers; the service only applies to the
+// packets of the peers in its trie, if the filter of the service is enabled.
+#define PEERS_MAP(name)                   \
+  struct                                  \
+  {                                       \
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);  \
+    __type(key, struct peer_key);         \
+    __type(value, __u8);                  \
+    __uint(max_entries, MAX_PEERS);       \
+    __uint(map_flags, BPF_F_NO_PREALLOC); \
+  } name SEC(".maps")
+
+PEERS_MAP(packetloss_peers);
+PEERS_MAP(bandwidth_peers);
+PEERS_MAP(latency_peers);
+PEERS_MAP(duplicate_peers);
+PEERS_MAP(reorder_peers);
+PEERS_MAP(corrupt_peers);
+PEERS_MAP(partition_peers);
+
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, __u32); // 1 if the peers of the service are filtered
+  __uint(max_entries, MAX_SERVICES);
+} peer_filter_map SEC(".maps");
+
+// Returns 1 if the service applies to the packet.
+static __always_inline int peer_matches(void *peers, __u32 service, struct packet *pkt)
+{
+  __u32 *enabled = bpf_map_lookup_elem(&peer_filter_map, &service);
+  if (!enabled || *enabled == 0)
+    return 1;
+
+  // pkt may be NULL for the verifier, as the services are global functions
+  if (!pkt || !pkt->is_ip)
+    return 0;
+
+  return bpf_map_lookup_elem(peers, &pkt->peer) != 0;
+}
+
+#endif
@@ -0,0 +1,66 @@
+// go:build ignore
+#ifndef __PROTOCOLS_H
+#define __PROTOCOLS_H
+
+#include <linux/bpf.h>
+#include <linux/in.h>
+#include <linux/in6.h>
+#include <bpf/bpf_helpers.h>
+#include "packet.h"
+#include "services.h"
+
+// Scopes a service to a transport protocol and/or a port range. The port
+// range matches the TCP and UDP packets whose source or destination port is
+// in the range.
+struct protocol_filter
+{
+  __u32 protocol; // IPPROTO_*, 0 for any protocol
+  __u16 port_min;
+  __u16 port_max; // 0 for any port
+};
+
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, struct protocol_filter);
+  __uint(max_entries, MAX_SERVICES);
+} protocol_filter_map SEC(".maps");
+
+static __always_inline int port_in_range(__u16 port, struct protocol_filter *filter)
+{
+  return port >= filter->port_min && port <= filter->port_max;
+}
+
+// Returns 1 if the service applies to the packet.
+static __always_inline int protocol_matches(__u32 service, struct packet *pkt)
+{
+  struct protocol_filter *filter = bpf_map_lookup_elem(&protocol_filter_map, &service);
+  if (!filter || (filter->protocol == 0 && filter->port_max == 0))
+    return 1;
+
+  // pkt may be NULL for the verifier, as the services are global functions
+  if (!pkt || !pkt->is_ip)
+    return 0;
+
+  if (filter->protocol == IPPROTO_ICMP)
+  {
+    // ICMP stands for both ICMP and ICMPv6
+    if (pkt->protocol != IPPROTO_ICMP && pkt->protocol != IPPROTO_ICMPV6)
+      return 0;
+  }
+  else if (filter->protocol != 0 && pkt->protocol != filter->protocol)
+  {
+    return 0;
+  }
+
+  if (filter->port_max == 0)
+    return 1;
+
+  if (!pkt->has_ports)
+    return 0;
+
+  return port_in_range(pkt->src_port, filter) || port_in_range(pkt->dst_port, filter);
+}
+
+#endif
@@ -0,0 +1,16 @@
+// go:build ignore
+#ifndef __SERVICES_H
+#define __SERVICES_H
+
+// The services, used as key of the maps holding the rules of all the
+// services, e.g. peer_filter_map.
+#define SERVICE_PACKETLOSS 0
+#define SERVICE_BANDWIDTH 1
+#define SERVICE_LATENCY 2
+#define SERVICE_DUPLICATE 3
+#define SERVICE_REORDER 4
+#define SERVICE_CORRUPT 5
+#define SERVICE_PARTITION 6
+#define MAX_SERVICES 7
+
+#endif
@@ -0,0 +1,74 @@
+// go:build ignore
+#ifndef __STATS_H
+#define __STATS_H
+
+#include <linux/bpf.h>
+#include <bpf/bpf_helpers.h>
+#include "direction.h"
+#include "services.h"
+
+// What a service did with a packet it applies to. The delayed and modified
+// packets are passed too.
+#define STATS_PASSED 0
+#define STATS_DROPPED 1
+#define STATS_DELAYED 2
+#define STATS_MODIFIED 3
+
+// Counters of the packets a service applies to in one direction, i.e. the
+// packets which match its peers and protocol filter while it runs.
+struct service_stats
+{
+  __u64 packets_seen;
+  __u64 bytes_seen;
+  __u64 packets_passed;
+  __u64 bytes_passed;
+  __u64 packets_dropped;
+  __u64 bytes_dropped;
+  __u64 packets_delayed;
+  __u64 bytes_delayed;
+  __u64 packets_modified; // duplicated or corrupted
+  __u64 bytes_modified;
+};
+
+// Keyed by service * MAX_DIRECTIONS + direction, kept per CPU so the
+// counters are updated without atomic operations.
+struct
+{
+  __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+  __type(key, __u32);
+  __type(value, struct service_stats);
+  __uint(max_entries, MAX_SERVICES * MAX_DIRECTIONS);
+} stats_map SEC(".maps");
+
+static __always_inline void stats_count(__u32 service, __u32 direction, __u64 bytes, int outcome)
+{
+  __u32 key = service * MAX_DIRECTIONS + direction;
+  struct service_stats *stats = bpf_map_lookup_elem(&stats_map, &key);
+  if (!stats)
+    return;
+
+  stats->packets_seen++;
+  stats->bytes_seen += bytes;
+
+  if (outcome == STATS_DROPPED)
+  {
+    stats->packets_dropped++;
+    stats->bytes_dropped += bytes;
+    return;
+  }
+
+  stats->packets_passed++;
+  stats->bytes_passed += bytes;
+  if (outcome == STATS_DELAYED)
+  {
+    stats->packets_delayed++;
+    stats->bytes_delayed += bytes;
+  }
+  else if (outcome == STATS_MODIFIED)
+  {
+    stats->packets_modified++;
+    stats->bytes_modified += bytes;
+  }
+}
+
+#endif
@@ -0,0 +1,172 @@
+// go:build ignore
+#include <linux/bpf.h>
+#include <linux/pkt_cls.h>
+#include <bpf/bpf_helpers.h>
+#include "peers.h"
+#include "protocols.h"
+#include "chance.h"
+#include "stats.h"
+
+#define TCP_CSUM_OFF 16
+#define UDP_CSUM_OFF 6
+#define UDP_HDR_LEN 8
+
+// Corrupts the egress packets by flipping one random bit of the packet, from
+// the network header on. The packets are seen before segmentation offload, so
+// a corrupted GSO packet may show up as several corrupted segments.
+struct corrupt_params
+{
+  __u32 rate; // Parts per million
+  // Only flip a bit of the TCP/UDP payload, the other packets are left intact.
+  __u8 payload_only;
+  // Update the TCP/UDP checksum, so the corruption reaches the application.
+  // It implies payload_only.
+  __u8 fix_checksum;
+};
+
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, struct corrupt_params);
+  __uint(max_entries, 1);
+} corrupt_params_map SEC(".maps");
+
+// Returns the length of the TCP/UDP header, 0 if it cannot be read.
+static __always_inline __u32 l4_header_len(struct __sk_buff *skb, struct packet *pkt)
+{
+  if (pkt->protocol == IPPROTO_UDP)
+    return UDP_HDR_LEN;
+
+  __u8 doff;
+  if (bpf_skb_load_bytes(skb, pkt->l4_off + 12, &doff, sizeof(doff)) < 0)
+    return 0;
+  return (doff >> 4) * 4;
+}
+
+// Loads the 16-bit word of the TCP/UDP checksum holding the byte at off; the
+// last byte of an odd-length segment is padded with zero.
+static __always_inline __u16 load_csum_word(struct __sk_buff *skb, __u32 word_off)
+{
+  union
+  {
+    __u8 bytes[2];
+    __u16 word;
+  } w = {};
+  if (word_off + 2 <= skb->len)
+    bpf_skb_load_bytes(skb, word_off, w.bytes, 2);
+  else
+    bpf_skb_load_bytes(skb, word_off, w.bytes, 1);
+  return w.word;
+}
+
+// Flips one random bit of the packet, from the network header on or in the
+// TCP/UDP payload only, and returns 1 if it did.
+static __always_inline int corrupt_bit(struct __sk_buff *skb, struct packet *pkt, struct corrupt_params *params, int has_csum, int payload_only)
+{
+  __u32 start = pkt->l3_off;
+  if (payload_only)
+  {
+    __u32 hdr_len = l4_header_len(skb, pkt);
+    if (hdr_len == 0)
+      return 0;
+    start = pkt->l4_off + hdr_len;
+  }
+  if (start >= skb->len)
+  {
+    return 0;
+  }
+
+  __u32 off = start + bpf_get_prandom_u32() % (skb->len - start);
+  __u32 csum_off = pkt->l4_off + (pkt->protocol == IPPROTO_TCP ? TCP_CSUM_OFF : UDP_CSUM_OFF);
+  // The bits of the checksum itself, and of the headers before it, are not
+  // covered by the TCP/UDP checksum.
+  if (has_csum && (off < pkt->l4_off || (off >= csum_off && off < csum_off + 2)))
+  {
+    has_csum = 0;
+  }
+
+  // The checksum is computed over 16-bit words from the start of the segment.
+  __u32 word_off = off - ((off - pkt->l4_off) & 1);
+  __u16 from = 0;
+  if (has_csum)
+  {
+    from = load_csum_word(skb, word_off);
+  }
+
+  __u8 byte;
+  if (bpf_skb_load_bytes(skb, off, &byte, sizeof(byte)) < 0)
+  {
+    return 0;
+  }
+  byte ^= 1 << (bpf_get_prandom_u32() % 8);
+  if (bpf_skb_store_bytes(skb, off, &byte, sizeof(byte), 0) < 0)
+  {
+    return 0;
+  }
+
+  if (!has_csum)
+  {
+    return 1;
+  }
+
+  __u16 to = load_csum_word(skb, word_off);
+  __u64 flags = sizeof(__u16);
+  if (pkt->protocol == IPPROTO_UDP)
+  {
+    // A null UDP checksum means no checksum over IPv4
+    flags |= BPF_F_MARK_MANGLED_0;
+  }
+
+  if (params->fix_checksum)
+  {
+    // With checksum offload (CHECKSUM_PARTIAL) the checksum is computed
+    // later over the corrupted payload, and the helper leaves it as is.
+    bpf_l4_csum_replace(skb, csum_off, from, to, flags);
+    return 1;
+  }
+
+  // Keep the checksum of the original packet, so the receiver detects the
+  // corruption. With checksum offload, the field holds the pseudo-header
+  // seed the checksum is computed from: updating it with the opposite change
+  // makes the computed checksum match the original payload. Without it, the
+  // two updates cancel each other out.
+  bpf_l4_csum_replace(skb, csum_off, to, from, flags | BPF_F_PSEUDO_HDR);
+  bpf_l4_csum_replace(skb, csum_off, from, to, flags);
+
+  return 1;
+}
+
+int tc_corrupt(struct __sk_buff *skb, struct packet *pkt)
+{
+  __u32 key = 0;
+  struct corrupt_params *params = bpf_map_lookup_elem(&corrupt_params_map, &key);
+  if (!params || params->rate == 0)
+  {
+    // if it has not set by the user space program,
+    // or the service is stopped
+    return TC_ACT_OK;
+  }
+
+  if (!peer_matches(&corrupt_peers, SERVICE_CORRUPT, pkt) || !protocol_matches(SERVICE_CORRUPT, pkt))
+  {
+    return TC_ACT_OK;
+  }
+
+  // pkt may be NULL for the verifier, as the services are global functions
+  if (!pkt || !pkt->is_ip)
+  {
+    return TC_ACT_OK;
+  }
+
+  int has_csum = pkt->has_ports && (pkt->protocol == IPPROTO_TCP || pkt->protocol == IPPROTO_UDP);
+  int payload_only = params->payload_only || params->fix_checksum;
+  int corrupted = 0;
+  if ((!payload_only || has_csum) && chance_ppm(params->rate))
+  {
+    corrupted = corrupt_bit(skb, pkt, params, has_csum, payload_only);
+  }
+
+  stats_count(SERVICE_CORRUPT, DIRECTION_EGRESS, skb->len, corrupted ? STATS_MODIFIED : STATS_PASSED);
+  return TC_ACT_OK;
+}
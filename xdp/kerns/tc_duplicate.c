@@ -0,0 +1,73 @@
+// go:build ignore
+#include <linux/bpf.h>
+#include <linux/pkt_cls.h>
+#include <bpf/bpf_helpers.h>
+#include "peers.h"
+#include "protocols.h"
+#include "chance.h"
+#include "stats.h"
+
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, __u32); // Duplicate rate in parts per million
+  __uint(max_entries, 1);
+} duplicate_rate_map SEC(".maps");
+
+// Set while tc_duplicate sends a copy of a packet. bpf_clone_redirect runs
+// the egress TC program on the copy before it returns, on the same CPU, so
+// the copy is told apart without tagging it, e.g. with the mark other
+// programs use too.
+struct
+{
+  __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+  __type(key, __u32);
+  __type(value, __u32);
+  __uint(max_entries, 1);
+} duplicate_cloning_map SEC(".maps");
+
+// Returns 1 if the packet is a copy sent by tc_duplicate.
+static __always_inline int is_duplicate(void)
+{
+  __u32 key = 0;
+  __u32 *cloning = bpf_map_lookup_elem(&duplicate_cloning_map, &key);
+  return cloning && *cloning;
+}
+
+// Duplicates the egress packets: a copy of the packet is sent before it
+// with bpf_clone_redirect.
+int tc_duplicate(struct __sk_buff *skb, struct packet *pkt)
+{
+  __u32 key = 0;
+  __u32 *rate = bpf_map_lookup_elem(&duplicate_rate_map, &key);
+  if (!rate || *rate == 0)
+  {
+    // if it has not set by the user space program,
+    // or the service is stopped
+    return TC_ACT_OK;
+  }
+
+  if (!peer_matches(&duplicate_peers, SERVICE_DUPLICATE, pkt) || !protocol_matches(SERVICE_DUPLICATE, pkt))
+  {
+    return TC_ACT_OK;
+  }
+
+  if (!chance_ppm(*rate))
+  {
+    stats_count(SERVICE_DUPLICATE, DIRECTION_EGRESS, skb->len, STATS_PASSED);
+    return TC_ACT_OK;
+  }
+
+  __u32 *cloning = bpf_map_lookup_elem(&duplicate_cloning_map, &key);
+  if (!cloning)
+  {
+    return TC_ACT_OK;
+  }
+  *cloning = 1;
+  bpf_clone_redirect(skb, skb->ifindex, 0);
+  *cloning = 0;
+
+  stats_count(SERVICE_DUPLICATE, DIRECTION_EGRESS, skb->len, STATS_MODIFIED);
+  return TC_ACT_OK;
+}
@@ -0,0 +1,81 @@
+// go:build ignore
+#include <linux/bpf.h>
+#include <linux/pkt_cls.h>
+#include <bpf/bpf_helpers.h>
+#include "peers.h"
+#include "protocols.h"
+#include "stats.h"
+
+// Delay of the egress packets, it relies on the fq qdisc to hold the packets
+// until their earliest departure time (skb->tstamp).
+struct latency_params
+{
+  __u64 latency_ns;
+  __u64 jitter_ns;
+};
+
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, struct latency_params);
+  __uint(max_entries, 1);
+} latency_params_map SEC(".maps");
+
+// Index of the ifb device whose netem qdisc delays the egress packets with
+// the tc engine, 0 if the engine does not run. tc_main passes the packets on
+// to the filter redirecting them to it once the other services ran, and skips
+// the packets the ifb device sends back through the network interface.
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, __u32);
+  __uint(max_entries, 1);
+} latency_ifb_map SEC(".maps");
+
+// Returns the index of the ifb device of the tc engine, or 0.
+static __always_inline __u32 latency_ifb(void)
+{
+  __u32 key = 0;
+  __u32 *ifindex = bpf_map_lookup_elem(&latency_ifb_map, &key);
+  return ifindex ? *ifindex : 0;
+}
+
+int tc_latency(struct __sk_buff *skb, struct packet *pkt)
+{
+  __u32 key = 0;
+  struct latency_params *params = bpf_map_lookup_elem(&latency_params_map, &key);
+  if (!params)
+  {
+    return TC_ACT_OK;
+  }
+
+  if (params->latency_ns == 0 && params->jitter_ns == 0)
+  {
+    // If the service is stopped
+    return TC_ACT_OK;
+  }
+
+  if (!peer_matches(&latency_peers, SERVICE_LATENCY, pkt) || !protocol_matches(SERVICE_LATENCY, pkt))
+  {
+    return TC_ACT_OK;
+  }
+
+  __u64 delay = params->latency_ns;
+  if (params->jitter_ns > 0)
+  {
+    // Uniformly distributed in [latency - jitter, latency + jitter], drawn
+    // from 64 random bits so jitters beyond 2^32 ns cover the whole range
+    __u64 random = ((__u64)bpf_get_prandom_u32() << 32) | bpf_get_prandom_u32();
+    __u64 jitter = random % (2 * params->jitter_ns + 1);
+    delay = delay + jitter > params->jitter_ns ? delay + jitter - params->jitter_ns : 0;
+  }
+
+  __u64 now = bpf_ktime_get_ns();
+  __u64 departure = skb->tstamp > now ? skb->tstamp : now;
+  skb->tstamp = departure + delay;
+
+  stats_count(SERVICE_LATENCY, DIRECTION_EGRESS, skb->len, STATS_DELAYED);
+  return TC_ACT_OK;
+}
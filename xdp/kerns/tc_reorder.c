@@ -0,0 +1,75 @@
+// go:build ignore
+#include <linux/bpf.h>
+#include <linux/pkt_cls.h>
+#include <bpf/bpf_helpers.h>
+#include "peers.h"
+#include "protocols.h"
+#include "chance.h"
+#include "stats.h"
+
+// Reorders the egress packets: a packet is held back by delay_ns, relying on
+// the fq qdisc like the latency, so the packets sent after it overtake it.
+struct reorder_params
+{
+  __u32 rate; // Parts per million
+  // At least gap - 1 packets are sent in order between two reordered packets.
+  __u32 gap;
+  __u64 delay_ns;
+};
+
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, struct reorder_params);
+  __uint(max_entries, 1);
+} reorder_params_map SEC(".maps");
+
+// The number of packets sent in order since the last reordered packet, kept
+// per CPU so the packets processed on different CPUs do not race on it.
+struct
+{
+  __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+  __type(key, __u32);
+  __type(value, __u32);
+  __uint(max_entries, 1);
+} reorder_gap_map SEC(".maps");
+
+int tc_reorder(struct __sk_buff *skb, struct packet *pkt)
+{
+  __u32 key = 0;
+  struct reorder_params *params = bpf_map_lookup_elem(&reorder_params_map, &key);
+  if (!params || params->rate == 0)
+  {
+    // if it has not set by the user space program,
+    // or the service is stopped
+    return TC_ACT_OK;
+  }
+
+  if (!peer_matches(&reorder_peers, SERVICE_REORDER, pkt) || !protocol_matches(SERVICE_REORDER, pkt))
+  {
+    return TC_ACT_OK;
+  }
+
+  __u32 *in_order = bpf_map_lookup_elem(&reorder_gap_map, &key);
+  if (!in_order)
+  {
+    return TC_ACT_OK;
+  }
+
+  if (*in_order + 1 < params->gap || !chance_ppm(params->rate))
+  {
+    if (*in_order < params->gap)
+      (*in_order)++;
+    stats_count(SERVICE_REORDER, DIRECTION_EGRESS, skb->len, STATS_PASSED);
+    return TC_ACT_OK;
+  }
+  *in_order = 0;
+
+  __u64 now = bpf_ktime_get_ns();
+  __u64 departure = skb->tstamp > now ? skb->tstamp : now;
+  skb->tstamp = departure + params->delay_ns;
+
+  stats_count(SERVICE_REORDER, DIRECTION_EGRESS, skb->len, STATS_DELAYED);
+  return TC_ACT_OK;
+}
@@ -1,93 +1,134 @@
 // go:build ignore
 #include <linux/bpf.h>
+#include <linux/pkt_cls.h>
 #include <bpf/bpf_helpers.h>
 #include <string.h>
+#include "direction.h"
+#include "peers.h"
+#include "protocols.h"
+#include "stats.h"
 
-#define MAX_MAP_ENTRIES 1
 #define NANOS_PER_SEC 1000000000UL
-// A time window is used to measure the average bandwidth.
-#define TIME_WINDOW_SEC 5
 
-struct
+struct bandwidth_limit
 {
-  __uint(type, BPF_MAP_TYPE_HASH);
-  __type(key, __u32);
-  __type(value, __u64);
-  __uint(max_entries, MAX_MAP_ENTRIES);
-} last_packet_timestamp SEC(".maps");
+  __u64 rate_bps;    // Bits per second
+  __u64 burst_bytes; // Size of the token bucket
+};
+
+// The tokens are bytes, refilled at rate_bps / 8 bytes per second up to
+// burst_bytes.
+struct token_bucket
+{
+  __u64 tokens;
+  __u64 last_refill_ns;
+};
 
 struct
 {
-  __uint(type, BPF_MAP_TYPE_LRU_HASH);
+  __uint(type, BPF_MAP_TYPE_HASH);
   __type(key, __u32);
-  __type(value, __u64);
-  __uint(max_entries, MAX_MAP_ENTRIES);
-} byte_counter SEC(".maps");
+  __type(value, struct token_bucket);
+  __uint(max_entries, MAX_DIRECTIONS);
+} token_bucket_map SEC(".maps");
 
 struct
 {
   __uint(type, BPF_MAP_TYPE_LRU_HASH);
   __type(key, __u32);
-  __type(value, __u64); // Bytes per second
-  __uint(max_entries, MAX_MAP_ENTRIES);
+  __type(value, struct bandwidth_limit);
+  __uint(max_entries, MAX_DIRECTIONS);
 } bandwidth_limit_map SEC(".maps");
 
-int xdp_bandwidth_limit(struct xdp_md *ctx)
+// Returns 1 if the packet exceeds the bandwidth limit and has to be dropped.
+int bandwidth_limit_exceeded(__u32 direction, __u64 packet_size, struct packet *pkt)
 {
-  __u32 key = 0;
-  __u64 *bandwidth_limit_ptr = bpf_map_lookup_elem(&bandwidth_limit_map, &key);
-  if (!bandwidth_limit_ptr)
+  __u32 key = direction;
+  struct bandwidth_limit *limit = bpf_map_lookup_elem(&bandwidth_limit_map, &key);
+  if (!limit)
   {
     // if it has not set by the user space program,
     // or the service is not started yet
-    return XDP_PASS;
+    return 0;
   }
 
-  if (*bandwidth_limit_ptr == 0)
+  __u64 rate = limit->rate_bps / 8; // Bytes per second
+  if (rate == 0)
   {
     // If the service is stopped
-    return XDP_PASS;
+    return 0;
   }
 
-  __u64 current_timestamp = bpf_ktime_get_ns();
-  __u64 *last_time_window_start = bpf_map_lookup_elem(&last_packet_timestamp, &key);
-  if (!last_time_window_start)
+  if (!peer_matches(&bandwidth_peers, SERVICE_BANDWIDTH, pkt) || !protocol_matches(SERVICE_BANDWIDTH, pkt))
   {
-    bpf_map_update_elem(&last_packet_timestamp, &key, &current_timestamp, BPF_ANY);
-    return XDP_PASS;
+    return 0;
   }
 
-  __u64 packet_size = (__u64)(ctx->data_end - ctx->data);
+  __u64 now = bpf_ktime_get_ns();
+  struct token_bucket *bucket = bpf_map_lookup_elem(&token_bucket_map, &key);
+  if (!bucket)
+  {
+    // Start with a full bucket
+    struct token_bucket new_bucket = {
+        .tokens = limit->burst_bytes,
+        .last_refill_ns = now,
+    };
+    bpf_map_update_elem(&token_bucket_map, &key, &new_bucket, BPF_ANY);
+    bucket = bpf_map_lookup_elem(&token_bucket_map, &key);
+    if (!bucket)
+    {
+      stats_count(SERVICE_BANDWIDTH, direction, packet_size, STATS_DROPPED);
+      return 1;
+    }
+  }
 
-  __u64 *byte_count_ptr = bpf_map_lookup_elem(&byte_counter, &key);
-  if (!byte_count_ptr)
+  __u64 elapsed = now - bucket->last_refill_ns;
+  if (elapsed >= limit->burst_bytes * NANOS_PER_SEC / rate)
   {
-    bpf_map_update_elem(&byte_counter, &key, &packet_size, BPF_ANY);
+    // Enough time passed to fill the bucket up
+    bucket->tokens = limit->burst_bytes;
+    bucket->last_refill_ns = now;
   }
   else
   {
-    *byte_count_ptr += packet_size;
+    __u64 refill = elapsed * rate / NANOS_PER_SEC;
+    if (refill > 0)
+    {
+      bucket->tokens += refill;
+      if (bucket->tokens > limit->burst_bytes)
+        bucket->tokens = limit->burst_bytes;
+      // Only account for the time of the refilled tokens, so the
+      // fractions of a token are not lost between packets.
+      bucket->last_refill_ns += refill * NANOS_PER_SEC / rate;
+    }
   }
 
-  static __u64 time_window_ns = TIME_WINDOW_SEC * NANOS_PER_SEC;
-  if (current_timestamp - *last_time_window_start >= time_window_ns)
+  if (bucket->tokens < packet_size)
   {
-    // Reset byte counter and start of the next time window
-    __u64 reset_value = 0;
-    bpf_map_update_elem(&byte_counter, &key, &reset_value, BPF_ANY);
-    bpf_map_update_elem(&last_packet_timestamp, &key, &current_timestamp, BPF_ANY);
+    stats_count(SERVICE_BANDWIDTH, direction, packet_size, STATS_DROPPED);
+    return 1;
   }
 
-  // number of bytes per window
-  // divide by 8 to convert from bits to bytes
-  __u64 allowed_bytes = (*bandwidth_limit_ptr / 8 * TIME_WINDOW_SEC);
-
-  __u64 *accumulated_bytes = bpf_map_lookup_elem(&byte_counter, &key);
-  if (!accumulated_bytes)
-    return XDP_ABORTED;
+  bucket->tokens -= packet_size;
+  stats_count(SERVICE_BANDWIDTH, direction, packet_size, STATS_PASSED);
+  return 0;
+}
 
-  if (*accumulated_bytes > allowed_bytes)
+int xdp_bandwidth_limit(struct xdp_md *ctx, struct packet *pkt)
+{
+  __u64 packet_size = (__u64)(ctx->data_end - ctx->data);
+  if (bandwidth_limit_exceeded(DIRECTION_INGRESS, packet_size, pkt))
+  {
     return XDP_DROP;
-
+  }
   return XDP_PASS;
 }
+
+int tc_bandwidth_limit(struct __sk_buff *skb, struct packet *pkt)
+{
+  if (bandwidth_limit_exceeded(DIRECTION_EGRESS, skb->len, pkt))
+  {
+    return TC_ACT_SHOT;
+  }
+  return TC_ACT_OK;
+}
@@ -1,38 +1,125 @@
 // go:build ignore
 #include <linux/bpf.h>
+#include <linux/pkt_cls.h>
 #include <bpf/bpf_helpers.h>
 #include <string.h>
+#include "direction.h"
+#include "peers.h"
+#include "protocols.h"
+#include "chance.h"
+#include "stats.h"
 
-#define MAX_MAP_ENTRIES 1
 struct
 {
   __uint(type, BPF_MAP_TYPE_LRU_HASH);
   __type(key, __u32);
-  __type(value, __s32);
-  __uint(max_entries, MAX_MAP_ENTRIES);
+  __type(value, __u32); // Drop rate in parts per million
+  __uint(max_entries, MAX_DIRECTIONS);
 } packetloss_rate_map SEC(".maps");
 
-int xdp_packetloss(struct xdp_md *ctx)
+// Parameters of the Gilbert-Elliott loss model, in parts per million: the
+// link goes from the good to the bad state with probability p and back with
+// probability r, and drops the packets with probability loss_good in the
+// good state and loss_bad in the bad state.
+struct gilbert_elliott_params
 {
-  __u32 key = 0;
-  __s32 *drop_rate_ptr = bpf_map_lookup_elem(&packetloss_rate_map, &key);
-  if (!drop_rate_ptr)
+  __u32 enabled; // 1 to use the model instead of packetloss_rate_map
+  __u32 p;
+  __u32 r;
+  __u32 loss_good;
+  __u32 loss_bad;
+};
+
+#define GILBERT_ELLIOTT_GOOD 0
+#define GILBERT_ELLIOTT_BAD 1
+
+struct
+{
+  __uint(type, BPF_MAP_TYPE_ARRAY);
+  __type(key, __u32);
+  __type(value, struct gilbert_elliott_params);
+  __uint(max_entries, MAX_DIRECTIONS);
+} gilbert_elliott_params_map SEC(".maps");
+
+// The state of the model, kept per CPU so the packets processed on different
+// CPUs do not race on it.
+struct
+{
+  __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+  __type(key, __u32);
+  __type(value, __u32);
+  __uint(max_entries, MAX_DIRECTIONS);
+} gilbert_elliott_state_map SEC(".maps");
+
+static __always_inline int gilbert_elliott_drop(__u32 direction, struct gilbert_elliott_params *params)
+{
+  __u32 *state = bpf_map_lookup_elem(&gilbert_elliott_state_map, &direction);
+  if (!state)
+    return 0;
+
+  // Move to the next state, then drop with the loss probability of that state.
+  if (*state == GILBERT_ELLIOTT_GOOD)
+  {
+    if (chance_ppm(params->p))
+      *state = GILBERT_ELLIOTT_BAD;
+  }
+  else if (chance_ppm(params->r))
   {
-    // if it has not set by the user space program,
-    // or the service is not started yet
-    return XDP_PASS;
+    *state = GILBERT_ELLIOTT_GOOD;
   }
 
-  if (*drop_rate_ptr == 0)
+  return chance_ppm(*state == GILBERT_ELLIOTT_BAD ? params->loss_bad : params->loss_good);
+}
+
+// Returns 1 if the packet has to be dropped.
+int packetloss_drop(__u32 direction, __u64 bytes, struct packet *pkt)
+{
+  struct gilbert_elliott_params *ge = bpf_map_lookup_elem(&gilbert_elliott_params_map, &direction);
+  int use_ge = ge && ge->enabled;
+
+  __u32 drop_rate = 0;
+  if (!use_ge)
   {
-    // If the service is stopped
-    return XDP_PASS;
+    __u32 *drop_rate_ptr = bpf_map_lookup_elem(&packetloss_rate_map, &direction);
+    if (!drop_rate_ptr)
+    {
+      // if it has not set by the user space program,
+      // or the service is not started yet
+      return 0;
+    }
+
+    drop_rate = *drop_rate_ptr;
+    if (drop_rate == 0)
+    {
+      // If the service is stopped
+      return 0;
+    }
   }
 
-  if (bpf_get_prandom_u32() % 100 < *drop_rate_ptr)
+  if (!peer_matches(&packetloss_peers, SERVICE_PACKETLOSS, pkt) || !protocol_matches(SERVICE_PACKETLOSS, pkt))
   {
-    return XDP_DROP;
+    return 0;
   }
 
+  int drop = use_ge ? gilbert_elliott_drop(direction, ge) : chance_ppm(drop_rate);
+  stats_count(SERVICE_PACKETLOSS, direction, bytes, drop ? STATS_DROPPED : STATS_PASSED);
+  return drop;
+}
+
+int xdp_packetloss(struct xdp_md *ctx, struct packet *pkt)
+{
+  if (packetloss_drop(DIRECTION_INGRESS, (__u64)(ctx->data_end - ctx->data), pkt))
+  {
+    return XDP_DROP;
+  }
   return XDP_PASS;
-}
\ No newline at end of file
+}
+
+int tc_packetloss(struct __sk_buff *skb, struct packet *pkt)
+{
+  if (packetloss_drop(DIRECTION_EGRESS, skb->len, pkt))
+  {
+    return TC_ACT_SHOT;
+  }
+  return TC_ACT_OK;
+}
@@ -0,0 +1,44 @@
+// go:build ignore
+#include <linux/bpf.h>
+#include <linux/pkt_cls.h>
+#include <bpf/bpf_helpers.h>
+#include "peers.h"
+#include "stats.h"
+
+// Returns 1 if the packet comes from or goes to a peer of the partition. The
+// service has no rule of its own: it is enabled by the peer filter of
+// SERVICE_PARTITION, which is only set while it runs.
+int partition_drop(struct packet *pkt)
+{
+  __u32 service = SERVICE_PARTITION;
+  __u32 *enabled = bpf_map_lookup_elem(&peer_filter_map, &service);
+  if (!enabled || *enabled == 0)
+  {
+    // the service is stopped
+    return 0;
+  }
+
+  return peer_matches(&partition_peers, SERVICE_PARTITION, pkt);
+}
+
+// Drops all the packets sent by the peers of the partition.
+int xdp_partition(struct xdp_md *ctx, struct packet *pkt)
+{
+  if (partition_drop(pkt))
+  {
+    stats_count(SERVICE_PARTITION, DIRECTION_INGRESS, (__u64)(ctx->data_end - ctx->data), STATS_DROPPED);
+    return XDP_DROP;
+  }
+  return XDP_PASS;
+}
+
+// Drops all the packets sent to the peers of the partition.
+int tc_partition(struct __sk_buff *skb, struct packet *pkt)
+{
+  if (partition_drop(pkt))
+  {
+    stats_count(SERVICE_PARTITION, DIRECTION_EGRESS, skb->len, STATS_DROPPED);
+    return TC_ACT_SHOT;
+  }
+  return TC_ACT_OK;
+}
@@ -2,13 +2,35 @@ package latency
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/celestiaorg/bittwister/xdp/qdisc"
+	"github.com/cilium/ebpf"
+)
+
+const (
+	// EngineTc imposes latency and jitter with the tc netem qdisc.
+	EngineTc = "tc"
+	// EngineEBPF imposes latency and jitter with the egress TC-BPF program,
+	// which sets the departure time of the packets enforced by the fq qdisc.
+	EngineEBPF = "ebpf"
+)
+
+// Distributions of the jitter of netem, the jitter is uniform if none is
+// given. Any other distribution is the path of an empirical distribution
+// table, a .dist file as generated by the maketable tool of iproute2.
+const (
+	DistributionNormal       = "normal"
+	DistributionPareto       = "pareto"
+	DistributionParetoNormal = "paretonormal"
 )
 
 type Latency struct {
@@ -16,13 +38,102 @@ type Latency struct {
 	Latency          time.Duration
 	Jitter           time.Duration
 	TcBinPath        string // default: tc
+	Engine           string // default: EngineTc
+	// Distribution and Correlation shape the jitter, they require EngineTc.
+	Distribution string  // default: uniform
+	Correlation  float64 // Percent, of the jitter of a packet with the previous one
+	// Peers, Protocol and Ports scope the latency to some of the traffic,
+	// they require EngineEBPF.
+	Peers    []*net.IPNet  // default: all the traffic
+	Protocol xdp.Protocol  // default: all the protocols
+	Ports    xdp.PortRange // default: all the ports
+
+	// set while the service runs, x with EngineEBPF and tcX with EngineTc
+	x   *xdp.XdpObject
+	tcX *xdp.XdpObject
+}
+
+var (
+	_ xdp.XdpLoader   = (*Latency)(nil)
+	_ xdp.Updater     = (*Latency)(nil)
+	_ xdp.StatsReader = (*Latency)(nil)
+	_ xdp.Adopter     = (*Latency)(nil)
+)
+
+func (l *Latency) Validate() error {
+	if l.NetworkInterface == nil {
+		return xdp.ErrNetworkInterfaceNotSet
+	}
+	if l.Latency < 0 || l.Jitter < 0 {
+		return fmt.Errorf("latency and jitter must not be negative")
+	}
+	if l.Correlation < 0 || l.Correlation > 100 {
+		return fmt.Errorf("jitter correlation must be between 0 and 100")
+	}
+	if (l.Distribution != "" || l.Correlation > 0) && l.Jitter == 0 {
+		return fmt.Errorf("a jitter distribution or correlation requires a jitter")
+	}
+
+	switch l.Engine {
+	case "", EngineTc:
+		if len(l.Peers) > 0 || l.Protocol != xdp.ProtocolAny || !l.Ports.IsAny() {
+			return fmt.Errorf("latency peers, protocol and ports require the %q engine", EngineEBPF)
+		}
+		return l.validateDistribution()
+	case EngineEBPF:
+		if l.Distribution != "" || l.Correlation > 0 {
+			return fmt.Errorf("a jitter distribution or correlation requires the %q engine", EngineTc)
+		}
+		_, err := xdp.NewProtocolFilter(l.Protocol, l.Ports)
+		return err
+	default:
+		return fmt.Errorf("unknown latency engine %q", l.Engine)
+	}
 }
 
-var _ xdp.XdpLoader = (*Latency)(nil)
+// validateDistribution checks that the table of a custom distribution can be
+// read by tc.
+func (l *Latency) validateDistribution() error {
+	switch l.Distribution {
+	case "", DistributionNormal, DistributionPareto, DistributionParetoNormal:
+		return nil
+	}
+
+	if filepath.Ext(l.Distribution) != ".dist" {
+		return fmt.Errorf("unknown jitter distribution %q: not %s, %s, %s or the path of a .dist table",
+			l.Distribution, DistributionNormal, DistributionPareto, DistributionParetoNormal)
+	}
+	if _, err := os.Stat(l.Distribution); err != nil {
+		return fmt.Errorf("jitter distribution table: %w", err)
+	}
+	return nil
+}
+
+// setDefaults fills in the defaults of the unset parameters.
+func (l *Latency) setDefaults() {
+	if l.Engine == "" {
+		l.Engine = EngineTc
+	}
+}
 
-// Latency uses TC under the hood to impose latency and jitter on packets.
-// This is a temporary solution until we have a better way to do this; probably in XDP.
 func (l *Latency) Start() (xdp.CancelFunc, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	l.setDefaults()
+
+	if l.Engine == EngineEBPF {
+		return l.startEBPF()
+	}
+	return l.startTc()
+}
+
+// startTc uses TC under the hood to impose latency and jitter on packets:
+// the egress traffic is redirected to an ifb device whose root qdisc is
+// netem, so the qdiscs of the interface are left as they are. The index of
+// the ifb device is set in the map, so the TC program of the other services
+// lets the packets be redirected once they went through them.
+func (l *Latency) startTc() (xdp.CancelFunc, error) {
 	if l.TcBinPath == "" {
 		l.TcBinPath = "tc"
 	}
@@ -31,13 +142,32 @@ func (l *Latency) Start() (xdp.CancelFunc, error) {
 		return nil, fmt.Errorf("tc command not found")
 	}
 
-	if err := l.deleteTc(); err != nil {
+	if err := l.deleteLeftoverTc(); err != nil {
 		return nil, err
 	}
 
+	x, err := xdp.GetPreparedXdpObject(l.NetworkInterface.Index)
+	if err != nil {
+		return nil, fmt.Errorf("prepare XDP object: %w", err)
+	}
+
+	ifb, clsactAdded, err := qdisc.AddIfb(l.NetworkInterface.Index)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("redirect the egress traffic: %w", err), x.Close())
+	}
 	if err := l.addTc(); err != nil {
-		return nil, fmt.Errorf("set latency/jitter using tc: %w", err)
+		return nil, errors.Join(fmt.Errorf("set latency/jitter using tc: %w", err),
+			qdisc.DeleteIfb(l.NetworkInterface.Index, clsactAdded), x.Close())
+	}
+	if err := qdisc.TrackIfb(l.NetworkInterface.Index, "netem", clsactAdded); err != nil {
+		return nil, errors.Join(fmt.Errorf("record the netem qdisc: %w", err),
+			qdisc.DeleteIfb(l.NetworkInterface.Index, clsactAdded), x.Close())
 	}
+	key := uint32(0)
+	if err := x.BpfObjs.LatencyIfbMap.Update(key, uint32(ifb.Index), ebpf.UpdateAny); err != nil {
+		return nil, errors.Join(fmt.Errorf("update latency ifb device: %w", err), l.deleteTc(), x.Close())
+	}
+	l.tcX = x
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
@@ -45,9 +175,18 @@ func (l *Latency) Start() (xdp.CancelFunc, error) {
 	}()
 
 	cancelFunc := xdp.CancelFunc(func() error {
+		if err := x.BpfObjs.LatencyIfbMap.Update(key, uint32(0), ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("clear latency ifb device: %w", err)
+		}
+		l.tcX = nil
+
 		if err := l.deleteTc(); err != nil {
 			return err
 		}
+
+		if err := x.Close(); err != nil {
+			return fmt.Errorf("close XDP object: %w", err)
+		}
 		cancel()
 		return nil
 	})
@@ -55,37 +194,256 @@ func (l *Latency) Start() (xdp.CancelFunc, error) {
 	return cancelFunc, nil
 }
 
+// startEBPF imposes latency and jitter on the egress packets with the TC
+// program. The fq qdisc is installed as the root qdisc if the interface does
+// not use it yet, since it holds the packets until their departure time.
+func (l *Latency) startEBPF() (xdp.CancelFunc, error) {
+	x, err := xdp.GetPreparedXdpObject(l.NetworkInterface.Index)
+	if err != nil {
+		return nil, fmt.Errorf("prepare XDP object: %w", err)
+	}
+
+	if err := qdisc.AcquireFq(l.NetworkInterface.Index); err != nil {
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, err
+	}
+
+	key := uint32(0)
+	err = x.AttachTc()
+	if err == nil {
+		err = x.ResetStats(xdp.ServiceLatency)
+	}
+	if err == nil {
+		err = x.SetPeers(xdp.ServiceLatency, l.Peers)
+	}
+	if err == nil {
+		err = x.SetProtocolFilter(xdp.ServiceLatency, l.Protocol, l.Ports)
+	}
+	if err == nil {
+		err = x.BpfObjs.LatencyParamsMap.Update(key, l.mapValue(), ebpf.UpdateAny)
+	}
+	if err != nil {
+		if cErr := qdisc.ReleaseFq(l.NetworkInterface.Index); cErr != nil {
+			return nil, cErr
+		}
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, fmt.Errorf("update latency params: %w", err)
+	}
+
+	return l.runEBPF(x), nil
+}
+
+// Adopt takes over the latency a previous process left in the pinned maps
+// with EngineEBPF, with the fq qdisc it installed. The netem qdisc of
+// EngineTc is not adopted, see qdisc.DeleteLeftover.
+func (l *Latency) Adopt() (xdp.CancelFunc, bool, error) {
+	x, ok, err := xdp.AdoptObject(l.NetworkInterface.Index, l.readMaps)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	if err := qdisc.AcquireFq(l.NetworkInterface.Index); err != nil {
+		if cErr := x.Close(); cErr != nil {
+			return nil, false, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, false, err
+	}
+	return l.runEBPF(x), true, nil
+}
+
+// readMaps sets the parameters of the service from the maps, and reports
+// whether it runs with EngineEBPF. The ifb device of EngineTc is cleared, as
+// its netem qdisc is not adopted.
+func (l *Latency) readMaps(x *xdp.XdpObject) (bool, error) {
+	if err := x.BpfObjs.LatencyIfbMap.Update(uint32(0), uint32(0), ebpf.UpdateAny); err != nil {
+		return false, fmt.Errorf("clear latency ifb device: %w", err)
+	}
+
+	var params xdp.LatencyParams
+	if err := x.BpfObjs.LatencyParamsMap.Lookup(uint32(0), &params); err != nil {
+		return false, err
+	}
+	if params == (xdp.LatencyParams{}) {
+		return false, nil
+	}
+	l.Engine = EngineEBPF
+	l.Latency = time.Duration(params.LatencyNs)
+	l.Jitter = time.Duration(params.JitterNs)
+
+	var err error
+	if l.Peers, err = x.Peers(xdp.ServiceLatency); err != nil {
+		return false, err
+	}
+	if l.Protocol, l.Ports, err = x.ProtocolFilter(xdp.ServiceLatency); err != nil {
+		return false, err
+	}
+	return true, l.Validate()
+}
+
+// runEBPF marks the service as running on the XDP object, and returns the
+// function which stops it.
+func (l *Latency) runEBPF(x *xdp.XdpObject) xdp.CancelFunc {
+	key := uint32(0)
+	l.x = x
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+	}()
+
+	cancelFunc := xdp.CancelFunc(func() error {
+		// Update the map with zero values to disable the latency.
+		err := x.BpfObjs.LatencyParamsMap.Update(key, xdp.LatencyParams{}, ebpf.UpdateAny)
+		if err != nil {
+			return fmt.Errorf("update latency params to zero: %w", err)
+		}
+
+		l.x = nil
+
+		if err := x.SetPeers(xdp.ServiceLatency, nil); err != nil {
+			return fmt.Errorf("clear latency peers: %w", err)
+		}
+
+		if err := x.SetProtocolFilter(xdp.ServiceLatency, xdp.ProtocolAny, xdp.PortRange{}); err != nil {
+			return fmt.Errorf("clear latency protocol filter: %w", err)
+		}
+
+		if err := qdisc.ReleaseFq(l.NetworkInterface.Index); err != nil {
+			return err
+		}
+
+		if err := x.Close(); err != nil {
+			return fmt.Errorf("close XDP object: %w", err)
+		}
+		cancel()
+		return nil
+	})
+
+	return cancelFunc
+}
+
+// Update changes the latency and jitter of the running service: the netem
+// qdisc is changed in place with EngineTc, and the map is updated with
+// EngineEBPF.
+func (l *Latency) Update(next xdp.XdpLoader) error {
+	n, ok := next.(*Latency)
+	if !ok {
+		return fmt.Errorf("cannot update %T with %T", l, next)
+	}
+	if l.x == nil && l.tcX == nil {
+		return xdp.ErrServiceNotRunning
+	}
+
+	u := *l
+	u.Latency, u.Jitter = n.Latency, n.Jitter
+	u.Distribution, u.Correlation = n.Distribution, n.Correlation
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	if l.x != nil {
+		key := uint32(0)
+		if err := l.x.BpfObjs.LatencyParamsMap.Update(key, u.mapValue(), ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("update latency params: %w", err)
+		}
+	} else if err := u.changeTc(); err != nil {
+		return fmt.Errorf("set latency/jitter using tc: %w", err)
+	}
+
+	*l = u
+	return nil
+}
+
+func (l *Latency) mapValue() xdp.LatencyParams {
+	return xdp.LatencyParams{
+		LatencyNs: uint64(l.Latency.Nanoseconds()),
+		JitterNs:  uint64(l.Jitter.Nanoseconds()),
+	}
+}
+
 // Check if the tc command is installed.
 func (l *Latency) isTcInstalled() bool {
 	_, err := exec.LookPath(l.TcBinPath)
 	return err == nil
 }
 
+// deleteTc deletes the netem qdisc installed by the service with its ifb
+// device and the redirection of the egress traffic.
 func (l *Latency) deleteTc() error {
-	if !l.isThereTcNetEmRule() {
-		return nil
+	if err := qdisc.Release(l.NetworkInterface.Index, "netem"); err != nil {
+		return fmt.Errorf("delete tc rule: %w", err)
 	}
-	out, err := exec.Command(l.TcBinPath, "qdisc", "del", "dev", l.NetworkInterface.Name, "root").CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("delete tc rule: %w, output: `%s`", err, string(out))
+	return nil
+}
+
+// deleteLeftoverTc deletes the netem qdisc left behind by a bittwister
+// process which crashed, with its ifb device and the redirection of the
+// egress traffic. The qdiscs of the user are never deleted.
+func (l *Latency) deleteLeftoverTc() error {
+	if _, err := qdisc.DeleteLeftover(l.NetworkInterface.Index, "netem"); err != nil {
+		return fmt.Errorf("delete leftover tc rule: %w", err)
 	}
 	return nil
 }
 
 func (l *Latency) addTc() error {
-	latencyStr := fmt.Sprintf("%dms", l.Latency.Milliseconds())
-	jitterStr := fmt.Sprintf("%dms", l.Jitter.Milliseconds())
-	out, err := exec.Command(l.TcBinPath, "qdisc", "add", "dev", l.NetworkInterface.Name, "root", "netem", "delay", latencyStr, jitterStr).CombinedOutput()
+	return l.netemTc("add")
+}
+
+// changeTc changes the parameters of the netem qdisc, the packets it holds
+// are kept.
+func (l *Latency) changeTc() error {
+	return l.netemTc("change")
+}
+
+func (l *Latency) netemTc(action string) error {
+	cmd := exec.Command(l.TcBinPath, l.netemArgs(action)...)
+
+	// tc looks the tables up by name in its library directory, so a custom
+	// table is read from its own directory instead.
+	if l.Distribution != "" && filepath.Ext(l.Distribution) == ".dist" {
+		cmd.Env = append(os.Environ(), "TC_LIB_DIR="+filepath.Dir(l.Distribution))
+	}
+
+	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("add tc rule: %w, output: `%s`", err, string(out))
+		return fmt.Errorf("%s tc rule: %w, output: `%s`", action, err, string(out))
 	}
 	return nil
 }
 
-func (l *Latency) isThereTcNetEmRule() bool {
-	out, err := exec.Command(l.TcBinPath, "qdisc", "show", "dev", l.NetworkInterface.Name).CombinedOutput()
-	if err != nil {
-		return false
+func (l *Latency) netemArgs(action string) []string {
+	// netem keeps the delays in nanoseconds, but tc parses microseconds at most
+	args := []string{"qdisc", action, "dev", qdisc.IfbName(l.NetworkInterface.Index), "root", "netem", "delay",
+		fmt.Sprintf("%dus", l.Latency.Microseconds()),
+		fmt.Sprintf("%dus", l.Jitter.Microseconds()),
+	}
+	if l.Correlation > 0 {
+		args = append(args, fmt.Sprintf("%g%%", l.Correlation))
+	}
+
+	switch l.Distribution {
+	case "":
+	case DistributionNormal, DistributionPareto, DistributionParetoNormal:
+		args = append(args, "distribution", l.Distribution)
+	default:
+		args = append(args, "distribution", strings.TrimSuffix(filepath.Base(l.Distribution), ".dist"))
+	}
+	return args
+}
+
+// Stats returns the counters of the packets delayed since the service was
+// started, they are only kept with EngineEBPF.
+func (l *Latency) Stats() (xdp.ServiceStats, error) {
+	if l.x == nil {
+		if l.tcX != nil {
+			return xdp.ServiceStats{}, fmt.Errorf("%w with the %q engine", xdp.ErrStatsUnavailable, EngineTc)
+		}
+		return xdp.ServiceStats{}, xdp.ErrServiceNotRunning
 	}
-	return strings.Contains(string(out), "netem")
+	return l.x.Stats(xdp.ServiceLatency)
 }
@@ -0,0 +1,86 @@
+package latency
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDistribution(t *testing.T) {
+	table := filepath.Join(t.TempDir(), "experimental.dist")
+	require.NoError(t, os.WriteFile(table, []byte("0 1 2\n"), 0o600))
+
+	iface := &net.Interface{Index: 1, Name: "lo"}
+	tests := []struct {
+		name       string
+		latency    Latency
+		shouldFail bool
+	}{
+		{name: "uniform", latency: Latency{Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond}},
+		{name: "normal", latency: Latency{Jitter: 10 * time.Millisecond, Distribution: DistributionNormal, Correlation: 25}},
+		{name: "custom table", latency: Latency{Jitter: 10 * time.Millisecond, Distribution: table}},
+		{name: "missing table", latency: Latency{Jitter: 10 * time.Millisecond, Distribution: table + ".missing.dist"}, shouldFail: true},
+		{name: "unknown distribution", latency: Latency{Jitter: 10 * time.Millisecond, Distribution: "gaussian"}, shouldFail: true},
+		{name: "no jitter", latency: Latency{Latency: 100 * time.Millisecond, Distribution: DistributionPareto}, shouldFail: true},
+		{name: "correlation too high", latency: Latency{Jitter: 10 * time.Millisecond, Correlation: 101}, shouldFail: true},
+		{name: "ebpf engine", latency: Latency{Jitter: 10 * time.Millisecond, Correlation: 10, Engine: EngineEBPF}, shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.latency
+			l.NetworkInterface = iface
+			err := l.Validate()
+			if tt.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.latency.Engine, l.Engine, "Validate leaves the engine unset")
+		})
+	}
+}
+
+func TestNetemArgs(t *testing.T) {
+	iface := &net.Interface{Index: 1, Name: "eth0"}
+	tests := []struct {
+		name    string
+		latency Latency
+		want    []string
+	}{
+		{
+			name:    "uniform",
+			latency: Latency{Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond},
+			want:    []string{"100000us", "10000us"},
+		},
+		{
+			name:    "correlated pareto",
+			latency: Latency{Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond, Distribution: DistributionPareto, Correlation: 25.5},
+			want:    []string{"100000us", "10000us", "25.5%", "distribution", "pareto"},
+		},
+		{
+			name:    "sub-millisecond",
+			latency: Latency{Latency: 150 * time.Microsecond, Jitter: 20 * time.Microsecond},
+			want:    []string{"150us", "20us"},
+		},
+		{
+			name:    "custom table",
+			latency: Latency{Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond, Distribution: "/etc/bittwister/experimental.dist"},
+			want:    []string{"100000us", "10000us", "distribution", "experimental"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.latency
+			l.NetworkInterface = iface
+			want := append([]string{"qdisc", "add", "dev", "btifb1", "root", "netem", "delay"}, tt.want...)
+			assert.Equal(t, want, l.netemArgs("add"))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package packetloss
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/celestiaorg/bittwister/xdp"
+)
+
+const (
+	// ModelBernoulli drops each packet independently with PacketLossRate.
+	ModelBernoulli = "bernoulli"
+	// ModelGilbertElliott drops packets in bursts, following a two-state
+	// Markov chain, see GilbertElliott.
+	ModelGilbertElliott = "gilbert-elliott"
+)
+
+// GilbertElliott holds the parameters of the Gilbert-Elliott loss model, in
+// percent. The link goes from the good to the bad state with probability P
+// and back with probability R, and drops the packets with probability
+// LossGood in the good state and LossBad in the bad state. The Gilbert model
+// is the special case with LossGood = 0 and LossBad = 100.
+type GilbertElliott struct {
+	P        float64
+	R        float64
+	LossGood float64
+	LossBad  float64
+}
+
+func (g GilbertElliott) validate() error {
+	for name, v := range map[string]float64{
+		"p":         g.P,
+		"r":         g.R,
+		"loss good": g.LossGood,
+		"loss bad":  g.LossBad,
+	} {
+		if v < 0 || v > 100 || math.IsNaN(v) {
+			return fmt.Errorf("gilbert-elliott %s must be between 0 and 100, got %v", name, v)
+		}
+	}
+	return nil
+}
+
+// mapValue returns the value of the model in the gilbert_elliott_params_map
+// map, with the probabilities in parts per million.
+func (g GilbertElliott) mapValue() xdp.GilbertElliottParams {
+	return xdp.GilbertElliottParams{
+		Enabled:  1,
+		P:        xdp.PercentToPPM(g.P),
+		R:        xdp.PercentToPPM(g.R),
+		LossGood: xdp.PercentToPPM(g.LossGood),
+		LossBad:  xdp.PercentToPPM(g.LossBad),
+	}
+}
+
+// gilbertElliottFromMap returns the model of its value in the
+// gilbert_elliott_params_map map, see mapValue.
+func gilbertElliottFromMap(v xdp.GilbertElliottParams) GilbertElliott {
+	return GilbertElliott{
+		P:        xdp.PPMToPercent(v.P),
+		R:        xdp.PPMToPercent(v.R),
+		LossGood: xdp.PPMToPercent(v.LossGood),
+		LossBad:  xdp.PPMToPercent(v.LossBad),
+	}
+}
@@ -3,6 +3,7 @@ package packetloss
 import (
 	"context"
 	"fmt"
+	"math"
 	"net"
 
 	"github.com/celestiaorg/bittwister/xdp"
@@ -10,27 +11,207 @@ import (
 )
 
 type PacketLoss struct {
-	NetworkInterface *net.Interface
-	PacketLossRate   int32
+	NetworkInterface      *net.Interface
+	PacketLossRate        float64        // Percent, e.g. 0.1 for 0.1%
+	PacketLossRateIngress float64        // Percent on ingress, instead of PacketLossRate, see IsAsymmetric
+	PacketLossRateEgress  float64        // Percent on egress, instead of PacketLossRate, see IsAsymmetric
+	Model                 string         // default: ModelBernoulli
+	GilbertElliott        GilbertElliott // used by ModelGilbertElliott
+	Direction             xdp.Direction  // default: ingress
+	Peers                 []*net.IPNet   // default: all the traffic
+	Protocol              xdp.Protocol   // default: all the protocols
+	Ports                 xdp.PortRange  // default: all the ports
+
+	x *xdp.XdpObject // set while the service runs
+}
+
+var (
+	_ xdp.XdpLoader   = (*PacketLoss)(nil)
+	_ xdp.Updater     = (*PacketLoss)(nil)
+	_ xdp.StatsReader = (*PacketLoss)(nil)
+	_ xdp.Adopter     = (*PacketLoss)(nil)
+)
+
+// ValidateRate checks that the packet loss rate is a percentage. The kernel
+// drops packets with a precision of one part per million (0.0001%).
+func ValidateRate(rate float64) error {
+	if rate < 0 || rate > 100 || math.IsNaN(rate) {
+		return fmt.Errorf("packet loss rate must be between 0 and 100, got %v", rate)
+	}
+	if rate != 0 && xdp.PercentToPPM(rate) == 0 {
+		// it would read as a stopped service in the kernel
+		return fmt.Errorf("packet loss rate %v is below one part per million (0.0001%%)", rate)
+	}
+	return nil
+}
+
+func (p *PacketLoss) Validate() error {
+	if p.NetworkInterface == nil {
+		return xdp.ErrNetworkInterfaceNotSet
+	}
+	for _, rate := range []float64{p.PacketLossRate, p.PacketLossRateIngress, p.PacketLossRateEgress} {
+		if err := ValidateRate(rate); err != nil {
+			return err
+		}
+	}
+
+	if p.IsAsymmetric() {
+		if p.PacketLossRate != 0 {
+			return fmt.Errorf("packet loss rate cannot be combined with the ingress and egress rates")
+		}
+		if p.Model != "" && p.Model != ModelBernoulli {
+			return fmt.Errorf("the ingress and egress packet loss rates require the %q model", ModelBernoulli)
+		}
+		if _, err := xdp.AsymmetricDirection(p.Direction, p.PacketLossRateIngress != 0, p.PacketLossRateEgress != 0); err != nil {
+			return fmt.Errorf("packet loss rate: %w", err)
+		}
+	} else if p.Direction != "" {
+		if _, err := p.Direction.MapKeys(); err != nil {
+			return err
+		}
+	}
+
+	switch p.Model {
+	case "", ModelBernoulli:
+	case ModelGilbertElliott:
+		if err := p.GilbertElliott.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown loss model %q (expected %q or %q)", p.Model, ModelBernoulli, ModelGilbertElliott)
+	}
+
+	_, err := xdp.NewProtocolFilter(p.Protocol, p.Ports)
+	return err
 }
 
-var _ xdp.XdpLoader = (*PacketLoss)(nil)
+// setDefaults fills in the defaults of the unset parameters.
+func (p *PacketLoss) setDefaults() {
+	if p.Model == "" {
+		p.Model = ModelBernoulli
+	}
+	if p.Direction == "" {
+		// the directions which have a rate, ingress for a single rate
+		p.Direction, _ = xdp.AsymmetricDirection("", p.PacketLossRateIngress != 0, p.PacketLossRateEgress != 0)
+	}
+}
 
 func (p *PacketLoss) Start() (xdp.CancelFunc, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	p.setDefaults()
+
+	keys, err := p.Direction.MapKeys()
+	if err != nil {
+		return nil, err
+	}
+
 	x, err := xdp.GetPreparedXdpObject(p.NetworkInterface.Index)
 	if err != nil {
 		return nil, fmt.Errorf("prepare XDP object: %w", err)
 	}
 
-	key := uint32(0)
-	err = x.BpfObjs.PacketlossRateMap.Update(key, p.PacketLossRate, ebpf.UpdateAny)
+	if p.Direction.HasEgress() {
+		if err := x.AttachTc(); err != nil {
+			if cErr := x.Close(); cErr != nil {
+				return nil, fmt.Errorf("close XDP object: %w", cErr)
+			}
+			return nil, err
+		}
+	}
+
+	err = x.ResetStats(xdp.ServicePacketLoss)
+	if err == nil {
+		err = x.SetPeers(xdp.ServicePacketLoss, p.Peers)
+	}
+	if err == nil {
+		err = x.SetProtocolFilter(xdp.ServicePacketLoss, p.Protocol, p.Ports)
+	}
 	if err != nil {
 		if cErr := x.Close(); cErr != nil {
 			return nil, fmt.Errorf("close XDP object: %w", cErr)
 		}
-		return nil, fmt.Errorf("update packetloss drop rate: %v", err)
+		return nil, fmt.Errorf("set packetloss rules: %w", err)
+	}
+
+	if err := p.setModel(x, keys, true); err != nil {
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, err
+	}
+
+	return p.run(x, keys), nil
+}
+
+// Adopt takes over the packet loss a previous process left in the pinned
+// maps. The direction is derived from the directions with a loss rate or
+// model, and a service which drops nothing is not adopted.
+func (p *PacketLoss) Adopt() (xdp.CancelFunc, bool, error) {
+	x, ok, err := xdp.AdoptObject(p.NetworkInterface.Index, p.readMaps)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	// readMaps validated the direction
+	keys, _ := p.Direction.MapKeys()
+	return p.run(x, keys), true, nil
+}
+
+// readMaps sets the parameters of the service from the maps, and reports
+// whether it runs.
+func (p *PacketLoss) readMaps(x *xdp.XdpObject) (bool, error) {
+	rateIngress, rateEgress, err := xdp.LookupDirections[uint32](x.BpfObjs.PacketlossRateMap)
+	if err != nil {
+		return false, err
+	}
+	geIngress, geEgress, err := xdp.LookupDirections[xdp.GilbertElliottParams](x.BpfObjs.GilbertElliottParamsMap)
+	if err != nil {
+		return false, err
 	}
 
+	ingress := rateIngress != 0 || geIngress.Enabled != 0
+	egress := rateEgress != 0 || geEgress.Enabled != 0
+	if !ingress && !egress {
+		return false, nil
+	}
+	if p.Direction, err = xdp.AsymmetricDirection("", ingress, egress); err != nil {
+		return false, err
+	}
+
+	rate, ge := rateIngress, geIngress
+	if !ingress {
+		rate, ge = rateEgress, geEgress
+	}
+	switch {
+	case ge.Enabled != 0:
+		p.Model = ModelGilbertElliott
+		p.GilbertElliott = gilbertElliottFromMap(ge)
+		p.PacketLossRate = xdp.PPMToPercent(rate)
+	case ingress && egress && rateIngress != rateEgress:
+		p.Model = ModelBernoulli
+		p.PacketLossRateIngress = xdp.PPMToPercent(rateIngress)
+		p.PacketLossRateEgress = xdp.PPMToPercent(rateEgress)
+	default:
+		p.Model = ModelBernoulli
+		p.PacketLossRate = xdp.PPMToPercent(rate)
+	}
+
+	if p.Peers, err = x.Peers(xdp.ServicePacketLoss); err != nil {
+		return false, err
+	}
+	if p.Protocol, p.Ports, err = x.ProtocolFilter(xdp.ServicePacketLoss); err != nil {
+		return false, err
+	}
+	return true, p.Validate()
+}
+
+// run marks the service as running on the XDP object, and returns the
+// function which stops it.
+func (p *PacketLoss) run(x *xdp.XdpObject, keys []uint32) xdp.CancelFunc {
+	p.x = x
+
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		<-ctx.Done()
@@ -38,10 +219,25 @@ func (p *PacketLoss) Start() (xdp.CancelFunc, error) {
 
 	cancelFunc := xdp.CancelFunc(func() error {
 		// Update the map with a rate of 0 to disable the packetloss.
-		zero := int32(0)
-		err = x.BpfObjs.PacketlossRateMap.Update(key, zero, ebpf.UpdateAny)
-		if err != nil {
-			return fmt.Errorf("update packetloss drop rate to zero: %v", err)
+		zero := uint32(0)
+		for _, key := range keys {
+			err := x.BpfObjs.PacketlossRateMap.Update(key, zero, ebpf.UpdateAny)
+			if err != nil {
+				return fmt.Errorf("update packetloss drop rate to zero: %v", err)
+			}
+			err = x.BpfObjs.GilbertElliottParamsMap.Update(key, xdp.GilbertElliottParams{}, ebpf.UpdateAny)
+			if err != nil {
+				return fmt.Errorf("disable gilbert-elliott model: %w", err)
+			}
+		}
+
+		p.x = nil
+
+		if err := x.SetPeers(xdp.ServicePacketLoss, nil); err != nil {
+			return fmt.Errorf("clear packetloss peers: %w", err)
+		}
+		if err := x.SetProtocolFilter(xdp.ServicePacketLoss, xdp.ProtocolAny, xdp.PortRange{}); err != nil {
+			return fmt.Errorf("clear packetloss protocol filter: %w", err)
 		}
 
 		if err := x.Close(); err != nil {
@@ -51,5 +247,101 @@ func (p *PacketLoss) Start() (xdp.CancelFunc, error) {
 		return nil
 	})
 
-	return cancelFunc, nil
+	return cancelFunc
+}
+
+// Update changes the loss rates and model of the running service. The
+// direction is kept, so the new rates must fit it.
+func (p *PacketLoss) Update(next xdp.XdpLoader) error {
+	n, ok := next.(*PacketLoss)
+	if !ok {
+		return fmt.Errorf("cannot update %T with %T", p, next)
+	}
+	if p.x == nil {
+		return xdp.ErrServiceNotRunning
+	}
+
+	u := *p
+	u.PacketLossRate, u.Model, u.GilbertElliott = n.PacketLossRate, n.Model, n.GilbertElliott
+	u.PacketLossRateIngress, u.PacketLossRateEgress = n.PacketLossRateIngress, n.PacketLossRateEgress
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	u.setDefaults()
+
+	keys, err := u.Direction.MapKeys()
+	if err != nil {
+		return err
+	}
+	if err := u.setModel(p.x, keys, u.Model != p.Model); err != nil {
+		return err
+	}
+
+	*p = u
+	return nil
+}
+
+// setModel writes the loss rate and model to the maps of the given
+// directions. Each direction switches to the new values at once, since the
+// model is enabled by its own map entry. resetState starts the model over in
+// the good state on every CPU.
+func (p *PacketLoss) setModel(x *xdp.XdpObject, keys []uint32, resetState bool) error {
+	var geParams xdp.GilbertElliottParams
+	if p.Model == ModelGilbertElliott {
+		geParams = p.GilbertElliott.mapValue()
+	}
+
+	var goodStates []uint32
+	if resetState {
+		cpus, err := ebpf.PossibleCPU()
+		if err != nil {
+			return fmt.Errorf("get possible CPUs: %w", err)
+		}
+		goodStates = make([]uint32, cpus)
+	}
+
+	for _, key := range keys {
+		if resetState {
+			if err := x.BpfObjs.GilbertElliottStateMap.Update(key, goodStates, ebpf.UpdateAny); err != nil {
+				return fmt.Errorf("reset gilbert-elliott state: %w", err)
+			}
+		}
+
+		err := x.BpfObjs.PacketlossRateMap.Update(key, xdp.PercentToPPM(p.rate(key)), ebpf.UpdateAny)
+		if err == nil {
+			err = x.BpfObjs.GilbertElliottParamsMap.Update(key, geParams, ebpf.UpdateAny)
+		}
+		if err != nil {
+			return fmt.Errorf("update packetloss drop rate: %v", err)
+		}
+	}
+	return nil
+}
+
+// IsAsymmetric reports whether the service has its own loss rate for each
+// direction: PacketLossRateIngress and PacketLossRateEgress are used instead
+// of PacketLossRate, with the bernoulli model only. The direction defaults to
+// the ones they are set for.
+func (p *PacketLoss) IsAsymmetric() bool {
+	return p.PacketLossRateIngress != 0 || p.PacketLossRateEgress != 0
+}
+
+// rate returns the loss rate of the direction of the map key.
+func (p *PacketLoss) rate(key uint32) float64 {
+	if !p.IsAsymmetric() {
+		return p.PacketLossRate
+	}
+	if xdp.IsIngressKey(key) {
+		return p.PacketLossRateIngress
+	}
+	return p.PacketLossRateEgress
+}
+
+// Stats returns the counters of the packets the service applied to since it
+// was started.
+func (p *PacketLoss) Stats() (xdp.ServiceStats, error) {
+	if p.x == nil {
+		return xdp.ServiceStats{}, xdp.ErrServiceNotRunning
+	}
+	return p.x.Stats(xdp.ServicePacketLoss)
 }
@@ -0,0 +1,122 @@
+package partition
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/celestiaorg/bittwister/xdp"
+)
+
+// Partition cuts the network interface off from some peers: all the packets
+// sent by the peers are dropped on ingress and all the packets sent to them
+// on egress, while the traffic of the other peers is left intact.
+type Partition struct {
+	NetworkInterface *net.Interface
+	Peers            []*net.IPNet // required
+
+	x *xdp.XdpObject // set while the service runs
+}
+
+var (
+	_ xdp.XdpLoader   = (*Partition)(nil)
+	_ xdp.StatsReader = (*Partition)(nil)
+	_ xdp.Adopter     = (*Partition)(nil)
+)
+
+func (p *Partition) Validate() error {
+	if p.NetworkInterface == nil {
+		return xdp.ErrNetworkInterfaceNotSet
+	}
+	if len(p.Peers) == 0 {
+		return fmt.Errorf("partition peers must be given")
+	}
+	return nil
+}
+
+func (p *Partition) Start() (xdp.CancelFunc, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	x, err := xdp.GetPreparedXdpObject(p.NetworkInterface.Index)
+	if err != nil {
+		return nil, fmt.Errorf("prepare XDP object: %w", err)
+	}
+
+	// The peers enable the service, see kerns/xdp_partition.c
+	err = x.AttachTc()
+	if err == nil {
+		err = x.ResetStats(xdp.ServicePartition)
+	}
+	if err == nil {
+		err = x.SetPeers(xdp.ServicePartition, p.Peers)
+	}
+	if err != nil {
+		if cErr := x.SetPeers(xdp.ServicePartition, nil); cErr != nil {
+			return nil, fmt.Errorf("clear partition peers: %w", cErr)
+		}
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, fmt.Errorf("set partition peers: %w", err)
+	}
+
+	return p.run(x), nil
+}
+
+// Adopt takes over the partition a previous process left in the pinned
+// maps.
+func (p *Partition) Adopt() (xdp.CancelFunc, bool, error) {
+	x, ok, err := xdp.AdoptObject(p.NetworkInterface.Index, p.readMaps)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return p.run(x), true, nil
+}
+
+// readMaps sets the peers of the service from the maps, and reports
+// whether it runs.
+func (p *Partition) readMaps(x *xdp.XdpObject) (bool, error) {
+	var err error
+	if p.Peers, err = x.Peers(xdp.ServicePartition); err != nil {
+		return false, err
+	}
+	return len(p.Peers) > 0, nil
+}
+
+// run marks the service as running on the XDP object, and returns the
+// function which stops it.
+func (p *Partition) run(x *xdp.XdpObject) xdp.CancelFunc {
+	p.x = x
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+	}()
+
+	cancelFunc := xdp.CancelFunc(func() error {
+		p.x = nil
+
+		if err := x.SetPeers(xdp.ServicePartition, nil); err != nil {
+			return fmt.Errorf("clear partition peers: %w", err)
+		}
+
+		if err := x.Close(); err != nil {
+			return fmt.Errorf("close XDP object: %w", err)
+		}
+		cancel()
+		return nil
+	})
+
+	return cancelFunc
+}
+
+// Stats returns the counters of the packets the service applied to since it
+// was started.
+func (p *Partition) Stats() (xdp.ServiceStats, error) {
+	if p.x == nil {
+		return xdp.ServiceStats{}, xdp.ErrServiceNotRunning
+	}
+	return p.x.Stats(xdp.ServicePartition)
+}
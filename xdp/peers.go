@@ -0,0 +1,182 @@
+package xdp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// Service identifies a service in the maps shared by all the services,
+// see kerns/peers.h
+type Service uint32
+
+const (
+	ServicePacketLoss Service = 0
+	ServiceBandwidth  Service = 1
+	ServiceLatency    Service = 2
+	ServiceDuplicate  Service = 3
+	ServiceReorder    Service = 4
+	ServiceCorrupt    Service = 5
+	ServicePartition  Service = 6
+)
+
+// MaxPeers is the maximum number of peers of a service, see kerns/peers.h
+const MaxPeers = 1024
+
+// PeerKey is the key of the peer LPM tries.
+type PeerKey = bpfPeerKey
+
+// ParsePeers parses a list of peers given in CIDR notation, e.g. 10.0.0.0/24
+// or fd00::/64. A plain IP address matches that address only.
+func ParsePeers(peers []string) ([]*net.IPNet, error) {
+	if len(peers) > MaxPeers {
+		return nil, fmt.Errorf("too many peers: %d (max %d)", len(peers), MaxPeers)
+	}
+
+	nets := make([]*net.IPNet, 0, len(peers))
+	for _, p := range peers {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid peer %q: not an IP address or a CIDR", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer %q: %w", p, err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// NewPeerKey returns the key of the network in the peer LPM tries. IPv4
+// networks are stored as IPv4-mapped IPv6 networks.
+func NewPeerKey(n *net.IPNet) PeerKey {
+	ones, bits := n.Mask.Size()
+	if bits == 8*net.IPv4len {
+		ones += 8 * (net.IPv6len - net.IPv4len)
+	}
+
+	var key PeerKey
+	key.Prefixlen = uint32(ones)
+	copy(key.Addr[:], n.IP.To16())
+	return key
+}
+
+// IPNet returns the network of the key, see NewPeerKey.
+func (k PeerKey) IPNet() *net.IPNet {
+	ip := net.IP(k.Addr[:])
+	ones := int(k.Prefixlen)
+	if ip4 := ip.To4(); ip4 != nil && ones >= 8*(net.IPv6len-net.IPv4len) {
+		ones -= 8 * (net.IPv6len - net.IPv4len)
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones, 8*net.IPv4len)}
+	}
+	return &net.IPNet{IP: append(net.IP(nil), ip...), Mask: net.CIDRMask(ones, 8*net.IPv6len)}
+}
+
+// SetPeers scopes the service to the given peers: the source addresses of
+// the ingress packets and the destination addresses of the egress packets.
+// The service applies to all the traffic if no peers are given.
+func (x *XdpObject) SetPeers(s Service, peers []*net.IPNet) error {
+	m, err := x.peersMap(s)
+	if err != nil {
+		return err
+	}
+
+	if err := clearMap(m); err != nil {
+		return fmt.Errorf("clear peers: %w", err)
+	}
+
+	for _, p := range peers {
+		if err := m.Update(NewPeerKey(p), uint8(1), ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("add peer %s: %w", p, err)
+		}
+	}
+
+	enabled := uint32(0)
+	if len(peers) > 0 {
+		enabled = 1
+	}
+	if err := x.BpfObjs.PeerFilterMap.Update(uint32(s), enabled, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("update peer filter: %w", err)
+	}
+
+	return nil
+}
+
+// Peers returns the peers the service is scoped to, see SetPeers.
+func (x *XdpObject) Peers(s Service) ([]*net.IPNet, error) {
+	var enabled uint32
+	if err := x.BpfObjs.PeerFilterMap.Lookup(uint32(s), &enabled); err != nil {
+		return nil, fmt.Errorf("lookup peer filter: %w", err)
+	}
+	if enabled == 0 {
+		return nil, nil
+	}
+
+	m, err := x.peersMap(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		key   PeerKey
+		peers []*net.IPNet
+	)
+	for err := m.NextKey(nil, &key); err == nil; err = m.NextKey(key, &key) {
+		peers = append(peers, key.IPNet())
+	}
+	return peers, nil
+}
+
+func (x *XdpObject) peersMap(s Service) (*ebpf.Map, error) {
+	switch s {
+	case ServicePacketLoss:
+		return x.BpfObjs.PacketlossPeers, nil
+	case ServiceBandwidth:
+		return x.BpfObjs.BandwidthPeers, nil
+	case ServiceLatency:
+		return x.BpfObjs.LatencyPeers, nil
+	case ServiceDuplicate:
+		return x.BpfObjs.DuplicatePeers, nil
+	case ServiceReorder:
+		return x.BpfObjs.ReorderPeers, nil
+	case ServiceCorrupt:
+		return x.BpfObjs.CorruptPeers, nil
+	case ServicePartition:
+		return x.BpfObjs.PartitionPeers, nil
+	default:
+		return nil, fmt.Errorf("unknown service %d", s)
+	}
+}
+
+func clearMap(m *ebpf.Map) error {
+	var (
+		key  PeerKey
+		keys []PeerKey
+	)
+	for err := m.NextKey(nil, &key); err == nil; err = m.NextKey(key, &key) {
+		keys = append(keys, key)
+	}
+
+	for _, k := range keys {
+		if err := m.Delete(k); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+	}
+	return nil
+}
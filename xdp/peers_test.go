@@ -0,0 +1,42 @@
+package xdp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePeers(t *testing.T) {
+	tests := []struct {
+		peer       string
+		prefixlen  uint32
+		addr       net.IP
+		shouldFail bool
+	}{
+		{peer: "10.0.0.0/24", prefixlen: 96 + 24, addr: net.ParseIP("10.0.0.0")},
+		{peer: "10.0.0.1", prefixlen: 128, addr: net.ParseIP("10.0.0.1")},
+		{peer: "fd00::/64", prefixlen: 64, addr: net.ParseIP("fd00::")},
+		{peer: "fd00::1", prefixlen: 128, addr: net.ParseIP("fd00::1")},
+		{peer: "10.0.0.0/33", shouldFail: true},
+		{peer: "validator-0", shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.peer, func(t *testing.T) {
+			nets, err := ParsePeers([]string{tt.peer})
+			if tt.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, nets, 1)
+
+			key := NewPeerKey(nets[0])
+			assert.Equal(t, tt.prefixlen, key.Prefixlen)
+			assert.Equal(t, []byte(tt.addr.To16()), key.Addr[:])
+			assert.Equal(t, nets[0].String(), key.IPNet().String())
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package xdp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultPinPath is the usual directory to pin the objects in, on the bpffs
+// mounted at /sys/fs/bpf.
+const DefaultPinPath = "/sys/fs/bpf/bittwister"
+
+// PinPath is the bpffs directory the programs, links and maps are pinned in,
+// one directory per network interface index. The pinned links keep the
+// programs attached after the process exits, and the pinned maps keep the
+// parameters of the services, so the next process adopts them, see Adopter.
+// Nothing is pinned if it is empty.
+var PinPath string
+
+// Names of the pinned programs and links, the maps are pinned by their name.
+const (
+	pinXdpLink = "xdp_link"
+	pinTcLink  = "tc_link"
+	pinXdpMain = "xdp_main"
+	pinTcMain  = "tc_main"
+)
+
+// ErrPinsIncompatible is returned when the maps pinned by a previous process
+// do not match the maps of this version, e.g. after an upgrade changed one of
+// them. Its services cannot be adopted, and the pins have to be removed with
+// RemoveInterfacePins before the network interface is used again.
+var ErrPinsIncompatible = errors.New("pinned maps are incompatible with this version")
+
+// Adopter is implemented by the services which can take over the
+// impairments a previous process left in the pinned maps.
+type Adopter interface {
+	// Adopt reads the parameters of the service on its network interface
+	// from the maps, and reports false if the service does not run there.
+	// Otherwise the service runs as if Start was called with them.
+	Adopt() (CancelFunc, bool, error)
+}
+
+// AdoptObject returns the XDP object of the network interface if read,
+// which sets the parameters of a service from the maps, reports that the
+// service runs there. The TC program is attached already if a previous
+// process attached it.
+func AdoptObject(netInterfaceIndex int, read func(*XdpObject) (bool, error)) (*XdpObject, bool, error) {
+	x, err := GetPreparedXdpObject(netInterfaceIndex)
+	if err != nil {
+		return nil, false, fmt.Errorf("prepare XDP object: %w", err)
+	}
+
+	running, err := read(x)
+	if err != nil || !running {
+		if cErr := x.Close(); cErr != nil {
+			return nil, false, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, false, err
+	}
+	return x, true, nil
+}
+
+// pinLock is held while the process uses the pinned objects, see PinsInUse.
+var pinLock *os.File
+
+// PinnedInterfaces returns the indexes of the network interfaces with
+// objects pinned in PinPath.
+func PinnedInterfaces() ([]int, error) {
+	if PinPath == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(PinPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read pinned objects: %w", err)
+	}
+
+	var indexes []int
+	for _, e := range entries {
+		if i, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// PinsInUse reports whether a running process uses the objects pinned in
+// the directory.
+func PinsInUse(dir string) (bool, error) {
+	f, err := os.Open(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return true, nil
+	}
+	return false, err
+}
+
+// RemovePins removes the objects pinned in the directory, which detaches
+// the programs and disables all the services left running there.
+func RemovePins(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// RemoveInterfacePins removes the objects pinned for the network interface,
+// see RemovePins.
+func RemoveInterfacePins(netInterfaceIndex int) error {
+	return RemovePins(pinDir(netInterfaceIndex))
+}
+
+func pinDir(netInterfaceIndex int) string {
+	return filepath.Join(PinPath, strconv.Itoa(netInterfaceIndex))
+}
+
+// loadObjects loads the programs and the maps into the kernel. The maps
+// pinned by a previous process are reused, with the values they hold.
+func (x *XdpObject) loadObjects() error {
+	if PinPath == "" {
+		return loadBpfObjects(&x.BpfObjs, nil)
+	}
+
+	dir := pinDir(x.netInterfaceIndex)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create pin directory: %w", err)
+	}
+	if err := lockPins(); err != nil {
+		return err
+	}
+
+	spec, err := loadBpf()
+	if err != nil {
+		return err
+	}
+	for _, m := range spec.Maps {
+		m.Pinning = ebpf.PinByName
+	}
+
+	err = spec.LoadAndAssign(&x.BpfObjs, &ebpf.CollectionOptions{
+		Maps: ebpf.MapOptions{PinPath: dir},
+	})
+	if errors.Is(err, ebpf.ErrMapIncompatible) {
+		return fmt.Errorf("%w: %w", ErrPinsIncompatible, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	for name, p := range map[string]*ebpf.Program{pinXdpMain: x.BpfObjs.XdpMain, pinTcMain: x.BpfObjs.TcMain} {
+		// the program of the previous process is replaced
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Join(fmt.Errorf("unpin program: %w", err), x.BpfObjs.Close())
+		}
+		if err := p.Pin(path); err != nil {
+			return errors.Join(fmt.Errorf("pin program: %w", err), x.BpfObjs.Close())
+		}
+	}
+	return nil
+}
+
+// lockPins takes a shared lock on PinPath until the process exits.
+func lockPins() error {
+	if pinLock != nil {
+		return nil
+	}
+
+	f, err := os.Open(PinPath)
+	if err != nil {
+		return fmt.Errorf("open pin directory: %w", err)
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_SH); err != nil {
+		f.Close()
+		return fmt.Errorf("lock pin directory: %w", err)
+	}
+	pinLock = f
+	return nil
+}
+
+// attach attaches the program with attachFn. If the link is pinned, the
+// link of the previous process is updated to the program instead, so it is
+// replaced atomically.
+func (x *XdpObject) attach(name string, prog *ebpf.Program, attachFn func() (link.Link, error)) (link.Link, error) {
+	if PinPath == "" {
+		return attachFn()
+	}
+
+	path := filepath.Join(pinDir(x.netInterfaceIndex), name)
+	l, err := link.LoadPinnedLink(path, nil)
+	if err == nil {
+		if err := l.Update(prog); err != nil {
+			return nil, errors.Join(fmt.Errorf("update pinned link: %w", err), l.Close())
+		}
+		return l, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load pinned link: %w", err)
+	}
+
+	l, err = attachFn()
+	if err != nil {
+		return nil, err
+	}
+	if err := l.Pin(path); err != nil {
+		return nil, errors.Join(fmt.Errorf("pin link: %w", err), l.Close())
+	}
+	return l, nil
+}
+
+// isPinned reports whether a previous process pinned the object.
+func (x *XdpObject) isPinned(name string) bool {
+	if PinPath == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(pinDir(x.netInterfaceIndex), name))
+	return err == nil
+}
+
+// unpin removes the pinned objects, so the programs are detached when the
+// object is closed.
+func (x *XdpObject) unpin() error {
+	if PinPath == "" {
+		return nil
+	}
+	return RemovePins(pinDir(x.netInterfaceIndex))
+}
@@ -0,0 +1,112 @@
+package xdp
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bpffsDir returns a temporary directory on a mounted bpffs.
+func bpffsDir(t *testing.T) string {
+	f, err := os.Open("/proc/mounts")
+	require.NoError(t, err)
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 || fields[2] != "bpf" {
+			continue
+		}
+		dir, err := os.MkdirTemp(fields[1], "bittwister-test-")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+	t.Skip("no bpffs mounted")
+	return ""
+}
+
+func TestPinnedObjectAdoption(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("loading BPF programs requires root privileges")
+	}
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("loopback interface not found")
+	}
+
+	prev := PinPath
+	PinPath = bpffsDir(t)
+	t.Cleanup(func() { PinPath = prev })
+
+	x, err := GetPreparedXdpObject(lo.Index)
+	require.NoError(t, err)
+	require.NoError(t, x.AttachTc())
+	require.NoError(t, x.BpfObjs.DuplicateRateMap.Update(uint32(0), uint32(1234), ebpf.UpdateAny))
+
+	// the process exits without closing the object
+	xdpObjectsMu.Lock()
+	delete(xdpObjects, lo.Index)
+	xdpObjectsMu.Unlock()
+	require.NoError(t, x.Link.Close())
+	require.NoError(t, x.TcLink.Close())
+	require.NoError(t, x.BpfObjs.Close())
+
+	indexes, err := PinnedInterfaces()
+	require.NoError(t, err)
+	assert.Equal(t, []int{lo.Index}, indexes)
+
+	x, err = GetPreparedXdpObject(lo.Index)
+	require.NoError(t, err)
+	assert.NotNil(t, x.TcLink, "the pinned TC link is adopted")
+
+	var rate uint32
+	require.NoError(t, x.BpfObjs.DuplicateRateMap.Lookup(uint32(0), &rate))
+	assert.Equal(t, uint32(1234), rate, "the pinned maps are reused")
+	require.NoError(t, x.BpfObjs.DuplicateRateMap.Update(uint32(0), uint32(0), ebpf.UpdateAny))
+
+	require.NoError(t, x.Close())
+	indexes, err = PinnedInterfaces()
+	require.NoError(t, err)
+	assert.Empty(t, indexes, "the last service removes the pins")
+}
+
+func TestIncompatiblePins(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("loading BPF programs requires root privileges")
+	}
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("loopback interface not found")
+	}
+
+	prev := PinPath
+	PinPath = bpffsDir(t)
+	t.Cleanup(func() { PinPath = prev })
+
+	// a previous version pinned the map with another value size
+	spec, err := loadBpf()
+	require.NoError(t, err)
+	ms := spec.Maps["duplicate_rate_map"].Copy()
+	ms.ValueSize *= 2
+	ms.Pinning = ebpf.PinByName
+	require.NoError(t, os.MkdirAll(pinDir(lo.Index), 0o700))
+	m, err := ebpf.NewMapWithOptions(ms, ebpf.MapOptions{PinPath: pinDir(lo.Index)})
+	require.NoError(t, err)
+	require.NoError(t, m.Close())
+
+	_, err = GetPreparedXdpObject(lo.Index)
+	require.ErrorIs(t, err, ErrPinsIncompatible)
+
+	require.NoError(t, RemoveInterfacePins(lo.Index))
+	x, err := GetPreparedXdpObject(lo.Index)
+	require.NoError(t, err)
+	require.NoError(t, x.Close())
+}
@@ -0,0 +1,140 @@
+package xdp
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// skBuff is struct __sk_buff, the context of a TC program run with
+// ebpf.Program.Run.
+type skBuff struct {
+	Len, PktType, Mark, QueueMapping, Protocol, VlanPresent, VlanTci, VlanProto uint32
+	Priority, IngressIfindex, Ifindex, TcIndex                                  uint32
+	Cb                                                                          [5]uint32
+	Hash, TcClassid, Data, DataEnd, NapiID, Family, RemoteIP4, LocalIP4         uint32
+	RemoteIP6, LocalIP6                                                         [4]uint32
+	RemotePort, LocalPort, DataMeta                                             uint32
+	FlowKeys                                                                    uint64
+	Tstamp                                                                      uint64
+	WireLen, GsoSegs                                                            uint32
+	Sk                                                                          uint64
+	GsoSize                                                                     uint32
+	TstampType                                                                  uint8
+	_                                                                           [3]byte
+	Hwtstamp                                                                    uint64
+}
+
+// Verdicts of the TC program, see linux/pkt_cls.h
+const (
+	tcActUnspec = ^uint32(0)
+	tcActOk     = 0
+	tcActShot   = 2
+)
+
+// loadTestObjects loads the programs and maps, without attaching them.
+func loadTestObjects(t *testing.T) *bpfObjects {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("loading BPF programs requires root privileges")
+	}
+
+	var objs bpfObjects
+	require.NoError(t, loadBpfObjects(&objs, nil))
+	t.Cleanup(func() { objs.Close() })
+	return &objs
+}
+
+// udpPacket returns an Ethernet frame holding an IPv4 UDP packet from
+// 10.0.0.1:1000 to 10.0.0.2:2000.
+func udpPacket() []byte {
+	pkt := make([]byte, 14+20+8+32)
+	binary.BigEndian.PutUint16(pkt[12:14], unix.ETH_P_IP)
+
+	ip := pkt[14:34]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(pkt)-14))
+	ip[8] = 64
+	ip[9] = unix.IPPROTO_UDP
+	copy(ip[12:16], []byte{10, 0, 0, 1})
+	copy(ip[16:20], []byte{10, 0, 0, 2})
+
+	udp := pkt[34:42]
+	binary.BigEndian.PutUint16(udp[0:2], 1000)
+	binary.BigEndian.PutUint16(udp[2:4], 2000)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(len(pkt)-34))
+	return pkt
+}
+
+// runTc runs the TC program on the packet, and returns its verdict and the
+// context it leaves.
+func runTc(t *testing.T, objs *bpfObjects, in skBuff) (uint32, skBuff) {
+	t.Helper()
+
+	var out skBuff
+	ret, err := objs.TcMain.Run(&ebpf.RunOptions{
+		Data:       udpPacket(),
+		Context:    in,
+		ContextOut: &out,
+	})
+	require.NoError(t, err)
+	return ret, out
+}
+
+func monotonicNow(t *testing.T) uint64 {
+	var ts unix.Timespec
+	require.NoError(t, unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts))
+	return uint64(ts.Nano())
+}
+
+func TestTcLatencyJitter(t *testing.T) {
+	objs := loadTestObjects(t)
+
+	const latency, jitter = 10 * time.Second, 3 * time.Second
+	params := LatencyParams{LatencyNs: uint64(latency), JitterNs: uint64(jitter)}
+	require.NoError(t, objs.LatencyParamsMap.Update(uint32(0), params, ebpf.UpdateAny))
+
+	var lowest, highest time.Duration = latency, latency
+	for i := 0; i < 200; i++ {
+		before := monotonicNow(t)
+		_, out := runTc(t, objs, skBuff{})
+		delay := time.Duration(out.Tstamp - before)
+		lowest, highest = min(lowest, delay), max(highest, delay)
+	}
+
+	// the delays cover [latency - jitter, latency + jitter], even though
+	// twice the jitter is beyond the 2^32 ns of a 32-bit random value
+	assert.GreaterOrEqual(t, lowest, latency-jitter)
+	assert.Less(t, lowest, latency-jitter/2)
+	assert.Greater(t, highest, latency+jitter/2)
+	assert.LessOrEqual(t, highest, latency+jitter+time.Second)
+}
+
+func TestTcLatencyIfb(t *testing.T) {
+	objs := loadTestObjects(t)
+
+	// the egress packets are all dropped
+	require.NoError(t, objs.PacketlossRateMap.Update(directionKeyEgress, PercentToPPM(100), ebpf.UpdateAny))
+	verdict, _ := runTc(t, objs, skBuff{})
+	assert.EqualValues(t, tcActShot, verdict)
+
+	// with the tc latency engine, the packets which pass the services are
+	// left to the filter redirecting them to the ifb device, and the ones it
+	// sends back do not go through the services again
+	const ifbIndex = 42
+	require.NoError(t, objs.LatencyIfbMap.Update(uint32(0), uint32(ifbIndex), ebpf.UpdateAny))
+	verdict, _ = runTc(t, objs, skBuff{IngressIfindex: ifbIndex})
+	assert.EqualValues(t, tcActOk, verdict)
+	verdict, _ = runTc(t, objs, skBuff{})
+	assert.EqualValues(t, tcActShot, verdict)
+
+	require.NoError(t, objs.PacketlossRateMap.Update(directionKeyEgress, uint32(0), ebpf.UpdateAny))
+	verdict, _ = runTc(t, objs, skBuff{})
+	assert.Equal(t, tcActUnspec, verdict)
+}
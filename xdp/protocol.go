@@ -0,0 +1,136 @@
+package xdp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+// Protocol selects the transport protocol a service applies to.
+type Protocol string
+
+const (
+	ProtocolAny  Protocol = ""
+	ProtocolTCP  Protocol = "tcp"
+	ProtocolUDP  Protocol = "udp"
+	ProtocolICMP Protocol = "icmp" // ICMP and ICMPv6
+)
+
+// ProtocolFilter is the value of the protocol_filter_map map.
+type ProtocolFilter = bpfProtocolFilter
+
+// PortRange selects the TCP and UDP packets whose source or destination
+// port is in [Min, Max]. The zero value selects all the ports.
+type PortRange struct {
+	Min uint16
+	Max uint16
+}
+
+// ParsePortRange parses a port, e.g. 26656, or a port range, e.g.
+// 26656-26660. An empty string selects all the ports.
+func ParsePortRange(s string) (PortRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return PortRange{}, nil
+	}
+
+	minStr, maxStr, isRange := strings.Cut(s, "-")
+	if !isRange {
+		maxStr = minStr
+	}
+
+	min, err := strconv.ParseUint(strings.TrimSpace(minStr), 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	max, err := strconv.ParseUint(strings.TrimSpace(maxStr), 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+
+	if min == 0 || min > max {
+		return PortRange{}, fmt.Errorf("invalid port range %q: expected 1 <= min <= max", s)
+	}
+
+	return PortRange{Min: uint16(min), Max: uint16(max)}, nil
+}
+
+// IsAny reports whether the range selects all the ports.
+func (r PortRange) IsAny() bool {
+	return r.Max == 0
+}
+
+func (r PortRange) String() string {
+	if r.IsAny() {
+		return ""
+	}
+	if r.Min == r.Max {
+		return strconv.Itoa(int(r.Min))
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// NewProtocolFilter returns the value of the protocol filter in the
+// protocol_filter_map map.
+func NewProtocolFilter(p Protocol, ports PortRange) (ProtocolFilter, error) {
+	filter := ProtocolFilter{PortMin: ports.Min, PortMax: ports.Max}
+
+	switch p {
+	case ProtocolAny:
+	case ProtocolTCP:
+		filter.Protocol = unix.IPPROTO_TCP
+	case ProtocolUDP:
+		filter.Protocol = unix.IPPROTO_UDP
+	case ProtocolICMP:
+		if !ports.IsAny() {
+			return ProtocolFilter{}, fmt.Errorf("ports can not be used with the %q protocol", p)
+		}
+		filter.Protocol = unix.IPPROTO_ICMP
+	default:
+		return ProtocolFilter{}, fmt.Errorf("unknown protocol %q (expected %q, %q or %q)",
+			p, ProtocolTCP, ProtocolUDP, ProtocolICMP)
+	}
+
+	return filter, nil
+}
+
+// SetProtocolFilter scopes the service to the given transport protocol and
+// port range. Without ports, the service applies to all the packets of the
+// protocol; without a protocol, the ports select both TCP and UDP packets.
+func (x *XdpObject) SetProtocolFilter(s Service, p Protocol, ports PortRange) error {
+	filter, err := NewProtocolFilter(p, ports)
+	if err != nil {
+		return err
+	}
+
+	if err := x.BpfObjs.ProtocolFilterMap.Update(uint32(s), filter, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("update protocol filter: %w", err)
+	}
+	return nil
+}
+
+// ProtocolFilter returns the protocol and the ports the service is scoped
+// to, see SetProtocolFilter.
+func (x *XdpObject) ProtocolFilter(s Service) (Protocol, PortRange, error) {
+	var filter ProtocolFilter
+	if err := x.BpfObjs.ProtocolFilterMap.Lookup(uint32(s), &filter); err != nil {
+		return "", PortRange{}, fmt.Errorf("lookup protocol filter: %w", err)
+	}
+
+	ports := PortRange{Min: filter.PortMin, Max: filter.PortMax}
+	switch filter.Protocol {
+	case 0:
+		return ProtocolAny, ports, nil
+	case unix.IPPROTO_TCP:
+		return ProtocolTCP, ports, nil
+	case unix.IPPROTO_UDP:
+		return ProtocolUDP, ports, nil
+	case unix.IPPROTO_ICMP:
+		return ProtocolICMP, ports, nil
+	default:
+		return "", PortRange{}, fmt.Errorf("unknown protocol %d in the protocol filter", filter.Protocol)
+	}
+}
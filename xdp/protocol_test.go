@@ -0,0 +1,66 @@
+package xdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		ports      string
+		want       PortRange
+		shouldFail bool
+	}{
+		{ports: "", want: PortRange{}},
+		{ports: "26656", want: PortRange{Min: 26656, Max: 26656}},
+		{ports: "26656-26660", want: PortRange{Min: 26656, Max: 26660}},
+		{ports: "26660-26656", shouldFail: true},
+		{ports: "0", shouldFail: true},
+		{ports: "65536", shouldFail: true},
+		{ports: "p2p", shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ports, func(t *testing.T) {
+			got, err := ParsePortRange(tt.ports)
+			if tt.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ports, got.String())
+		})
+	}
+}
+
+func TestNewProtocolFilter(t *testing.T) {
+	tests := []struct {
+		protocol   Protocol
+		ports      PortRange
+		want       ProtocolFilter
+		shouldFail bool
+	}{
+		{protocol: ProtocolAny, want: ProtocolFilter{}},
+		{protocol: ProtocolAny, ports: PortRange{Min: 80, Max: 80}, want: ProtocolFilter{PortMin: 80, PortMax: 80}},
+		{protocol: ProtocolTCP, ports: PortRange{Min: 26656, Max: 26656}, want: ProtocolFilter{Protocol: 6, PortMin: 26656, PortMax: 26656}},
+		{protocol: ProtocolUDP, want: ProtocolFilter{Protocol: 17}},
+		{protocol: ProtocolICMP, want: ProtocolFilter{Protocol: 1}},
+		{protocol: ProtocolICMP, ports: PortRange{Min: 80, Max: 80}, shouldFail: true},
+		{protocol: "sctp", shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.protocol), func(t *testing.T) {
+			got, err := NewProtocolFilter(tt.protocol, tt.ports)
+			if tt.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package qdisc
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// fqUsers counts the services relying on the fq qdisc of each network
+// interface, so it is removed when the last of them stops.
+type fqUsers struct {
+	count int
+	added bool // fq was installed by AcquireFq, not by the user
+}
+
+var (
+	fqByIface   = make(map[int]*fqUsers)
+	fqByIfaceMu sync.Mutex
+)
+
+// AcquireFq makes sure the root qdisc of the network interface is fq, which
+// holds the packets until their earliest departure time (skb->tstamp). If the
+// root qdisc is the default one of the kernel, it is replaced by fq until the
+// last service calls ReleaseFq, which brings the default back. Any other root
+// qdisc was set up by the user and AcquireFq fails: replacing it would drop
+// its classes and filters, which cannot be restored.
+func AcquireFq(ifaceIndex int) error {
+	fqByIfaceMu.Lock()
+	defer fqByIfaceMu.Unlock()
+
+	if u, ok := fqByIface[ifaceIndex]; ok {
+		u.count++
+		return nil
+	}
+
+	kind, handle, err := root(ifaceIndex)
+	if err != nil {
+		return err
+	}
+
+	u := &fqUsers{count: 1}
+	if kind != "fq" {
+		// the kernel attaches its default qdiscs with the 0: handle
+		if handle != 0 {
+			return fmt.Errorf("the root qdisc of the network interface is %q, not the default one or fq: "+
+				"replace it with fq or delete it to use the fq qdisc", kind)
+		}
+		if err := ReplaceRoot(ifaceIndex, "fq"); err != nil {
+			return err
+		}
+		if err := Track(ifaceIndex, "fq"); err != nil {
+			return errors.Join(err, DeleteRoot(ifaceIndex))
+		}
+		u.added = true
+	} else {
+		// an fq left behind by a bittwister process which crashed is ours
+		// to remove, not the user's
+		adopted, err := AdoptLeftover(ifaceIndex, "fq")
+		if err != nil {
+			return err
+		}
+		u.added = adopted
+	}
+	fqByIface[ifaceIndex] = u
+
+	return nil
+}
+
+// ReleaseFq releases the fq qdisc acquired by AcquireFq. The last release
+// deletes the qdisc if AcquireFq installed it and it was not replaced since,
+// so the kernel restores the default qdisc of the interface.
+func ReleaseFq(ifaceIndex int) error {
+	fqByIfaceMu.Lock()
+	defer fqByIfaceMu.Unlock()
+
+	u, ok := fqByIface[ifaceIndex]
+	if !ok {
+		return nil
+	}
+
+	u.count--
+	if u.count > 0 {
+		return nil
+	}
+	delete(fqByIface, ifaceIndex)
+
+	if !u.added {
+		return nil
+	}
+	return Release(ifaceIndex, "fq")
+}
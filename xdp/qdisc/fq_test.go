@@ -0,0 +1,38 @@
+package qdisc
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireFqUserRoot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("rtnetlink requires root privileges")
+	}
+	setStatePath(t)
+
+	iface, err := loopback()
+	require.NoError(t, err)
+	kind, handle, err := root(iface.Index)
+	require.NoError(t, err)
+	require.Equal(t, "noqueue", kind)
+	assert.Zero(t, handle, "the default qdisc has the 0: handle")
+
+	require.NoError(t, ReplaceRoot(iface.Index, "pfifo"))
+	t.Cleanup(func() { _ = DeleteRoot(iface.Index) })
+
+	err = AcquireFq(iface.Index)
+	assert.ErrorContains(t, err, `"pfifo"`)
+
+	// the qdisc of the user is left in place
+	kind, err = RootKind(iface.Index)
+	require.NoError(t, err)
+	assert.Equal(t, "pfifo", kind)
+	records, err := Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	assert.NotContains(t, fqByIface, iface.Index)
+}
@@ -0,0 +1,200 @@
+package qdisc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	tcHClsact       = 0xFFFFFFF1
+	tcHClsactIngr   = 0xFFFFFFF2
+	tcHClsactEgress = 0xFFFFFFF3
+	tcaOptions      = 2
+	ifinfomsgLen    = 16
+
+	tcaU32Sel       = 5
+	tcaU32Act       = 7
+	tcU32Terminal   = 1
+	tcaActKind      = 1
+	tcaActOptions   = 2
+	tcaMirredParms  = 2
+	tcActStolen     = 4
+	tcaEgressRedir  = 1
+	tcMirredParmLen = 28
+
+	// redirectPrio is the priority of the filter redirecting the egress
+	// traffic to the ifb device. It is high, so the filters of the user run
+	// first.
+	redirectPrio = 0xFFF0
+)
+
+// IfbName returns the name of the ifb device the egress traffic of the network
+// interface is redirected to by AddIfb.
+func IfbName(ifaceIndex int) string {
+	return fmt.Sprintf("btifb%d", ifaceIndex)
+}
+
+// AddIfb creates an ifb device and redirects the egress traffic of the network
+// interface to it, so a qdisc installed as its root qdisc, e.g. netem, applies
+// to the traffic while the qdiscs of the network interface are left untouched:
+// the ifb device sends the packets back through them. The redirection is a
+// filter of the clsact qdisc, which is added if the interface has none. It
+// returns the ifb device and whether the clsact qdisc was added.
+func AddIfb(ifaceIndex int) (*net.Interface, bool, error) {
+	name := IfbName(ifaceIndex)
+	if err := addIfbLink(name); err != nil {
+		return nil, false, fmt.Errorf("add ifb device %s: %w", name, err)
+	}
+	ifb, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, false, errors.Join(err, deleteLink(name))
+	}
+
+	kind, _, err := find(ifaceIndex, tcHClsact)
+	if err != nil {
+		return nil, false, errors.Join(err, deleteLink(name))
+	}
+	clsactAdded := kind != "clsact"
+	if clsactAdded {
+		if err := addClsact(ifaceIndex); err != nil {
+			return nil, false, errors.Join(err, deleteLink(name))
+		}
+	}
+
+	if err := addRedirect(ifaceIndex, ifb.Index); err != nil {
+		return nil, false, errors.Join(err, DeleteIfb(ifaceIndex, clsactAdded))
+	}
+	return ifb, clsactAdded, nil
+}
+
+// DeleteIfb removes the redirection of the egress traffic of the network
+// interface and the ifb device added by AddIfb, with its qdisc. The clsact
+// qdisc is removed too if AddIfb added it and no other filter uses it.
+func DeleteIfb(ifaceIndex int, clsactAdded bool) error {
+	data := tcmsg(ifaceIndex, tcHClsactEgress)
+	binary.NativeEndian.PutUint32(data[16:20], filterInfo(redirectPrio))
+	_, err := request(unix.RTM_DELTFILTER, unix.NLM_F_ACK, data)
+	// the filter, the clsact qdisc or the interface may be gone
+	if err != nil && !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.ENODEV) {
+		return fmt.Errorf("delete redirect filter: %w", err)
+	}
+
+	if clsactAdded {
+		if err := deleteClsact(ifaceIndex); err != nil {
+			return err
+		}
+	}
+
+	if err := deleteLink(IfbName(ifaceIndex)); err != nil {
+		return fmt.Errorf("delete ifb device: %w", err)
+	}
+	return nil
+}
+
+func addIfbLink(name string) error {
+	info := appendAttr(nil, unix.IFLA_INFO_KIND, []byte("ifb"))
+
+	data := make([]byte, ifinfomsgLen)
+	binary.NativeEndian.PutUint32(data[8:12], unix.IFF_UP)  // flags
+	binary.NativeEndian.PutUint32(data[12:16], unix.IFF_UP) // change
+	data = appendAttr(data, unix.IFLA_IFNAME, append([]byte(name), 0))
+	data = appendAttr(data, unix.IFLA_LINKINFO|unix.NLA_F_NESTED, info)
+
+	_, err := request(unix.RTM_NEWLINK, unix.NLM_F_CREATE|unix.NLM_F_EXCL|unix.NLM_F_ACK, data)
+	return err
+}
+
+// deleteLink deletes the network interface, which may be gone already.
+func deleteLink(name string) error {
+	data := make([]byte, ifinfomsgLen)
+	data = appendAttr(data, unix.IFLA_IFNAME, append([]byte(name), 0))
+
+	_, err := request(unix.RTM_DELLINK, unix.NLM_F_ACK, data)
+	if errors.Is(err, unix.ENODEV) {
+		return nil
+	}
+	return err
+}
+
+func addClsact(ifaceIndex int) error {
+	data := tcmsg(ifaceIndex, tcHClsact)
+	binary.NativeEndian.PutUint32(data[8:12], 0xFFFF0000) // handle ffff:
+	data = appendAttr(data, tcaKind, append([]byte("clsact"), 0))
+
+	_, err := request(unix.RTM_NEWQDISC, unix.NLM_F_CREATE|unix.NLM_F_EXCL|unix.NLM_F_ACK, data)
+	if err != nil {
+		return fmt.Errorf("add clsact qdisc: %w", err)
+	}
+	return nil
+}
+
+// deleteClsact deletes the clsact qdisc of the network interface unless it
+// still has filters.
+func deleteClsact(ifaceIndex int) error {
+	for _, parent := range []uint32{tcHClsactIngr, tcHClsactEgress} {
+		msgs, err := request(unix.RTM_GETTFILTER, unix.NLM_F_DUMP, tcmsg(ifaceIndex, parent))
+		if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENODEV) {
+			// the clsact qdisc or the interface is gone
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("dump filters: %w", err)
+		}
+		if len(msgs) > 0 {
+			return nil
+		}
+	}
+
+	_, err := request(unix.RTM_DELQDISC, unix.NLM_F_ACK, tcmsg(ifaceIndex, tcHClsact))
+	if err != nil && !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.EINVAL) {
+		return fmt.Errorf("delete clsact qdisc: %w", err)
+	}
+	return nil
+}
+
+// addRedirect adds the filter redirecting all the egress traffic of the
+// network interface to the ifb device, the equivalent of:
+//
+//	tc filter add dev <iface> egress prio <redirectPrio> u32 match u32 0 0 \
+//		action mirred egress redirect dev <ifb>
+func addRedirect(ifaceIndex, ifbIndex int) error {
+	// a selector with a single key matching every packet
+	sel := make([]byte, 32)
+	sel[0] = tcU32Terminal
+	sel[2] = 1 // nkeys
+
+	parms := make([]byte, tcMirredParmLen)
+	binary.NativeEndian.PutUint32(parms[8:12], tcActStolen)
+	binary.NativeEndian.PutUint32(parms[20:24], tcaEgressRedir)
+	binary.NativeEndian.PutUint32(parms[24:28], uint32(ifbIndex))
+
+	action := appendAttr(nil, tcaActKind, append([]byte("mirred"), 0))
+	action = appendAttr(action, tcaActOptions|unix.NLA_F_NESTED, appendAttr(nil, tcaMirredParms, parms))
+	actions := appendAttr(nil, 1|unix.NLA_F_NESTED, action)
+
+	options := appendAttr(nil, tcaU32Sel, sel)
+	options = appendAttr(options, tcaU32Act|unix.NLA_F_NESTED, actions)
+
+	data := tcmsg(ifaceIndex, tcHClsactEgress)
+	binary.NativeEndian.PutUint32(data[16:20], filterInfo(redirectPrio))
+	data = appendAttr(data, tcaKind, append([]byte("u32"), 0))
+	data = appendAttr(data, tcaOptions|unix.NLA_F_NESTED, options)
+
+	_, err := request(unix.RTM_NEWTFILTER, unix.NLM_F_CREATE|unix.NLM_F_EXCL|unix.NLM_F_ACK, data)
+	if err != nil {
+		return fmt.Errorf("add redirect filter: %w", err)
+	}
+	return nil
+}
+
+// filterInfo returns the tcm_info of a filter of all the protocols with the
+// priority.
+func filterInfo(prio uint16) uint32 {
+	var proto [2]byte
+	binary.BigEndian.PutUint16(proto[:], unix.ETH_P_ALL)
+	return uint32(prio)<<16 | uint32(binary.NativeEndian.Uint16(proto[:]))
+}
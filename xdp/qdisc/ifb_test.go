@@ -0,0 +1,58 @@
+package qdisc
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIfbLoopback(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("rtnetlink requires root privileges")
+	}
+	setStatePath(t)
+
+	iface, err := loopback()
+	require.NoError(t, err)
+	kind, err := RootKind(iface.Index)
+	require.NoError(t, err)
+
+	ifb, clsactAdded, err := AddIfb(iface.Index)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = DeleteIfb(iface.Index, clsactAdded) })
+	assert.Equal(t, IfbName(iface.Index), ifb.Name)
+
+	_, _, err = AddIfb(iface.Index)
+	assert.Error(t, err, "the ifb device exists already")
+
+	clsact, _, err := find(iface.Index, tcHClsact)
+	require.NoError(t, err)
+	assert.Equal(t, "clsact", clsact)
+
+	require.NoError(t, ReplaceRoot(ifb.Index, "pfifo"))
+	require.NoError(t, TrackIfb(iface.Index, "pfifo", clsactAdded))
+	records, err := Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, ifb.Name, records[0].Ifb)
+
+	require.NoError(t, Release(iface.Index, "pfifo"))
+	_, err = net.InterfaceByName(ifb.Name)
+	assert.Error(t, err, "the ifb device is deleted")
+	records, err = Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	// the qdiscs of the interface are left as they were
+	current, err := RootKind(iface.Index)
+	require.NoError(t, err)
+	assert.Equal(t, kind, current)
+	if clsactAdded {
+		clsact, _, err = find(iface.Index, tcHClsact)
+		require.NoError(t, err)
+		assert.Empty(t, clsact)
+	}
+}
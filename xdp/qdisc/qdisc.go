@@ -0,0 +1,194 @@
+// Package qdisc manages the root queueing discipline of a network interface
+// through rtnetlink, so it does not depend on the tc binary.
+package qdisc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	tcHRoot    = 0xFFFFFFFF
+	tcmsgLen   = 20
+	rtattrLen  = 4
+	tcaKind    = 1
+	nlmsgAlign = 4
+)
+
+// RootKind returns the kind of the root qdisc of the network interface,
+// e.g. "fq", "mq" or "noqueue". It returns an empty string if the kernel does
+// not list any root qdisc, e.g. for a down interface using the builtin noop qdisc.
+func RootKind(ifaceIndex int) (string, error) {
+	kind, _, err := root(ifaceIndex)
+	return kind, err
+}
+
+// root returns the kind and the handle of the root qdisc of the network
+// interface, see RootKind.
+func root(ifaceIndex int) (string, uint32, error) {
+	return find(ifaceIndex, tcHRoot)
+}
+
+// find returns the kind and the handle of the qdisc of the network interface
+// attached to the parent, or an empty kind if there is none.
+func find(ifaceIndex int, parent uint32) (string, uint32, error) {
+	msgs, err := request(unix.RTM_GETQDISC, unix.NLM_F_DUMP, tcmsg(ifaceIndex, 0))
+	if err != nil {
+		return "", 0, fmt.Errorf("dump qdiscs: %w", err)
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWQDISC || len(m.Data) < tcmsgLen {
+			continue
+		}
+
+		index := int32(binary.NativeEndian.Uint32(m.Data[4:8]))
+		if int(index) != ifaceIndex || binary.NativeEndian.Uint32(m.Data[12:16]) != parent {
+			continue
+		}
+
+		if kind, ok := attr(m.Data[tcmsgLen:], tcaKind); ok {
+			handle := binary.NativeEndian.Uint32(m.Data[8:12])
+			return string(trimNull(kind)), handle, nil
+		}
+	}
+
+	return "", 0, nil
+}
+
+// ReplaceRoot replaces the root qdisc of the network interface with a qdisc
+// of the given kind using its default parameters.
+func ReplaceRoot(ifaceIndex int, kind string) error {
+	data := tcmsg(ifaceIndex, tcHRoot)
+	data = appendAttr(data, tcaKind, append([]byte(kind), 0))
+
+	_, err := request(unix.RTM_NEWQDISC, unix.NLM_F_CREATE|unix.NLM_F_REPLACE|unix.NLM_F_ACK, data)
+	if err != nil {
+		return fmt.Errorf("replace root qdisc with %q: %w", kind, err)
+	}
+	return nil
+}
+
+// DeleteRoot deletes the root qdisc of the network interface, so the kernel
+// falls back to the default qdisc of the interface.
+func DeleteRoot(ifaceIndex int) error {
+	_, err := request(unix.RTM_DELQDISC, unix.NLM_F_ACK, tcmsg(ifaceIndex, tcHRoot))
+	if err != nil {
+		return fmt.Errorf("delete root qdisc: %w", err)
+	}
+	return nil
+}
+
+func tcmsg(ifaceIndex int, parent uint32) []byte {
+	b := make([]byte, tcmsgLen)
+	b[0] = unix.AF_UNSPEC
+	binary.NativeEndian.PutUint32(b[4:8], uint32(ifaceIndex))
+	binary.NativeEndian.PutUint32(b[12:16], parent)
+	return b
+}
+
+func appendAttr(b []byte, attrType uint16, value []byte) []byte {
+	a := make([]byte, align(rtattrLen+len(value)))
+	binary.NativeEndian.PutUint16(a[0:2], uint16(rtattrLen+len(value)))
+	binary.NativeEndian.PutUint16(a[2:4], attrType)
+	copy(a[rtattrLen:], value)
+	return append(b, a...)
+}
+
+func attr(b []byte, attrType uint16) ([]byte, bool) {
+	for len(b) >= rtattrLen {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < rtattrLen || l > len(b) {
+			return nil, false
+		}
+		if binary.NativeEndian.Uint16(b[2:4]) == attrType {
+			return b[rtattrLen:l], true
+		}
+		if align(l) >= len(b) {
+			break
+		}
+		b = b[align(l):]
+	}
+	return nil, false
+}
+
+func request(msgType uint16, flags int, data []byte) ([]syscall.NetlinkMessage, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("open netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("bind netlink socket: %w", err)
+	}
+
+	const seq = 1
+	msg := make([]byte, unix.NLMSG_HDRLEN, unix.NLMSG_HDRLEN+len(data))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(unix.NLMSG_HDRLEN+len(data)))
+	binary.NativeEndian.PutUint16(msg[4:6], msgType)
+	binary.NativeEndian.PutUint16(msg[6:8], uint16(unix.NLM_F_REQUEST|flags))
+	binary.NativeEndian.PutUint32(msg[8:12], seq)
+	msg = append(msg, data...)
+
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("send netlink message: %w", err)
+	}
+
+	var out []syscall.NetlinkMessage
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("receive netlink message: %w", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("parse netlink message: %w", err)
+		}
+
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return out, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, errors.New("truncated netlink error message")
+				}
+				if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				// An error message with errno 0 acknowledges the request.
+				return out, nil
+			default:
+				out = append(out, m)
+			}
+		}
+
+		if flags&unix.NLM_F_DUMP == 0 && flags&unix.NLM_F_ACK == 0 {
+			return out, nil
+		}
+	}
+}
+
+func align(l int) int {
+	return (l + nlmsgAlign - 1) & ^(nlmsgAlign - 1)
+}
+
+func trimNull(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
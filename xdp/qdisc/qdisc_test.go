@@ -0,0 +1,43 @@
+package qdisc
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootKindLoopback(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("rtnetlink requires root privileges")
+	}
+
+	interfaces, err := net.Interfaces()
+	require.NoError(t, err)
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+
+		kind, err := RootKind(iface.Index)
+		require.NoError(t, err)
+		assert.Equal(t, "noqueue", kind)
+		return
+	}
+	t.Skip("loopback interface not found")
+}
+
+func TestAttr(t *testing.T) {
+	b := appendAttr(nil, 2, []byte{1, 2, 3})
+	b = appendAttr(b, tcaKind, append([]byte("fq"), 0))
+
+	kind, ok := attr(b, tcaKind)
+	require.True(t, ok)
+	assert.Equal(t, "fq", string(trimNull(kind)))
+
+	_, ok = attr(b, 3)
+	assert.False(t, ok)
+}
@@ -0,0 +1,408 @@
+package qdisc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// StatePath is the file recording the root qdiscs installed by the bittwister
+// processes. The qdiscs outlive a process which crashed, the file tells them
+// apart from the qdiscs of the user.
+var StatePath = "/run/bittwister/qdiscs.json"
+
+var (
+	// processLock is the lock file this process holds while it has records,
+	// see Record.Lock, and processLockName its name.
+	processLock     *os.File
+	processLockName string
+	processLockMu   sync.Mutex
+)
+
+// Record is a qdisc installed by a bittwister process, the root qdisc of a
+// network interface or of its ifb device.
+type Record struct {
+	NetworkInterfaceIndex int       `json:"network_interface_index"`
+	NetworkInterfaceName  string    `json:"network_interface_name"`
+	Kind                  string    `json:"kind"`
+	Handle                uint32    `json:"handle"`
+	PID                   int       `json:"pid"`
+	Installed             time.Time `json:"installed"`
+	// Ifb is the ifb device the egress traffic of the network interface is
+	// redirected to, see AddIfb. The qdisc is its root qdisc then, and Clsact
+	// reports whether the clsact qdisc of the redirection was added too.
+	Ifb    string `json:"ifb,omitempty"`
+	Clsact bool   `json:"clsact,omitempty"`
+	// Lock is the file, next to StatePath, the process holds locked while it
+	// runs. The PID alone can be reused, e.g. by bittwister itself once its
+	// container restarted.
+	Lock string `json:"lock,omitempty"`
+}
+
+// Alive reports whether the process which installed the qdisc still runs.
+func (r Record) Alive() bool {
+	if r.Lock == "" {
+		// recorded by a version without lock files
+		err := unix.Kill(r.PID, 0)
+		return err == nil || errors.Is(err, unix.EPERM)
+	}
+	return lockHeld(r.Lock)
+}
+
+func (r Record) String() string {
+	if r.Ifb != "" {
+		return fmt.Sprintf("%s qdisc %x: on %s for %s (pid %d)", r.Kind, r.Handle>>16, r.Ifb, r.NetworkInterfaceName, r.PID)
+	}
+	return fmt.Sprintf("%s qdisc %x: on %s (pid %d)", r.Kind, r.Handle>>16, r.NetworkInterfaceName, r.PID)
+}
+
+// Track records the root qdisc of the network interface, which must be of the
+// given kind, as installed by this process.
+func Track(ifaceIndex int, kind string) error {
+	return track(Record{NetworkInterfaceIndex: ifaceIndex, Kind: kind}, ifaceIndex)
+}
+
+// TrackIfb records the root qdisc of the ifb device added by AddIfb for the
+// network interface, which must be of the given kind, as installed by this
+// process with the redirection.
+func TrackIfb(ifaceIndex int, kind string, clsactAdded bool) error {
+	ifb, err := net.InterfaceByName(IfbName(ifaceIndex))
+	if err != nil {
+		return err
+	}
+	return track(Record{NetworkInterfaceIndex: ifaceIndex, Kind: kind, Ifb: ifb.Name, Clsact: clsactAdded}, ifb.Index)
+}
+
+// track records r with the handle of the root qdisc of the device, the network
+// interface or its ifb device.
+func track(r Record, deviceIndex int) error {
+	current, handle, err := root(deviceIndex)
+	if err != nil {
+		return err
+	}
+	if current != r.Kind {
+		return fmt.Errorf("root qdisc is %q, not %q", current, r.Kind)
+	}
+
+	r.Handle = handle
+	r.PID = os.Getpid()
+	r.Installed = time.Now().UTC()
+	if iface, err := net.InterfaceByIndex(r.NetworkInterfaceIndex); err == nil {
+		r.NetworkInterfaceName = iface.Name
+	}
+
+	processLockMu.Lock()
+	defer processLockMu.Unlock()
+
+	if r.Lock, err = lockProcess(); err != nil {
+		return err
+	}
+	return updateState(func(records []Record) []Record {
+		return append(without(records, r.NetworkInterfaceIndex, r.Kind), r)
+	})
+}
+
+// Untrack forgets the qdisc of the kind recorded for the network interface,
+// without deleting it.
+func Untrack(ifaceIndex int, kind string) error {
+	processLockMu.Lock()
+	defer processLockMu.Unlock()
+
+	var records []Record
+	err := updateState(func(all []Record) []Record {
+		records = without(all, ifaceIndex, kind)
+		return records
+	})
+	if err != nil {
+		return err
+	}
+	return removeLocks(records)
+}
+
+// Release deletes the qdisc of the kind this process recorded for the network
+// interface, unless it was replaced since, and forgets it.
+func Release(ifaceIndex int, kind string) error {
+	records, err := Records()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r.NetworkInterfaceIndex == ifaceIndex && r.Kind == kind && r.mine() {
+			_, err := Cleanup(r)
+			return err
+		}
+	}
+	return nil
+}
+
+// Records returns the root qdiscs installed by the bittwister processes, the
+// running ones included.
+func Records() ([]Record, error) {
+	var records []Record
+	err := withState(os.O_RDONLY, unix.LOCK_SH, func(f *os.File) error {
+		var err error
+		records, err = readState(f)
+		return err
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return records, err
+}
+
+// Leftovers returns the root qdiscs installed by bittwister processes which
+// are gone, e.g. killed or crashed before removing them.
+func Leftovers() ([]Record, error) {
+	records, err := Records()
+	if err != nil {
+		return nil, err
+	}
+
+	var leftovers []Record
+	for _, r := range records {
+		if !r.Alive() {
+			leftovers = append(leftovers, r)
+		}
+	}
+	return leftovers, nil
+}
+
+// DeleteLeftover deletes the qdisc of the kind of the network interface if a
+// bittwister process which is gone installed it. It reports whether the
+// qdisc was deleted.
+func DeleteLeftover(ifaceIndex int, kind string) (bool, error) {
+	r, ok, err := leftover(ifaceIndex, kind)
+	if err != nil || !ok {
+		return false, err
+	}
+	return Cleanup(r)
+}
+
+// AdoptLeftover records the root qdisc of the kind of the network interface as
+// installed by this process if a bittwister process which is gone installed
+// it. It reports whether the qdisc was adopted.
+func AdoptLeftover(ifaceIndex int, kind string) (bool, error) {
+	r, ok, err := leftover(ifaceIndex, kind)
+	if err != nil || !ok || r.Ifb != "" {
+		return false, err
+	}
+	return true, Track(ifaceIndex, r.Kind)
+}
+
+func leftover(ifaceIndex int, kind string) (Record, bool, error) {
+	leftovers, err := Leftovers()
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	for _, r := range leftovers {
+		if r.NetworkInterfaceIndex != ifaceIndex || r.Kind != kind {
+			continue
+		}
+		installed, err := isInstalled(r)
+		return r, installed, err
+	}
+	return Record{}, false, nil
+}
+
+// Cleanup deletes the root qdisc of the record if it is still the root qdisc
+// of the network interface, and forgets the record. A qdisc which replaced it
+// since is left alone. The qdisc of an ifb device is deleted with the device
+// and the redirection, whatever replaced it. It reports whether the qdisc was
+// deleted.
+func Cleanup(r Record) (bool, error) {
+	installed, err := isInstalled(r)
+	if err != nil {
+		return false, err
+	}
+	if installed {
+		if r.Ifb != "" {
+			err = DeleteIfb(r.NetworkInterfaceIndex, r.Clsact)
+		} else {
+			err = DeleteRoot(r.NetworkInterfaceIndex)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return installed, Untrack(r.NetworkInterfaceIndex, r.Kind)
+}
+
+// isInstalled reports whether the qdisc of the record is the root qdisc of
+// its network interface, which may be gone, or whether its ifb device still
+// exists.
+func isInstalled(r Record) (bool, error) {
+	if r.Ifb != "" {
+		_, err := net.InterfaceByName(r.Ifb)
+		return err == nil, nil
+	}
+	if _, err := net.InterfaceByIndex(r.NetworkInterfaceIndex); err != nil {
+		return false, nil
+	}
+
+	kind, handle, err := root(r.NetworkInterfaceIndex)
+	if err != nil {
+		return false, err
+	}
+	return kind == r.Kind && handle == r.Handle, nil
+}
+
+// mine reports whether this process installed the qdisc.
+func (r Record) mine() bool {
+	processLockMu.Lock()
+	defer processLockMu.Unlock()
+
+	return processLock != nil && r.Lock == processLockName
+}
+
+// lockProcess returns the name of the lock file of this process, see
+// Record.Lock. The file is created and locked on the first call.
+func lockProcess() (string, error) {
+	if processLock != nil {
+		return processLockName, nil
+	}
+
+	dir := filepath.Dir(StatePath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create state directory: %w", err)
+	}
+	// the file is locked before it gets its name, so removeLocks never sees
+	// it unlocked
+	f, err := os.CreateTemp(dir, ".lock-")
+	if err != nil {
+		return "", fmt.Errorf("create lock file: %w", err)
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		return "", errors.Join(fmt.Errorf("lock file: %w", err), f.Close(), os.Remove(f.Name()))
+	}
+	name := fmt.Sprintf("%d-%d.lock", os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(f.Name(), filepath.Join(dir, name)); err != nil {
+		return "", errors.Join(fmt.Errorf("rename lock file: %w", err), f.Close(), os.Remove(f.Name()))
+	}
+	processLock, processLockName = f, name
+	return name, nil
+}
+
+// lockHeld reports whether a running process holds the lock file.
+func lockHeld(name string) bool {
+	f, err := os.Open(filepath.Join(filepath.Dir(StatePath), name))
+	if err != nil {
+		// the file of a process which is gone may be removed already
+		return !errors.Is(err, os.ErrNotExist)
+	}
+	defer f.Close()
+
+	err = unix.Flock(int(f.Fd()), unix.LOCK_SH|unix.LOCK_NB)
+	return errors.Is(err, unix.EWOULDBLOCK)
+}
+
+// removeLocks removes the lock files none of the records refers to, unless a
+// running process holds them: it is about to record a qdisc.
+func removeLocks(records []Record) error {
+	names, err := filepath.Glob(filepath.Join(filepath.Dir(StatePath), "*.lock"))
+	if err != nil {
+		return err
+	}
+
+	used := make(map[string]bool)
+	for _, r := range records {
+		used[r.Lock] = true
+	}
+	for _, name := range names {
+		base := filepath.Base(name)
+		if used[base] {
+			continue
+		}
+
+		if processLock != nil && base == processLockName {
+			// the last record of this process is gone
+			err := errors.Join(os.Remove(name), processLock.Close())
+			processLock, processLockName = nil, ""
+			if err != nil {
+				return fmt.Errorf("remove lock file: %w", err)
+			}
+			continue
+		}
+		if lockHeld(base) {
+			continue
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove lock file: %w", err)
+		}
+	}
+	return nil
+}
+
+func without(records []Record, ifaceIndex int, kind string) []Record {
+	out := records[:0]
+	for _, r := range records {
+		if r.NetworkInterfaceIndex != ifaceIndex || r.Kind != kind {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// updateState rewrites the state file with the records returned by update,
+// under an exclusive lock so that the processes do not lose their updates.
+func updateState(update func([]Record) []Record) error {
+	if err := os.MkdirAll(filepath.Dir(StatePath), 0o755); err != nil {
+		return fmt.Errorf("create state directory: %w", err)
+	}
+
+	return withState(os.O_RDWR|os.O_CREATE, unix.LOCK_EX, func(f *os.File) error {
+		records, err := readState(f)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.MarshalIndent(update(records), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := f.Truncate(0); err != nil {
+			return fmt.Errorf("truncate state file: %w", err)
+		}
+		if _, err := f.WriteAt(append(b, '\n'), 0); err != nil {
+			return fmt.Errorf("write state file: %w", err)
+		}
+		return nil
+	})
+}
+
+func withState(flag, lock int, fn func(*os.File) error) error {
+	f, err := os.OpenFile(StatePath, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := unix.Flock(int(f.Fd()), lock); err != nil {
+		return fmt.Errorf("lock state file: %w", err)
+	}
+	return fn(f)
+}
+
+func readState(f *os.File) ([]Record, error) {
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	var records []Record
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("parse state file %s: %w", StatePath, err)
+	}
+	return records, nil
+}
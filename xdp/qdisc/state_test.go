@@ -0,0 +1,130 @@
+package qdisc
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setStatePath(t *testing.T) {
+	prev := StatePath
+	StatePath = filepath.Join(t.TempDir(), "bittwister", "qdiscs.json")
+	t.Cleanup(func() {
+		StatePath = prev
+		if processLock != nil {
+			processLock.Close()
+			processLock, processLockName = nil, ""
+		}
+	})
+}
+
+// deadPID returns the PID of a process which exited.
+func deadPID(t *testing.T) int {
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func TestStateRecords(t *testing.T) {
+	setStatePath(t)
+
+	records, err := Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	running := Record{NetworkInterfaceIndex: 1, Kind: "netem", Handle: 0x80010000, PID: os.Getpid()}
+	crashed := Record{NetworkInterfaceIndex: 2, Kind: "fq", Handle: 0x80020000, PID: deadPID(t)}
+	require.NoError(t, updateState(func(r []Record) []Record { return append(r, running) }))
+	require.NoError(t, updateState(func(r []Record) []Record { return append(r, crashed) }))
+
+	records, err = Records()
+	require.NoError(t, err)
+	assert.Equal(t, []Record{running, crashed}, records)
+
+	leftovers, err := Leftovers()
+	require.NoError(t, err)
+	assert.Equal(t, []Record{crashed}, leftovers)
+
+	require.NoError(t, Untrack(crashed.NetworkInterfaceIndex, crashed.Kind))
+	records, err = Records()
+	require.NoError(t, err)
+	assert.Equal(t, []Record{running}, records)
+}
+
+func TestStateInvalidFile(t *testing.T) {
+	setStatePath(t)
+	require.NoError(t, os.MkdirAll(filepath.Dir(StatePath), 0o755))
+	require.NoError(t, os.WriteFile(StatePath, []byte("{"), 0o644))
+
+	_, err := Records()
+	assert.Error(t, err)
+	assert.Error(t, Untrack(1, "fq"))
+}
+
+func TestTrackLoopback(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("rtnetlink requires root privileges")
+	}
+	setStatePath(t)
+
+	iface, err := loopback()
+	require.NoError(t, err)
+
+	assert.Error(t, Track(iface.Index, "netem"), "the root qdisc of lo is noqueue")
+
+	require.NoError(t, Track(iface.Index, "noqueue"))
+	records, err := Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, iface.Name, records[0].NetworkInterfaceName)
+	assert.Equal(t, os.Getpid(), records[0].PID)
+	assert.True(t, records[0].Alive())
+	lock := filepath.Join(filepath.Dir(StatePath), records[0].Lock)
+	assert.FileExists(t, lock)
+
+	// a running process is not a leftover
+	deleted, err := DeleteLeftover(iface.Index, "noqueue")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	// the lock file goes with the last record of the process
+	require.NoError(t, Untrack(iface.Index, "noqueue"))
+	assert.NoFileExists(t, lock)
+}
+
+func TestStateReusedPID(t *testing.T) {
+	setStatePath(t)
+	require.NoError(t, os.MkdirAll(filepath.Dir(StatePath), 0o755))
+
+	// a process which crashed with the PID of this one, e.g. the previous
+	// run of a container, left its lock file behind
+	const lock = "1-1.lock"
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(StatePath), lock), nil, 0o644))
+	crashed := Record{NetworkInterfaceIndex: 2, Kind: "netem", Handle: 0x80020000, PID: os.Getpid(), Lock: lock}
+	require.NoError(t, updateState(func(r []Record) []Record { return append(r, crashed) }))
+
+	leftovers, err := Leftovers()
+	require.NoError(t, err)
+	assert.Equal(t, []Record{crashed}, leftovers)
+
+	require.NoError(t, Untrack(crashed.NetworkInterfaceIndex, crashed.Kind))
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(StatePath), lock))
+}
+
+func loopback() (*net.Interface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return &iface, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
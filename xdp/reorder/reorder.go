@@ -0,0 +1,244 @@
+package reorder
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"github.com/celestiaorg/bittwister/xdp"
+	"github.com/celestiaorg/bittwister/xdp/qdisc"
+	"github.com/cilium/ebpf"
+)
+
+// DefaultDelay is how long a reordered packet is held back when no delay is
+// given, so the packets sent after it overtake it.
+const DefaultDelay = 10 * time.Millisecond
+
+// Reorder delivers some of the egress packets out of order: the TC program
+// sets the departure time of a reordered packet Delay later, and the fq
+// qdisc sends the following packets first.
+type Reorder struct {
+	NetworkInterface *net.Interface
+	ReorderRate      float64       // Percent, e.g. 25 for 25%
+	Gap              uint32        // At least Gap-1 packets are sent in order between two reordered packets
+	Delay            time.Duration // default: DefaultDelay
+	Peers            []*net.IPNet  // default: all the traffic
+	Protocol         xdp.Protocol  // default: all the protocols
+	Ports            xdp.PortRange // default: all the ports
+
+	x *xdp.XdpObject // set while the service runs
+}
+
+var (
+	_ xdp.XdpLoader   = (*Reorder)(nil)
+	_ xdp.Updater     = (*Reorder)(nil)
+	_ xdp.StatsReader = (*Reorder)(nil)
+	_ xdp.Adopter     = (*Reorder)(nil)
+)
+
+// ValidateRate checks that the reorder rate is a percentage. The kernel applies
+// it with a precision of one part per million (0.0001%).
+func ValidateRate(rate float64) error {
+	if rate < 0 || rate > 100 || math.IsNaN(rate) {
+		return fmt.Errorf("reorder rate must be between 0 and 100, got %v", rate)
+	}
+	if rate != 0 && xdp.PercentToPPM(rate) == 0 {
+		// it would read as a stopped service in the kernel
+		return fmt.Errorf("reorder rate %v is below one part per million (0.0001%%)", rate)
+	}
+	return nil
+}
+
+func (r *Reorder) Validate() error {
+	if r.NetworkInterface == nil {
+		return xdp.ErrNetworkInterfaceNotSet
+	}
+	if err := ValidateRate(r.ReorderRate); err != nil {
+		return err
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("reorder delay must not be negative")
+	}
+
+	_, err := xdp.NewProtocolFilter(r.Protocol, r.Ports)
+	return err
+}
+
+// setDefaults fills in the defaults of the unset parameters.
+func (r *Reorder) setDefaults() {
+	if r.Delay == 0 {
+		r.Delay = DefaultDelay
+	}
+}
+
+func (r *Reorder) Start() (xdp.CancelFunc, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	r.setDefaults()
+
+	x, err := xdp.GetPreparedXdpObject(r.NetworkInterface.Index)
+	if err != nil {
+		return nil, fmt.Errorf("prepare XDP object: %w", err)
+	}
+
+	if err := qdisc.AcquireFq(r.NetworkInterface.Index); err != nil {
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, err
+	}
+
+	key := uint32(0)
+	err = x.AttachTc()
+	if err == nil {
+		err = x.ResetStats(xdp.ServiceReorder)
+	}
+	if err == nil {
+		err = x.SetPeers(xdp.ServiceReorder, r.Peers)
+	}
+	if err == nil {
+		err = x.SetProtocolFilter(xdp.ServiceReorder, r.Protocol, r.Ports)
+	}
+	if err == nil {
+		err = x.BpfObjs.ReorderParamsMap.Update(key, r.mapValue(), ebpf.UpdateAny)
+	}
+	if err != nil {
+		if cErr := qdisc.ReleaseFq(r.NetworkInterface.Index); cErr != nil {
+			return nil, cErr
+		}
+		if cErr := x.Close(); cErr != nil {
+			return nil, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, fmt.Errorf("update reorder params: %w", err)
+	}
+
+	return r.run(x), nil
+}
+
+// Adopt takes over the reordering a previous process left in the pinned
+// maps, with the fq qdisc it installed.
+func (r *Reorder) Adopt() (xdp.CancelFunc, bool, error) {
+	x, ok, err := xdp.AdoptObject(r.NetworkInterface.Index, r.readMaps)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	if err := qdisc.AcquireFq(r.NetworkInterface.Index); err != nil {
+		if cErr := x.Close(); cErr != nil {
+			return nil, false, fmt.Errorf("close XDP object: %w", cErr)
+		}
+		return nil, false, err
+	}
+	return r.run(x), true, nil
+}
+
+// readMaps sets the parameters of the service from the maps, and reports
+// whether it runs.
+func (r *Reorder) readMaps(x *xdp.XdpObject) (bool, error) {
+	var params xdp.ReorderParams
+	if err := x.BpfObjs.ReorderParamsMap.Lookup(uint32(0), &params); err != nil {
+		return false, err
+	}
+	if params == (xdp.ReorderParams{}) {
+		return false, nil
+	}
+	r.ReorderRate = xdp.PPMToPercent(params.Rate)
+	r.Gap = params.Gap
+	r.Delay = time.Duration(params.DelayNs)
+
+	var err error
+	if r.Peers, err = x.Peers(xdp.ServiceReorder); err != nil {
+		return false, err
+	}
+	if r.Protocol, r.Ports, err = x.ProtocolFilter(xdp.ServiceReorder); err != nil {
+		return false, err
+	}
+	return true, r.Validate()
+}
+
+// run marks the service as running on the XDP object, and returns the
+// function which stops it.
+func (r *Reorder) run(x *xdp.XdpObject) xdp.CancelFunc {
+	key := uint32(0)
+	r.x = x
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+	}()
+
+	cancelFunc := xdp.CancelFunc(func() error {
+		// Update the map with zero values to disable the reordering.
+		err := x.BpfObjs.ReorderParamsMap.Update(key, xdp.ReorderParams{}, ebpf.UpdateAny)
+		if err != nil {
+			return fmt.Errorf("update reorder params to zero: %w", err)
+		}
+
+		r.x = nil
+
+		if err := x.SetPeers(xdp.ServiceReorder, nil); err != nil {
+			return fmt.Errorf("clear reorder peers: %w", err)
+		}
+		if err := x.SetProtocolFilter(xdp.ServiceReorder, xdp.ProtocolAny, xdp.PortRange{}); err != nil {
+			return fmt.Errorf("clear reorder protocol filter: %w", err)
+		}
+
+		if err := qdisc.ReleaseFq(r.NetworkInterface.Index); err != nil {
+			return err
+		}
+
+		if err := x.Close(); err != nil {
+			return fmt.Errorf("close XDP object: %w", err)
+		}
+		cancel()
+		return nil
+	})
+
+	return cancelFunc
+}
+
+// Update changes the reorder rate, gap and delay of the running service.
+func (r *Reorder) Update(next xdp.XdpLoader) error {
+	n, ok := next.(*Reorder)
+	if !ok {
+		return fmt.Errorf("cannot update %T with %T", r, next)
+	}
+	if r.x == nil {
+		return xdp.ErrServiceNotRunning
+	}
+
+	u := *r
+	u.ReorderRate, u.Gap, u.Delay = n.ReorderRate, n.Gap, n.Delay
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	u.setDefaults()
+
+	key := uint32(0)
+	if err := r.x.BpfObjs.ReorderParamsMap.Update(key, u.mapValue(), ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("update reorder params: %w", err)
+	}
+
+	*r = u
+	return nil
+}
+
+func (r *Reorder) mapValue() xdp.ReorderParams {
+	return xdp.ReorderParams{
+		Rate:    xdp.PercentToPPM(r.ReorderRate),
+		Gap:     r.Gap,
+		DelayNs: uint64(r.Delay.Nanoseconds()),
+	}
+}
+
+// Stats returns the counters of the packets the service applied to since it
+// was started.
+func (r *Reorder) Stats() (xdp.ServiceStats, error) {
+	if r.x == nil {
+		return xdp.ServiceStats{}, xdp.ErrServiceNotRunning
+	}
+	return r.x.Stats(xdp.ServiceReorder)
+}
@@ -0,0 +1,105 @@
+package xdp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+)
+
+// maxDirections is the number of directions of the stats map keys, see
+// kerns/direction.h
+const maxDirections = 2
+
+// Stats are the counters of the packets a service applies to in one
+// direction, summed over all the CPUs. The delayed and modified (duplicated
+// or corrupted) packets are counted as passed too.
+type Stats struct {
+	PacketsSeen     uint64 `json:"packets_seen"`
+	BytesSeen       uint64 `json:"bytes_seen"`
+	PacketsPassed   uint64 `json:"packets_passed"`
+	BytesPassed     uint64 `json:"bytes_passed"`
+	PacketsDropped  uint64 `json:"packets_dropped"`
+	BytesDropped    uint64 `json:"bytes_dropped"`
+	PacketsDelayed  uint64 `json:"packets_delayed"`
+	BytesDelayed    uint64 `json:"bytes_delayed"`
+	PacketsModified uint64 `json:"packets_modified"`
+	BytesModified   uint64 `json:"bytes_modified"`
+}
+
+// ServiceStats are the counters of a service in each direction.
+type ServiceStats struct {
+	Ingress Stats `json:"ingress"`
+	Egress  Stats `json:"egress"`
+}
+
+// StatsReader is implemented by the services which count the packets they
+// apply to, see kerns/stats.h
+//
+// Stats must not be called concurrently with Start, Update or the CancelFunc
+// of the service, which closes the XDP object it reads.
+type StatsReader interface {
+	// Stats returns the counters of the service since it was started.
+	Stats() (ServiceStats, error)
+}
+
+// ErrStatsUnavailable is returned by Stats when the service does not count
+// the packets in its current configuration.
+var ErrStatsUnavailable = errors.New("stats are not available")
+
+func statsKey(service Service, directionKey uint32) uint32 {
+	return uint32(service)*maxDirections + directionKey
+}
+
+// Stats returns the counters of the service in each direction.
+func (x *XdpObject) Stats(service Service) (ServiceStats, error) {
+	var out ServiceStats
+	for _, d := range []struct {
+		key   uint32
+		stats *Stats
+	}{
+		{key: directionKeyIngress, stats: &out.Ingress},
+		{key: directionKeyEgress, stats: &out.Egress},
+	} {
+		var perCPU []bpfServiceStats
+		if err := x.BpfObjs.StatsMap.Lookup(statsKey(service, d.key), &perCPU); err != nil {
+			return ServiceStats{}, fmt.Errorf("lookup stats: %w", err)
+		}
+		*d.stats = sumStats(perCPU)
+	}
+	return out, nil
+}
+
+// ResetStats sets the counters of the service to zero on all the CPUs, so
+// they start over when the service is started.
+func (x *XdpObject) ResetStats(service Service) error {
+	cpus, err := ebpf.PossibleCPU()
+	if err != nil {
+		return fmt.Errorf("get possible CPUs: %w", err)
+	}
+
+	zero := make([]bpfServiceStats, cpus)
+	for _, key := range []uint32{directionKeyIngress, directionKeyEgress} {
+		if err := x.BpfObjs.StatsMap.Update(statsKey(service, key), zero, ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("reset stats: %w", err)
+		}
+	}
+	return nil
+}
+
+func sumStats(perCPU []bpfServiceStats) Stats {
+	var s Stats
+	for _, c := range perCPU {
+		s.PacketsSeen += c.PacketsSeen
+		s.BytesSeen += c.BytesSeen
+		s.PacketsPassed += c.PacketsPassed
+		s.BytesPassed += c.BytesPassed
+		s.PacketsDropped += c.PacketsDropped
+		s.BytesDropped += c.BytesDropped
+		s.PacketsDelayed += c.PacketsDelayed
+		s.BytesDelayed += c.BytesDelayed
+		s.PacketsModified += c.PacketsModified
+		s.BytesModified += c.BytesModified
+	}
+	return s
+}
@@ -0,0 +1,32 @@
+package xdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSumStats(t *testing.T) {
+	perCPU := []bpfServiceStats{
+		{PacketsSeen: 3, BytesSeen: 300, PacketsPassed: 2, BytesPassed: 200, PacketsDropped: 1, BytesDropped: 100},
+		{PacketsSeen: 2, BytesSeen: 120, PacketsPassed: 2, BytesPassed: 120, PacketsDelayed: 1, BytesDelayed: 60},
+		{},
+	}
+
+	assert.Equal(t, Stats{
+		PacketsSeen:    5,
+		BytesSeen:      420,
+		PacketsPassed:  4,
+		BytesPassed:    320,
+		PacketsDropped: 1,
+		BytesDropped:   100,
+		PacketsDelayed: 1,
+		BytesDelayed:   60,
+	}, sumStats(perCPU))
+}
+
+func TestStatsKey(t *testing.T) {
+	assert.Equal(t, uint32(0), statsKey(ServicePacketLoss, directionKeyIngress))
+	assert.Equal(t, uint32(1), statsKey(ServicePacketLoss, directionKeyEgress))
+	assert.Equal(t, uint32(13), statsKey(ServicePartition, directionKeyEgress))
+}
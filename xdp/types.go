@@ -1,76 +1,203 @@
 package xdp
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 )
 
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go bpf kerns/main.c -- -I../headers
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -type latency_params -type bandwidth_limit -type peer_key -type protocol_filter -type gilbert_elliott_params -type reorder_params -type corrupt_params -type service_stats bpf kerns/main.c -- -I../headers
 
 type XdpLoader interface {
+	// Validate checks the parameters, the unset ones standing for their
+	// defaults, without changing them or attaching anything. Start fills in
+	// the defaults.
+	Validate() error
 	Start() (CancelFunc, error)
 }
 
+// Updater is implemented by the services whose parameters can be changed
+// while they run, so there is no window without the impairment.
+//
+// Update must not be called concurrently with Start, Stats or the
+// CancelFunc of the service.
+type Updater interface {
+	// Update applies the impairment parameters of next, a service of the same
+	// type, to the running service. The network interface and the traffic
+	// the service applies to are kept.
+	Update(next XdpLoader) error
+}
+
+// ErrNetworkInterfaceNotSet is returned by Validate when the service has no
+// network interface.
+var ErrNetworkInterfaceNotSet = errors.New("network interface is not set")
+
+// ErrServiceNotRunning is returned by Update when the service is not started.
+var ErrServiceNotRunning = errors.New("service is not running")
+
 type CancelFunc func() error
 
+// LatencyParams is the value of the latency_params_map map.
+type LatencyParams = bpfLatencyParams
+
+// BandwidthLimit is the value of the bandwidth_limit_map map.
+type BandwidthLimit = bpfBandwidthLimit
+
+// ReorderParams is the value of the reorder_params_map map.
+type ReorderParams = bpfReorderParams
+
+// CorruptParams is the value of the corrupt_params_map map.
+type CorruptParams = bpfCorruptParams
+
+// GilbertElliottParams is the value of the gilbert_elliott_params_map map.
+type GilbertElliottParams = bpfGilbertElliottParams
+
+// PercentToPPM converts a percentage to parts per million, the unit of the
+// probabilities in the maps.
+func PercentToPPM(percent float64) uint32 {
+	return uint32(math.Round(percent * 10_000))
+}
+
+// PPMToPercent converts parts per million, the unit of the probabilities in
+// the maps, to a percentage.
+func PPMToPercent(ppm uint32) float64 {
+	return float64(ppm) / 10_000
+}
+
 type XdpObject struct {
 	BpfObjs           bpfObjects
 	Link              link.Link
+	TcLink            link.Link // egress TC program, attached on demand
 	totalServices     int32
-	mu                sync.Mutex
 	netInterfaceIndex int
 }
 
-var xdpObject XdpObject
+var (
+	// xdpObjects keeps one XDP object per network interface index, so
+	// services can target different devices concurrently.
+	xdpObjects   = make(map[int]*XdpObject)
+	xdpObjectsMu sync.Mutex
+)
 
+// GetPreparedXdpObject returns the XDP object attached to the given network
+// interface. The object is loaded and attached on the first call for the
+// interface and shared by all the services using the same interface.
+// With PinPath, the maps and links a previous process pinned for the
+// interface are reused, so the services it left running keep running.
 func GetPreparedXdpObject(netInterfaceIndex int) (*XdpObject, error) {
-	xdpObject.mu.Lock()
-	defer xdpObject.mu.Unlock()
-
-	// We add this once, so we know how many services are using this object.
-	xdpObject.totalServices++
+	xdpObjectsMu.Lock()
+	defer xdpObjectsMu.Unlock()
 
-	if xdpObject.Link != nil &&
-		xdpObject.netInterfaceIndex == netInterfaceIndex {
-		return &xdpObject, nil
+	if x, ok := xdpObjects[netInterfaceIndex]; ok {
+		// We add this once, so we know how many services are using this object.
+		x.totalServices++
+		return x, nil
 	}
-	xdpObject.netInterfaceIndex = netInterfaceIndex
+
+	x := &XdpObject{netInterfaceIndex: netInterfaceIndex}
 
 	// Load pre-compiled programs into the kernel.
-	err := loadBpfObjects(&xdpObject.BpfObjs, nil)
+	err := x.loadObjects()
 	if err != nil {
 		return nil, fmt.Errorf("could not load XDP program: %w", err)
 	}
 
-	xdpObject.Link, err = link.AttachXDP(link.XDPOptions{
-		Program:   xdpObject.BpfObjs.XdpMain,
-		Interface: netInterfaceIndex,
+	x.Link, err = x.attach(pinXdpLink, x.BpfObjs.XdpMain, func() (link.Link, error) {
+		return link.AttachXDP(link.XDPOptions{
+			Program:   x.BpfObjs.XdpMain,
+			Interface: netInterfaceIndex,
+		})
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("could not attach XDP program: %w", err)
+		err = fmt.Errorf("could not attach XDP program: %w", err)
+		if cErr := x.BpfObjs.Close(); cErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("close XDP objects: %w", cErr))
+		}
+		return nil, err
+	}
+
+	// The TC program a previous process attached keeps running, it is
+	// replaced right away.
+	if x.isPinned(pinTcLink) {
+		if err := x.attachTc(); err != nil {
+			return nil, errors.Join(err, x.Link.Close(), x.BpfObjs.Close())
+		}
 	}
-	return &xdpObject, nil
+
+	x.totalServices = 1
+	xdpObjects[netInterfaceIndex] = x
+
+	return x, nil
+}
+
+// AttachTc attaches the TC program to the egress path of the network
+// interface, unless it is already attached. It uses TCX, so it requires
+// Linux 6.6 or later. The program is detached when the object is closed.
+func (x *XdpObject) AttachTc() error {
+	xdpObjectsMu.Lock()
+	defer xdpObjectsMu.Unlock()
+
+	if x.TcLink != nil {
+		return nil
+	}
+	return x.attachTc()
+}
+
+func (x *XdpObject) attachTc() error {
+	l, err := x.attach(pinTcLink, x.BpfObjs.TcMain, func() (link.Link, error) {
+		return link.AttachTCX(link.TCXOptions{
+			Program:   x.BpfObjs.TcMain,
+			Attach:    ebpf.AttachTCXEgress,
+			Interface: x.netInterfaceIndex,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("could not attach TC program: %w", err)
+	}
+	x.TcLink = l
+
+	return nil
 }
 
 func (x *XdpObject) Close() error {
-	x.mu.Lock()
-	defer x.mu.Unlock()
+	xdpObjectsMu.Lock()
+	defer xdpObjectsMu.Unlock()
 
 	// The object is actually closed when all services using it are closed.
 	x.totalServices--
 	if x.totalServices > 0 {
 		return nil
 	}
+	delete(xdpObjects, x.netInterfaceIndex)
+
+	// No service runs anymore, the programs are detached. The links and the
+	// objects are closed even if something fails, as nothing else owns them.
+	var errs []error
+	if err := x.unpin(); err != nil {
+		errs = append(errs, fmt.Errorf("unpin XDP objects: %w", err))
+	}
 
 	if x.Link != nil {
 		if err := x.Link.Close(); err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("close XDP link: %w", err))
 		}
 	}
 	x.Link = nil
 
-	return x.BpfObjs.Close()
+	if x.TcLink != nil {
+		if err := x.TcLink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close TC link: %w", err))
+		}
+	}
+	x.TcLink = nil
+
+	if err := x.BpfObjs.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close XDP objects: %w", err))
+	}
+	return errors.Join(errs...)
 }
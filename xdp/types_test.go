@@ -0,0 +1,39 @@
+package xdp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/link"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLink is a link whose Close returns err and counts the calls.
+type fakeLink struct {
+	link.Link
+	err    error
+	closed int
+}
+
+func (l *fakeLink) Close() error {
+	l.closed++
+	return l.err
+}
+
+func TestXdpObjectCloseFailure(t *testing.T) {
+	errXdp := errors.New("xdp link")
+	xdpLink, tcLink := &fakeLink{err: errXdp}, &fakeLink{}
+	x := &XdpObject{Link: xdpLink, TcLink: tcLink, totalServices: 1, netInterfaceIndex: -1}
+
+	xdpObjectsMu.Lock()
+	xdpObjects[x.netInterfaceIndex] = x
+	xdpObjectsMu.Unlock()
+
+	err := x.Close()
+	assert.ErrorIs(t, err, errXdp)
+	assert.Equal(t, 1, xdpLink.closed)
+	assert.Equal(t, 1, tcLink.closed, "the TC link is closed despite the XDP link failure")
+	assert.Nil(t, x.Link)
+	assert.Nil(t, x.TcLink)
+	assert.NotContains(t, xdpObjects, x.netInterfaceIndex)
+}